@@ -6,11 +6,17 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"crud_ef/internal/adapter/http"
+	"crud_ef/internal/adapter/notify"
 	"crud_ef/internal/adapter/repository/postgres"
 	"crud_ef/internal/config"
 	"crud_ef/internal/db"
+	"crud_ef/internal/usecase/budget"
+	"crud_ef/internal/usecase/calendar"
+	"crud_ef/internal/usecase/catalog"
+	"crud_ef/internal/usecase/rates"
 	"crud_ef/internal/usecase/subscription"
 
 	_ "crud_ef/docs"
@@ -36,8 +42,21 @@ func main() {
 
 	repo := postgres.NewSubscriptionRepo(pg.Pool)
 	svc := subscription.NewService(repo)
+	ratesSvc := rates.NewService(postgres.NewExchangeRateRepo(pg.Pool))
+	budgetSvc := budget.NewService(postgres.NewBudgetRepo(pg.Pool), repo, notify.Log{})
+	svc.SetBudgetChecker(budgetSvc)
+	catalogRepo := postgres.NewCatalogRepo(pg.Pool)
+	svc.SetCatalogMatcher(catalogRepo)
+	catalogSvc := catalog.NewService(catalogRepo)
+	calendarSvc := calendar.NewService(postgres.NewCalendarRepo(pg.Pool), svc)
 
-	srv := http.New(cfg, svc)
+	srv := http.New(cfg, svc, ratesSvc, budgetSvc, catalogSvc, calendarSvc)
+
+	purgeCtx, stopPurge := context.WithCancel(ctx)
+	defer stopPurge()
+	if cfg.TrashRetentionDays > 0 && cfg.TrashPurgeInterval > 0 {
+		go purgeTrash(purgeCtx, svc, cfg.TrashRetention(), cfg.TrashPurgeInterval)
+	}
 
 	errCh := make(chan error, 1)
 	go func() { errCh <- srv.Run() }()
@@ -52,3 +71,22 @@ func main() {
 		log.Println("shutting down")
 	}
 }
+
+// purgeTrash периодически удаляет из корзины подписки старше retention.
+func purgeTrash(ctx context.Context, svc *subscription.Service, retention, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		n, err := svc.PurgeTrash(ctx, retention)
+		if err != nil {
+			log.Printf("purge trash: %v", err)
+		} else if n > 0 {
+			log.Printf("purge trash: %d subscriptions deleted", n)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+
+	"crud_ef/internal/adapter/ratesfile"
+	"crud_ef/internal/adapter/repository/postgres"
+	"crud_ef/internal/config"
+	"crud_ef/internal/db"
+	"crud_ef/internal/usecase/rates"
+)
+
+// Импорт курсов валют из локального файла ЕЦБ (XML или CSV):
+//
+//	go run ./cmd/rates-import -file eurofxref-hist.csv
+func main() {
+	file := flag.String("file", "", "path to ECB-style .xml or .csv file")
+	flag.Parse()
+	if *file == "" {
+		log.Fatal("-file is required")
+	}
+
+	items, err := ratesfile.ParseFile(*file)
+	if err != nil {
+		log.Fatalf("parse: %v", err)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("config: %v", err)
+	}
+
+	ctx := context.Background()
+
+	pg, err := db.New(ctx, cfg)
+	if err != nil {
+		log.Fatalf("postgres: %v", err)
+	}
+	defer pg.Close()
+
+	svc := rates.NewService(postgres.NewExchangeRateRepo(pg.Pool))
+	n, err := svc.Upsert(ctx, items)
+	if err != nil {
+		log.Fatalf("import: %v", err)
+	}
+	log.Printf("imported %d rates from %s", n, *file)
+}
@@ -0,0 +1,4 @@
+ALTER TABLE subscriptions
+    ADD COLUMN IF NOT EXISTS currency char(3) NOT NULL DEFAULT 'RUB' CHECK (currency ~ '^[A-Z]{3}$');
+
+CREATE INDEX IF NOT EXISTS idx_subscriptions_currency ON subscriptions(currency);
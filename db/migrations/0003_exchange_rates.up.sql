@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS exchange_rates (
+    rate_date  date NOT NULL,
+    base       char(3) NOT NULL CHECK (base ~ '^[A-Z]{3}$'),
+    quote      char(3) NOT NULL CHECK (quote ~ '^[A-Z]{3}$' AND quote <> base),
+    rate       numeric(18,8) NOT NULL CHECK (rate > 0),
+    created_at timestamptz NOT NULL DEFAULT now(),
+    PRIMARY KEY (rate_date, base, quote)
+);
+
+CREATE INDEX IF NOT EXISTS idx_exchange_rates_pair ON exchange_rates(base, quote, rate_date);
@@ -0,0 +1,8 @@
+DROP FUNCTION IF EXISTS monthly_equivalent(numeric, text, int);
+DROP FUNCTION IF EXISTS billing_step(text, int);
+
+ALTER TABLE subscriptions
+    DROP COLUMN IF EXISTS billing_interval,
+    DROP COLUMN IF EXISTS billing_period;
+
+ALTER TABLE subscriptions RENAME COLUMN price TO monthly_price;
@@ -0,0 +1,28 @@
+ALTER TABLE subscriptions RENAME COLUMN monthly_price TO price;
+
+ALTER TABLE subscriptions
+    ADD COLUMN IF NOT EXISTS billing_period text NOT NULL DEFAULT 'month'
+        CHECK (billing_period IN ('week', 'month', 'quarter', 'year')),
+    ADD COLUMN IF NOT EXISTS billing_interval int NOT NULL DEFAULT 1 CHECK (billing_interval > 0);
+
+-- Шаг между списаниями: billing_interval периодов billing_period.
+CREATE OR REPLACE FUNCTION billing_step(period text, n int) RETURNS interval
+LANGUAGE sql IMMUTABLE AS $$
+    SELECT CASE period
+        WHEN 'week' THEN make_interval(weeks => n)
+        WHEN 'month' THEN make_interval(months => n)
+        WHEN 'quarter' THEN make_interval(months => 3 * n)
+        WHEN 'year' THEN make_interval(years => n)
+    END
+$$;
+
+-- Стоимость списания, равномерно распределённая по месяцам.
+CREATE OR REPLACE FUNCTION monthly_equivalent(price numeric, period text, n int) RETURNS numeric
+LANGUAGE sql IMMUTABLE AS $$
+    SELECT CASE period
+        WHEN 'week' THEN price * 52 / 12 / n
+        WHEN 'month' THEN price / n
+        WHEN 'quarter' THEN price / (3 * n)
+        WHEN 'year' THEN price / (12 * n)
+    END
+$$;
@@ -0,0 +1,15 @@
+ALTER TABLE subscriptions DROP CONSTRAINT IF EXISTS subscriptions_end_date_check;
+
+UPDATE subscriptions
+SET start_date = date_trunc('month', start_date)::date,
+    end_date = date_trunc('month', end_date)::date;
+
+ALTER TABLE subscriptions RENAME COLUMN start_date TO start_month;
+ALTER TABLE subscriptions RENAME COLUMN end_date TO end_month;
+
+ALTER TABLE subscriptions
+    ADD CONSTRAINT subscriptions_start_month_check CHECK (date_trunc('month', start_month) = start_month),
+    ADD CONSTRAINT subscriptions_end_month_check CHECK (
+        (end_month IS NULL) OR
+        (date_trunc('month', end_month) = end_month AND end_month >= start_month)
+    );
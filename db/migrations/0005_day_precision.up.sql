@@ -0,0 +1,13 @@
+ALTER TABLE subscriptions DROP CONSTRAINT IF EXISTS subscriptions_start_month_check;
+ALTER TABLE subscriptions DROP CONSTRAINT IF EXISTS subscriptions_end_month_check;
+
+ALTER TABLE subscriptions RENAME COLUMN start_month TO start_date;
+ALTER TABLE subscriptions RENAME COLUMN end_month TO end_date;
+
+-- end_month хранил первое число последнего месяца; end_date — последний день подписки включительно.
+UPDATE subscriptions
+SET end_date = (end_date + interval '1 month' - interval '1 day')::date
+WHERE end_date IS NOT NULL;
+
+ALTER TABLE subscriptions
+    ADD CONSTRAINT subscriptions_end_date_check CHECK (end_date IS NULL OR end_date >= start_date);
@@ -0,0 +1,5 @@
+UPDATE subscriptions s
+SET price = price_at(s.id, s.price, current_date);
+
+DROP FUNCTION IF EXISTS price_at(uuid, numeric, date);
+DROP TABLE IF EXISTS subscription_prices;
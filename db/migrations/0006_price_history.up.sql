@@ -0,0 +1,24 @@
+CREATE TABLE IF NOT EXISTS subscription_prices (
+    subscription_id uuid NOT NULL REFERENCES subscriptions(id) ON DELETE CASCADE,
+    effective_month date NOT NULL CHECK (date_trunc('month', effective_month) = effective_month),
+    price           numeric(12,2) NOT NULL CHECK (price >= 0),
+    created_at      timestamptz NOT NULL DEFAULT now(),
+    PRIMARY KEY (subscription_id, effective_month)
+);
+
+INSERT INTO subscription_prices (subscription_id, effective_month, price)
+SELECT id, date_trunc('month', start_date)::date, price
+FROM subscriptions
+ON CONFLICT DO NOTHING;
+
+-- Цена, действующая в месяце даты at; fallback — цена из subscriptions, если истории нет.
+CREATE OR REPLACE FUNCTION price_at(sub uuid, fallback numeric, at date) RETURNS numeric
+LANGUAGE sql STABLE AS $$
+    SELECT COALESCE(
+        (SELECT p.price
+           FROM subscription_prices p
+          WHERE p.subscription_id = sub AND p.effective_month <= at
+          ORDER BY p.effective_month DESC
+          LIMIT 1),
+        fallback)
+$$;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_subscriptions_trial_end;
+ALTER TABLE subscriptions DROP CONSTRAINT IF EXISTS subscriptions_trial_end_check;
+ALTER TABLE subscriptions DROP COLUMN IF EXISTS trial_end;
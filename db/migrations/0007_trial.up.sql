@@ -0,0 +1,5 @@
+ALTER TABLE subscriptions
+    ADD COLUMN IF NOT EXISTS trial_end date NULL,
+    ADD CONSTRAINT subscriptions_trial_end_check CHECK (trial_end IS NULL OR trial_end >= start_date);
+
+CREATE INDEX IF NOT EXISTS idx_subscriptions_trial_end ON subscriptions(trial_end) WHERE trial_end IS NOT NULL;
@@ -0,0 +1,2 @@
+DROP FUNCTION IF EXISTS is_paused(uuid, date);
+DROP TABLE IF EXISTS subscription_pauses;
@@ -0,0 +1,23 @@
+CREATE TABLE IF NOT EXISTS subscription_pauses (
+    id              uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    subscription_id uuid NOT NULL REFERENCES subscriptions(id) ON DELETE CASCADE,
+    pause_from      date NOT NULL,
+    resume_at       date NULL CHECK (resume_at IS NULL OR resume_at > pause_from),
+    created_at      timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_subscription_pauses_sub ON subscription_pauses(subscription_id, pause_from);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_subscription_pauses_open
+    ON subscription_pauses(subscription_id) WHERE resume_at IS NULL;
+
+-- Приостановлена ли подписка в день at: pause_from включительно, resume_at — первый день после паузы.
+CREATE OR REPLACE FUNCTION is_paused(sub uuid, at date) RETURNS boolean
+LANGUAGE sql STABLE AS $$
+    SELECT EXISTS (
+        SELECT 1
+          FROM subscription_pauses p
+         WHERE p.subscription_id = sub
+           AND p.pause_from <= at
+           AND (p.resume_at IS NULL OR p.resume_at > at)
+    )
+$$;
@@ -0,0 +1,6 @@
+ALTER TABLE subscriptions
+    DROP COLUMN IF EXISTS cancel_reason,
+    DROP COLUMN IF EXISTS cancelled_by,
+    DROP COLUMN IF EXISTS cancelled_at,
+    DROP COLUMN IF EXISTS cancel_requested_at,
+    DROP COLUMN IF EXISTS notice_days;
@@ -0,0 +1,6 @@
+ALTER TABLE subscriptions
+    ADD COLUMN IF NOT EXISTS notice_days int NOT NULL DEFAULT 0 CHECK (notice_days >= 0),
+    ADD COLUMN IF NOT EXISTS cancel_requested_at date NULL,
+    ADD COLUMN IF NOT EXISTS cancelled_at timestamptz NULL,
+    ADD COLUMN IF NOT EXISTS cancelled_by text NULL,
+    ADD COLUMN IF NOT EXISTS cancel_reason text NULL;
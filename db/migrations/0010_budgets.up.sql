@@ -0,0 +1,27 @@
+CREATE TABLE IF NOT EXISTS budgets (
+    id            uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    scope         text NOT NULL CHECK (scope IN ('user', 'service', 'global')),
+    user_id       uuid NULL,
+    service_name  text NULL,
+    monthly_limit numeric(12,2) NOT NULL CHECK (monthly_limit > 0),
+    currency      char(3) NOT NULL DEFAULT 'RUB' CHECK (currency ~ '^[A-Z]{3}$'),
+    mode          text NOT NULL DEFAULT 'charges' CHECK (mode IN ('charges', 'spread')),
+    thresholds    int[] NOT NULL DEFAULT '{80,100}',
+    created_at    timestamptz NOT NULL DEFAULT now(),
+    updated_at    timestamptz NOT NULL DEFAULT now(),
+    CHECK ((scope = 'user') = (user_id IS NOT NULL)),
+    CHECK ((scope = 'service') = (service_name IS NOT NULL))
+);
+
+CREATE INDEX IF NOT EXISTS idx_budgets_user ON budgets(user_id) WHERE scope = 'user';
+
+-- Сработавшие пороги: не больше одного оповещения на бюджет, месяц и порог.
+CREATE TABLE IF NOT EXISTS budget_alerts (
+    budget_id       uuid NOT NULL REFERENCES budgets(id) ON DELETE CASCADE,
+    month           date NOT NULL CHECK (date_trunc('month', month) = month),
+    threshold       int NOT NULL,
+    spent           numeric(14,2) NOT NULL,
+    subscription_id uuid NULL,
+    created_at      timestamptz NOT NULL DEFAULT now(),
+    PRIMARY KEY (budget_id, month, threshold)
+);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_subscriptions_user_service_norm;
+DROP FUNCTION IF EXISTS normalize_service_name(text);
@@ -0,0 +1,8 @@
+-- Название сервиса для сравнения: без учёта регистра и лишних пробелов.
+CREATE OR REPLACE FUNCTION normalize_service_name(name text) RETURNS text
+LANGUAGE sql IMMUTABLE AS $$
+    SELECT lower(btrim(regexp_replace(name, '\s+', ' ', 'g')))
+$$;
+
+CREATE INDEX IF NOT EXISTS idx_subscriptions_user_service_norm
+    ON subscriptions(user_id, normalize_service_name(service_name));
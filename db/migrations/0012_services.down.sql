@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_subscriptions_service_id;
+ALTER TABLE subscriptions DROP COLUMN IF EXISTS service_id;
+DROP FUNCTION IF EXISTS match_service(text);
+DROP TABLE IF EXISTS services;
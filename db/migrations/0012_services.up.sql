@@ -0,0 +1,41 @@
+CREATE TABLE IF NOT EXISTS services (
+    id          uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    name        text NOT NULL CHECK (btrim(name) <> ''),
+    aliases     text[] NOT NULL DEFAULT '{}',
+    category    text NULL,
+    vendor_url  text NULL,
+    created_at  timestamptz NOT NULL DEFAULT now(),
+    updated_at  timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS ux_services_name ON services(normalize_service_name(name));
+
+-- Сервис каталога, которому соответствует название: по имени или одному из псевдонимов.
+CREATE OR REPLACE FUNCTION match_service(raw text) RETURNS uuid
+LANGUAGE sql STABLE AS $$
+    SELECT c.id
+      FROM services c
+     WHERE normalize_service_name(c.name) = normalize_service_name(raw)
+        OR EXISTS (SELECT 1 FROM unnest(c.aliases) a WHERE normalize_service_name(a) = normalize_service_name(raw))
+     ORDER BY normalize_service_name(c.name) = normalize_service_name(raw) DESC, c.created_at
+     LIMIT 1
+$$;
+
+ALTER TABLE subscriptions
+    ADD COLUMN IF NOT EXISTS service_id uuid NULL REFERENCES services(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_subscriptions_service_id ON subscriptions(service_id);
+
+-- Каталог из уже сохранённых подписок: одна запись на нормализованное название,
+-- каноническое имя — самое частое написание.
+INSERT INTO services (name)
+SELECT (array_agg(service_name ORDER BY cnt DESC, service_name))[1]
+FROM (
+    SELECT btrim(service_name) AS service_name, normalize_service_name(service_name) AS norm, count(*) AS cnt
+    FROM subscriptions
+    GROUP BY 1, 2
+) x
+GROUP BY norm
+ON CONFLICT DO NOTHING;
+
+UPDATE subscriptions SET service_id = match_service(service_name) WHERE service_id IS NULL;
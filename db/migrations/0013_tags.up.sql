@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS subscription_tags (
+    subscription_id uuid NOT NULL REFERENCES subscriptions(id) ON DELETE CASCADE,
+    tag             text NOT NULL CHECK (tag <> '' AND length(tag) <= 64 AND tag = lower(tag)),
+    PRIMARY KEY (subscription_id, tag)
+);
+
+CREATE INDEX IF NOT EXISTS idx_subscription_tags_tag ON subscription_tags(tag);
@@ -0,0 +1,2 @@
+DROP FUNCTION IF EXISTS member_shares(uuid, uuid, numeric, numeric);
+DROP TABLE IF EXISTS subscription_members;
@@ -0,0 +1,49 @@
+-- Участники, с которыми владелец подписки (subscriptions.user_id) делит её стоимость.
+CREATE TABLE IF NOT EXISTS subscription_members (
+    subscription_id uuid NOT NULL REFERENCES subscriptions(id) ON DELETE CASCADE,
+    user_id         uuid NOT NULL,
+    share_type      text NOT NULL CHECK (share_type IN ('equal', 'percent', 'fixed')),
+    share_value     numeric(12,2) NULL,
+    created_at      timestamptz NOT NULL DEFAULT now(),
+    PRIMARY KEY (subscription_id, user_id),
+    CHECK ((share_type = 'equal') = (share_value IS NULL)),
+    CHECK (share_type <> 'percent' OR (share_value > 0 AND share_value <= 100)),
+    CHECK (share_type <> 'fixed' OR share_value > 0)
+);
+
+CREATE INDEX IF NOT EXISTS idx_subscription_members_user ON subscription_members(user_id);
+
+-- Доли начисления total (при цене списания price) по участникам подписки sub.
+-- fixed — сумма с одного списания (доля total в пропорции share_value / price), percent —
+-- процент от total; остаток делится поровну между участниками equal. Владелец, не указанный
+-- среди участников, участвует как equal; если участников equal нет, остаток платит владелец.
+CREATE OR REPLACE FUNCTION member_shares(sub uuid, owner uuid, total numeric, price numeric)
+RETURNS TABLE (member_id uuid, share numeric)
+LANGUAGE sql STABLE AS $$
+    WITH m AS (
+        SELECT sm.user_id, sm.share_type, sm.share_value
+          FROM subscription_members sm
+         WHERE sm.subscription_id = sub
+        UNION ALL
+        SELECT owner, 'equal', NULL
+         WHERE NOT EXISTS (
+             SELECT 1 FROM subscription_members sm WHERE sm.subscription_id = sub AND sm.user_id = owner)
+    ),
+    parts AS (
+        SELECT m.user_id,
+               CASE m.share_type
+                    WHEN 'fixed' THEN total * LEAST(m.share_value / NULLIF(price, 0), 1)
+                    ELSE total * m.share_value / 100 END AS amount
+          FROM m
+         WHERE m.share_type <> 'equal'
+    ),
+    rest AS (
+        SELECT GREATEST(total - COALESCE((SELECT sum(p.amount) FROM parts p), 0), 0) AS amount,
+               (SELECT count(*) FROM m WHERE m.share_type = 'equal') AS n
+    )
+    SELECT p.user_id, COALESCE(p.amount, 0) FROM parts p
+    UNION ALL
+    SELECT m.user_id, r.amount / r.n FROM m, rest r WHERE m.share_type = 'equal'
+    UNION ALL
+    SELECT owner, r.amount FROM rest r WHERE r.n = 0 AND r.amount > 0
+$$;
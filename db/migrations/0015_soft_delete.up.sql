@@ -0,0 +1,3 @@
+ALTER TABLE subscriptions ADD COLUMN IF NOT EXISTS deleted_at timestamptz NULL;
+
+CREATE INDEX IF NOT EXISTS idx_subscriptions_deleted ON subscriptions(deleted_at) WHERE deleted_at IS NOT NULL;
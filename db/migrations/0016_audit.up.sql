@@ -0,0 +1,28 @@
+-- Журнал изменений подписок. Внешнего ключа нет: история сохраняется и после окончательного удаления.
+CREATE TABLE IF NOT EXISTS subscription_events (
+    id              bigint GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    subscription_id uuid NOT NULL,
+    action          text NOT NULL CHECK (action IN ('create', 'update', 'cancel', 'delete', 'restore')),
+    actor           text NULL,
+    request_id      text NULL,
+    before          jsonb NULL,
+    after           jsonb NULL,
+    created_at      timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_subscription_events_subscription ON subscription_events(subscription_id, id);
+CREATE INDEX IF NOT EXISTS idx_subscription_events_created ON subscription_events(created_at);
+CREATE INDEX IF NOT EXISTS idx_subscription_events_actor ON subscription_events(actor, created_at);
+
+-- Журнал только дополняется.
+CREATE OR REPLACE FUNCTION subscription_events_append_only() RETURNS trigger
+LANGUAGE plpgsql AS $$
+BEGIN
+    RAISE EXCEPTION 'subscription_events is append-only';
+END;
+$$;
+
+DROP TRIGGER IF EXISTS trg_subscription_events_append_only ON subscription_events;
+CREATE TRIGGER trg_subscription_events_append_only
+    BEFORE UPDATE OR DELETE ON subscription_events
+    FOR EACH ROW EXECUTE FUNCTION subscription_events_append_only();
@@ -0,0 +1,2 @@
+-- Версия строки для оптимистичной блокировки; увеличивается при каждом изменении подписки.
+ALTER TABLE subscriptions ADD COLUMN IF NOT EXISTS version int NOT NULL DEFAULT 1;
@@ -0,0 +1,6 @@
+-- Секретные токены календарных лент: по одному на пользователя, хранится только SHA-256.
+CREATE TABLE IF NOT EXISTS calendar_tokens (
+    user_id    uuid PRIMARY KEY,
+    token_hash bytea NOT NULL,
+    created_at timestamptz NOT NULL DEFAULT now()
+);
@@ -0,0 +1,2 @@
+-- Порядок списка подписок и курсорная пагинация по (created_at, id).
+CREATE INDEX IF NOT EXISTS idx_subscriptions_created_id ON subscriptions(created_at DESC, id DESC) WHERE deleted_at IS NULL;
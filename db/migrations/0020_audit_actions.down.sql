@@ -0,0 +1,6 @@
+ALTER TABLE subscription_events DISABLE TRIGGER trg_subscription_events_append_only;
+DELETE FROM subscription_events WHERE action IN ('price', 'pause', 'resume', 'members', 'purge');
+ALTER TABLE subscription_events ENABLE TRIGGER trg_subscription_events_append_only;
+ALTER TABLE subscription_events DROP CONSTRAINT IF EXISTS subscription_events_action_check;
+ALTER TABLE subscription_events ADD CONSTRAINT subscription_events_action_check
+    CHECK (action IN ('create', 'update', 'cancel', 'delete', 'restore'));
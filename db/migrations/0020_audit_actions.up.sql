@@ -0,0 +1,4 @@
+-- Журнал изменений: цены, паузы, участники и окончательное удаление из корзины.
+ALTER TABLE subscription_events DROP CONSTRAINT IF EXISTS subscription_events_action_check;
+ALTER TABLE subscription_events ADD CONSTRAINT subscription_events_action_check
+    CHECK (action IN ('create', 'update', 'cancel', 'delete', 'restore', 'price', 'pause', 'resume', 'members', 'purge'));
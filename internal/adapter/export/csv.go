@@ -0,0 +1,40 @@
+package export
+
+import (
+	"encoding/csv"
+	"io"
+)
+
+// CSV пишет только первый лист: у CSV нет листов, остальные пропускаются.
+type CSV struct {
+	w      *csv.Writer
+	sheets int
+}
+
+func NewCSV(w io.Writer) *CSV {
+	return &CSV{w: csv.NewWriter(w)}
+}
+
+func (c *CSV) Sheet(_ string, header ...string) error {
+	c.sheets++
+	if c.sheets > 1 {
+		return nil
+	}
+	return c.w.Write(header)
+}
+
+func (c *CSV) Row(cells ...any) error {
+	if c.sheets > 1 {
+		return nil
+	}
+	record := make([]string, len(cells))
+	for i, v := range cells {
+		record[i] = cellText(v)
+	}
+	return c.w.Write(record)
+}
+
+func (c *CSV) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
@@ -0,0 +1,97 @@
+// Package export пишет табличные выгрузки в CSV и XLSX построчно, не собирая их в памяти.
+package export
+
+import (
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Форматы выгрузки и их MIME-типы.
+const (
+	FormatCSV  = "csv"
+	FormatXLSX = "xlsx"
+
+	ContentTypeCSV  = "text/csv; charset=utf-8"
+	ContentTypeXLSX = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+)
+
+// Decimal — денежная сумма; в XLSX записывается числом с двумя знаками после запятой.
+type Decimal string
+
+// Writer пишет листы по строкам. Значения ячеек: string, Decimal, int, bool, time.Time,
+// *string и nil (пустая ячейка).
+type Writer interface {
+	// Sheet начинает новый лист с заголовком.
+	Sheet(name string, header ...string) error
+	Row(cells ...any) error
+	Close() error
+}
+
+// formatDecimal приводит сумму к виду 1234.50; некорректное значение возвращается как есть.
+func formatDecimal(d Decimal) string {
+	r, ok := new(big.Rat).SetString(string(d))
+	if !ok {
+		return string(d)
+	}
+	return r.FloatString(2)
+}
+
+// AddDecimal складывает суммы без потери точности.
+func AddDecimal(a, b Decimal) Decimal {
+	x, ok := new(big.Rat).SetString(string(a))
+	if !ok {
+		x = new(big.Rat)
+	}
+	y, ok := new(big.Rat).SetString(string(b))
+	if !ok {
+		y = new(big.Rat)
+	}
+	return Decimal(x.Add(x, y).FloatString(2))
+}
+
+// text — текстовое представление ячейки для CSV и строковых ячеек XLSX.
+func text(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case *string:
+		if v == nil {
+			return ""
+		}
+		return *v
+	case Decimal:
+		return formatDecimal(v)
+	case int:
+		return strconv.Itoa(v)
+	case bool:
+		return strconv.FormatBool(v)
+	case time.Time:
+		return v.UTC().Format(time.RFC3339)
+	case *time.Time:
+		if v == nil {
+			return ""
+		}
+		return v.UTC().Format(time.RFC3339)
+	case interface{ String() string }:
+		return v.String()
+	}
+	return ""
+}
+
+// cellText — text для ячейки выгрузки: строка, которую табличный редактор принял бы
+// за формулу (начинается с =, +, -, @, табуляции или CR), получает префикс-апостроф.
+// Числа (Decimal, int) не меняются.
+func cellText(v any) string {
+	s := text(v)
+	switch v.(type) {
+	case string, *string:
+		if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+			return "'" + s
+		}
+	}
+	return s
+}
@@ -0,0 +1,211 @@
+package export
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/xml"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// XLSX — минимальная книга Office Open XML: строки встроены в ячейки (inlineStr), суммы —
+// числа с форматом 0.00, заголовок выделен жирным. Листы пишутся в архив по мере заполнения.
+type XLSX struct {
+	zw     *zip.Writer
+	sheet  *bufio.Writer
+	names  []string
+	row    int
+	closed bool
+}
+
+func NewXLSX(w io.Writer) *XLSX {
+	return &XLSX{zw: zip.NewWriter(w)}
+}
+
+// Стили ячеек из styles.xml.
+const (
+	styleDecimal = 1
+	styleHeader  = 2
+)
+
+func (x *XLSX) Sheet(name string, header ...string) error {
+	if err := x.endSheet(); err != nil {
+		return err
+	}
+	x.names = append(x.names, sheetName(name, len(x.names)+1))
+	f, err := x.zw.Create("xl/worksheets/sheet" + strconv.Itoa(len(x.names)) + ".xml")
+	if err != nil {
+		return err
+	}
+	x.sheet = bufio.NewWriter(f)
+	x.row = 0
+	x.sheet.WriteString(xml.Header + `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+	if len(header) == 0 {
+		return nil
+	}
+	cells := make([]any, len(header))
+	for i, h := range header {
+		cells[i] = h
+	}
+	return x.writeRow(cells, styleHeader)
+}
+
+func (x *XLSX) Row(cells ...any) error {
+	if x.sheet == nil {
+		if err := x.Sheet("Sheet1"); err != nil {
+			return err
+		}
+	}
+	return x.writeRow(cells, 0)
+}
+
+func (x *XLSX) writeRow(cells []any, style int) error {
+	x.row++
+	r := strconv.Itoa(x.row)
+	b := x.sheet
+	b.WriteString(`<row r="` + r + `">`)
+	for i, v := range cells {
+		ref := column(i) + r
+		switch v := v.(type) {
+		case nil:
+			continue
+		case int:
+			b.WriteString(`<c r="` + ref + `"><v>` + strconv.Itoa(v) + `</v></c>`)
+		case Decimal:
+			if v == "" {
+				continue
+			}
+			b.WriteString(`<c r="` + ref + `" s="` + strconv.Itoa(styleDecimal) + `"><v>` + formatDecimal(v) + `</v></c>`)
+		case bool:
+			val := "0"
+			if v {
+				val = "1"
+			}
+			b.WriteString(`<c r="` + ref + `" t="b"><v>` + val + `</v></c>`)
+		default:
+			s := cellText(v)
+			if s == "" {
+				continue
+			}
+			b.WriteString(`<c r="` + ref + `" t="inlineStr"`)
+			if style != 0 {
+				b.WriteString(` s="` + strconv.Itoa(style) + `"`)
+			}
+			b.WriteString(`><is><t xml:space="preserve">`)
+			if err := xml.EscapeText(b, []byte(s)); err != nil {
+				return err
+			}
+			b.WriteString(`</t></is></c>`)
+		}
+	}
+	_, err := b.WriteString(`</row>`)
+	return err
+}
+
+func (x *XLSX) endSheet() error {
+	if x.sheet == nil {
+		return nil
+	}
+	x.sheet.WriteString(`</sheetData></worksheet>`)
+	err := x.sheet.Flush()
+	x.sheet = nil
+	return err
+}
+
+// Close дописывает описание книги и закрывает архив.
+func (x *XLSX) Close() error {
+	if x.closed {
+		return errors.New("xlsx: already closed")
+	}
+	x.closed = true
+	if len(x.names) == 0 {
+		if err := x.Sheet("Sheet1"); err != nil {
+			return err
+		}
+	}
+	if err := x.endSheet(); err != nil {
+		return err
+	}
+
+	var types, sheets, rels strings.Builder
+	for i, name := range x.names {
+		n := strconv.Itoa(i + 1)
+		types.WriteString(`<Override PartName="/xl/worksheets/sheet` + n + `.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`)
+		sheets.WriteString(`<sheet name="`)
+		xml.EscapeText(&sheets, []byte(name))
+		sheets.WriteString(`" sheetId="` + n + `" r:id="rId` + n + `"/>`)
+		rels.WriteString(`<Relationship Id="rId` + n + `" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet` + n + `.xml"/>`)
+	}
+	stylesID := "rId" + strconv.Itoa(len(x.names)+1)
+	rels.WriteString(`<Relationship Id="` + stylesID + `" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>`)
+
+	files := []struct{ name, body string }{
+		{"[Content_Types].xml", `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+			`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+			`<Default Extension="xml" ContentType="application/xml"/>` +
+			`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+			`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>` +
+			types.String() + `</Types>`},
+		{"_rels/.rels", `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+			`</Relationships>`},
+		{"xl/workbook.xml", `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" ` +
+			`xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets>` +
+			sheets.String() + `</sheets></workbook>`},
+		{"xl/_rels/workbook.xml.rels", `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			rels.String() + `</Relationships>`},
+		{"xl/styles.xml", stylesXML},
+	}
+	for _, f := range files {
+		w, err := x.zw.Create(f.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(w, xml.Header+f.body); err != nil {
+			return err
+		}
+	}
+	return x.zw.Close()
+}
+
+// stylesXML: xf 0 — обычная ячейка, 1 — число 0.00 (встроенный формат 2), 2 — жирный шрифт.
+const stylesXML = `<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+	`<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>` +
+	`<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>` +
+	`<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>` +
+	`<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>` +
+	`<cellXfs count="3">` +
+	`<xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/>` +
+	`<xf numFmtId="2" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>` +
+	`<xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/>` +
+	`</cellXfs>` +
+	`<cellStyles count="1"><cellStyle name="Normal" xfId="0" builtinId="0"/></cellStyles>` +
+	`</styleSheet>`
+
+// column возвращает буквенное имя столбца: 0 — A, 26 — AA.
+func column(i int) string {
+	name := ""
+	for i++; i > 0; i = (i - 1) / 26 {
+		name = string(rune('A'+(i-1)%26)) + name
+	}
+	return name
+}
+
+// sheetName убирает недопустимые в имени листа символы и ограничивает длину 31 символом.
+func sheetName(name string, n int) string {
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`[]:*?/\`, r) {
+			return '_'
+		}
+		return r
+	}, strings.TrimSpace(name))
+	if runes := []rune(name); len(runes) > 31 {
+		name = string(runes[:31])
+	}
+	if name == "" {
+		name = "Sheet" + strconv.Itoa(n)
+	}
+	return name
+}
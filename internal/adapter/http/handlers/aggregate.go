@@ -2,19 +2,131 @@ package handlers
 
 import (
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 
+	"crud_ef/internal/adapter/export"
+	"crud_ef/internal/domain"
 	"crud_ef/internal/usecase/subscription"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
 )
 
+type CurrencyTotalDTO struct {
+	Currency string `json:"currency"`
+	Total    string `json:"total"`
+}
+
+type AppliedRateDTO struct {
+	Month    string  `json:"month"`
+	Currency string  `json:"currency"`
+	Rate     string  `json:"rate"`
+	RateDate *string `json:"rate_date,omitempty"`
+}
+
+type MissingRateDTO struct {
+	Month    string `json:"month"`
+	Currency string `json:"currency"`
+	Amount   string `json:"amount"`
+}
+
+// ConvertedTotalDTO — сумма, пересчитанная в запрошенную валюту.
+// Начисления из MissingRates в Total не входят.
+type ConvertedTotalDTO struct {
+	Currency     string           `json:"currency"`
+	Total        string           `json:"total"`
+	RatesUsed    []AppliedRateDTO `json:"rates_used"`
+	MissingRates []MissingRateDTO `json:"missing_rates"`
+}
+
 type TotalResponse struct {
-	From        string  `json:"from"`
-	To          string  `json:"to"`
-	UserID      *string `json:"user_id,omitempty"`
-	ServiceName *string `json:"service_name,omitempty"`
-	Total       string  `json:"total"`
+	From        string             `json:"from"`
+	To          string             `json:"to"`
+	Mode        string             `json:"mode"`
+	Prorate     bool               `json:"prorate,omitempty"`
+	UserID      *string            `json:"user_id,omitempty"`
+	ServiceName *string            `json:"service_name,omitempty"`
+	ServiceID   *uuid.UUID         `json:"service_id,omitempty"`
+	Totals      []CurrencyTotalDTO `json:"totals"`
+	Converted   *ConvertedTotalDTO `json:"converted,omitempty"`
+}
+
+type MonthBreakdownDTO struct {
+	Month               string             `json:"month"`
+	ActiveSubscriptions int                `json:"active_subscriptions"`
+	Totals              []CurrencyTotalDTO `json:"totals"`
+}
+
+type BreakdownResponse struct {
+	From        string              `json:"from"`
+	To          string              `json:"to"`
+	Mode        string              `json:"mode"`
+	Prorate     bool                `json:"prorate,omitempty"`
+	UserID      *string             `json:"user_id,omitempty"`
+	ServiceName *string             `json:"service_name,omitempty"`
+	ServiceID   *uuid.UUID          `json:"service_id,omitempty"`
+	Months      []MonthBreakdownDTO `json:"months"`
+}
+
+// ForecastMonthDTO — прогноз на месяц; open_ended_totals — часть totals от бессрочных подписок.
+type ForecastMonthDTO struct {
+	Month                  string             `json:"month"`
+	ActiveSubscriptions    int                `json:"active_subscriptions"`
+	OpenEndedSubscriptions int                `json:"open_ended_subscriptions"`
+	Totals                 []CurrencyTotalDTO `json:"totals"`
+	OpenEndedTotals        []CurrencyTotalDTO `json:"open_ended_totals"`
+	Confidence             string             `json:"confidence"`
+	Note                   string             `json:"note"`
+}
+
+type ForecastResponse struct {
+	Months      int                `json:"months"`
+	Mode        string             `json:"mode"`
+	Prorate     bool               `json:"prorate,omitempty"`
+	UserID      *string            `json:"user_id,omitempty"`
+	ServiceName *string            `json:"service_name,omitempty"`
+	ServiceID   *uuid.UUID         `json:"service_id,omitempty"`
+	Forecast    []ForecastMonthDTO `json:"forecast"`
+}
+
+// GroupTotalDTO — сумма группы; у группы other ключей нет, groups — сколько групп в неё свёрнуто.
+type GroupTotalDTO struct {
+	Keys     map[string]string `json:"keys,omitempty"`
+	Currency string            `json:"currency"`
+	Total    string            `json:"total"`
+	Other    bool              `json:"other,omitempty"`
+	Groups   int               `json:"groups,omitempty"`
+}
+
+type GroupedTotalResponse struct {
+	From        string             `json:"from"`
+	To          string             `json:"to"`
+	Mode        string             `json:"mode"`
+	Prorate     bool               `json:"prorate,omitempty"`
+	UserID      *string            `json:"user_id,omitempty"`
+	ServiceName *string            `json:"service_name,omitempty"`
+	ServiceID   *uuid.UUID         `json:"service_id,omitempty"`
+	GroupBy     []string           `json:"group_by"`
+	Top         int                `json:"top,omitempty"`
+	Groups      []GroupTotalDTO    `json:"groups"`
+	GrandTotals []CurrencyTotalDTO `json:"grand_totals"`
+}
+
+// SettlementDTO — from_user должен to_user сумму amount.
+type SettlementDTO struct {
+	FromUser uuid.UUID `json:"from_user"`
+	ToUser   uuid.UUID `json:"to_user"`
+	Currency string    `json:"currency"`
+	Amount   string    `json:"amount"`
+}
+
+type SettlementsResponse struct {
+	From        string          `json:"from"`
+	To          string          `json:"to"`
+	UserID      *string         `json:"user_id,omitempty"`
+	Settlements []SettlementDTO `json:"settlements"`
 }
 
 type AggregateRoutes struct {
@@ -27,61 +139,530 @@ func NewAggregateRoutes(svc *subscription.Service) *AggregateRoutes {
 
 func (h *AggregateRoutes) Register(r chi.Router) {
 	r.Get("/subscriptions/total", h.total)
+	r.Get("/subscriptions/total/grouped", h.grouped)
+	r.Get("/subscriptions/breakdown", h.breakdown)
+	r.Get("/subscriptions/forecast", h.forecast)
+	r.Get("/settlements", h.settlements)
 }
 
-// @Summary      Total cost for period
+// @Summary      Total cost for period (per currency)
 // @Tags         subscriptions
 // @Produce      json
+// @Produce      text/csv
+// @Produce      application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
 // @Param        from          query  string  true   "YYYY-MM"
 // @Param        to            query  string  true   "YYYY-MM"
 // @Param        user_id       query  string  false  "User UUID"
 // @Param        service_name  query  string  false  "Service filter (ILIKE)"
+// @Param        service_id    query  string  false  "Exact catalog service UUID"
+// @Param        tag           query  []string  false  "Filter by tags (repeat or comma-separated)"  collectionFormat(multi)
+// @Param        tag_match     query  string  false  "any (default) or all of the tags"  Enums(any, all)
+// @Param        currency      query  string  false  "Convert to currency (ISO 4217) using monthly exchange rates"
+// @Param        mode          query  string  false  "charges (actual charges in period, default) or spread (cost spread evenly across months)"  Enums(charges, spread)
+// @Param        prorate       query  bool    false  "With mode=spread: charge first/last month by the fraction of days used"
+// @Param        format        query  string  false  "Export format (default: JSON or from Accept); XLSX adds a Totals sheet from the monthly breakdown"  Enums(json, csv, xlsx)
 // @Success      200  {object}  TotalResponse
 // @Failure      400  {object}  map[string]string
 // @Failure      500  {object}  map[string]string
 // @Router       /subscriptions/total [get]
 func (h *AggregateRoutes) total(w http.ResponseWriter, r *http.Request) {
 	q := r.URL.Query()
-	fromStr := q.Get("from")
-	toStr := q.Get("to")
+	fromStr, toStr, f, msg := parseTotalQuery(q)
+	if msg != "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": msg})
+		return
+	}
+	format, err := exportFormat(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	totals, err := h.svc.Total(r.Context(), fromStr, toStr, f)
+	if err != nil {
+		writeTotalError(w, err)
+		return
+	}
+	resp := TotalResponse{
+		From:      fromStr,
+		To:        toStr,
+		Mode:      f.Mode,
+		Prorate:   f.Prorate,
+		ServiceID: f.ServiceID,
+		Totals:    toCurrencyTotalDTOs(totals),
+	}
+	if cur := q.Get("currency"); cur != "" {
+		conv, err := h.svc.ConvertedTotal(r.Context(), fromStr, toStr, f, cur)
+		if err != nil {
+			writeTotalError(w, err)
+			return
+		}
+		resp.Converted = toConvertedDTO(conv)
+	}
+	if f.UserID != nil {
+		s := f.UserID.String()
+		resp.UserID = &s
+	}
+	if f.ServiceName != nil {
+		resp.ServiceName = f.ServiceName
+	}
+	if format != "" {
+		h.export(w, r, format, "total", fromStr, toStr, f, nil, func(x export.Writer) error {
+			if err := x.Sheet("Total", "scope", "currency", "total"); err != nil {
+				return err
+			}
+			if err := writeCurrencyRows(x, "total", totals); err != nil {
+				return err
+			}
+			if resp.Converted != nil {
+				return x.Row("converted", resp.Converted.Currency, export.Decimal(resp.Converted.Total))
+			}
+			return nil
+		})
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// @Summary      Totals grouped by service, user and/or month
+// @Description  Groups are computed per currency and sorted by total (desc). With top=N only N largest groups per currency are returned, the rest are merged into an "other" group.
+// @Tags         subscriptions
+// @Produce      json
+// @Produce      text/csv
+// @Produce      application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Param        from          query  string  true   "YYYY-MM"
+// @Param        to            query  string  true   "YYYY-MM"
+// @Param        group_by      query  string  true   "Comma-separated: service_name, user_id, month"
+// @Param        top           query  int     false  "Keep N largest groups per currency"
+// @Param        user_id       query  string  false  "User UUID"
+// @Param        service_name  query  string  false  "Service filter (ILIKE)"
+// @Param        service_id    query  string  false  "Exact catalog service UUID"
+// @Param        tag           query  []string  false  "Filter by tags (repeat or comma-separated)"  collectionFormat(multi)
+// @Param        tag_match     query  string  false  "any (default) or all of the tags"  Enums(any, all)
+// @Param        mode          query  string  false  "charges (default) or spread"  Enums(charges, spread)
+// @Param        prorate       query  bool    false  "With mode=spread: charge first/last month by the fraction of days used"
+// @Param        format        query  string  false  "Export format (default: JSON or from Accept); XLSX adds a Totals sheet from the monthly breakdown"  Enums(json, csv, xlsx)
+// @Success      200  {object}  GroupedTotalResponse
+// @Failure      400  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /subscriptions/total/grouped [get]
+func (h *AggregateRoutes) grouped(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	fromStr, toStr, f, msg := parseTotalQuery(q)
+	if msg != "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": msg})
+		return
+	}
+	var groupBy []string
+	for _, v := range q["group_by"] {
+		for _, g := range strings.Split(v, ",") {
+			if g = strings.TrimSpace(g); g != "" {
+				groupBy = append(groupBy, g)
+			}
+		}
+	}
+	top := 0
+	if v := q.Get("top"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid top"})
+			return
+		}
+		top = n
+	}
+
+	format, err := exportFormat(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	res, err := h.svc.GroupedTotal(r.Context(), fromStr, toStr, f, groupBy, top)
+	if err != nil {
+		writeTotalError(w, err)
+		return
+	}
+	resp := GroupedTotalResponse{
+		From:        fromStr,
+		To:          toStr,
+		Mode:        f.Mode,
+		Prorate:     f.Prorate,
+		ServiceName: f.ServiceName,
+		ServiceID:   f.ServiceID,
+		GroupBy:     groupBy,
+		Top:         top,
+		Groups:      make([]GroupTotalDTO, 0, len(res.Groups)),
+		GrandTotals: toCurrencyTotalDTOs(res.GrandTotals),
+	}
+	if f.UserID != nil {
+		s := f.UserID.String()
+		resp.UserID = &s
+	}
+	for _, g := range res.Groups {
+		resp.Groups = append(resp.Groups, GroupTotalDTO{
+			Keys:     g.Keys,
+			Currency: g.Currency,
+			Total:    g.Total,
+			Other:    g.Other,
+			Groups:   g.Groups,
+		})
+	}
+	if format != "" {
+		h.export(w, r, format, "total-grouped", fromStr, toStr, f, nil, func(x export.Writer) error {
+			return writeGroupedSheet(x, groupBy, res)
+		})
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// @Summary      Monthly spend breakdown
+// @Tags         subscriptions
+// @Produce      json
+// @Produce      text/csv
+// @Produce      application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Param        from          query  string  true   "YYYY-MM"
+// @Param        to            query  string  true   "YYYY-MM"
+// @Param        user_id       query  string  false  "User UUID"
+// @Param        service_name  query  string  false  "Service filter (ILIKE)"
+// @Param        service_id    query  string  false  "Exact catalog service UUID"
+// @Param        tag           query  []string  false  "Filter by tags (repeat or comma-separated)"  collectionFormat(multi)
+// @Param        tag_match     query  string  false  "any (default) or all of the tags"  Enums(any, all)
+// @Param        mode          query  string  false  "charges (default) or spread"  Enums(charges, spread)
+// @Param        prorate       query  bool    false  "With mode=spread: charge first/last month by the fraction of days used"
+// @Param        format        query  string  false  "Export format (default: JSON or from Accept); XLSX adds a Totals sheet from the monthly breakdown"  Enums(json, csv, xlsx)
+// @Success      200  {object}  BreakdownResponse
+// @Failure      400  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /subscriptions/breakdown [get]
+func (h *AggregateRoutes) breakdown(w http.ResponseWriter, r *http.Request) {
+	fromStr, toStr, f, msg := parseTotalQuery(r.URL.Query())
+	if msg != "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": msg})
+		return
+	}
+	format, err := exportFormat(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	months, err := h.svc.Breakdown(r.Context(), fromStr, toStr, f)
+	if err != nil {
+		writeTotalError(w, err)
+		return
+	}
+	resp := BreakdownResponse{
+		From:        fromStr,
+		To:          toStr,
+		Mode:        f.Mode,
+		Prorate:     f.Prorate,
+		ServiceName: f.ServiceName,
+		ServiceID:   f.ServiceID,
+		Months:      make([]MonthBreakdownDTO, 0, len(months)),
+	}
+	if f.UserID != nil {
+		s := f.UserID.String()
+		resp.UserID = &s
+	}
+	for _, m := range months {
+		resp.Months = append(resp.Months, MonthBreakdownDTO{
+			Month:               m.Month,
+			ActiveSubscriptions: m.ActiveSubscriptions,
+			Totals:              toCurrencyTotalDTOs(m.Totals),
+		})
+	}
+	if format != "" {
+		h.export(w, r, format, "breakdown", fromStr, toStr, f, months, func(x export.Writer) error {
+			if err := x.Sheet("Breakdown", "month", "currency", "amount", "active_subscriptions"); err != nil {
+				return err
+			}
+			for _, m := range months {
+				for _, t := range m.Totals {
+					if err := x.Row(m.Month, t.Currency, export.Decimal(t.Total), m.ActiveSubscriptions); err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		})
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// @Summary      Spend forecast
+// @Description  Projects charges for the next N months starting with the current one. Known end dates, scheduled price changes and pauses are taken into account; open-ended subscriptions are assumed to continue, which lowers the confidence of the month. The open_ended filter is not accepted: the forecast reports open-ended subscriptions separately.
+// @Tags         subscriptions
+// @Produce      json
+// @Produce      text/csv
+// @Produce      application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Param        months        query  int     false  "Number of months (1..60, default 12)"
+// @Param        user_id       query  string  false  "User UUID"
+// @Param        service_name  query  string  false  "Service filter (ILIKE)"
+// @Param        service_id    query  string  false  "Exact catalog service UUID"
+// @Param        tag           query  []string  false  "Filter by tags (repeat or comma-separated)"  collectionFormat(multi)
+// @Param        tag_match     query  string  false  "any (default) or all of the tags"  Enums(any, all)
+// @Param        mode          query  string  false  "charges (default) or spread"  Enums(charges, spread)
+// @Param        prorate       query  bool    false  "With mode=spread: charge first/last month by the fraction of days used"
+// @Param        format        query  string  false  "Export format (default: JSON or from Accept); XLSX adds a Totals sheet from the monthly breakdown"  Enums(json, csv, xlsx)
+// @Success      200  {object}  ForecastResponse
+// @Failure      400  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /subscriptions/forecast [get]
+func (h *AggregateRoutes) forecast(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	f, msg := parseTotalFilter(q)
+	if msg != "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": msg})
+		return
+	}
+	n := 12
+	if v := q.Get("months"); v != "" {
+		m, err := strconv.Atoi(v)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid months"})
+			return
+		}
+		n = m
+	}
+	format, err := exportFormat(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	months, err := h.svc.Forecast(r.Context(), n, f)
+	if err != nil {
+		writeTotalError(w, err)
+		return
+	}
+	resp := ForecastResponse{
+		Months:      n,
+		Mode:        f.Mode,
+		Prorate:     f.Prorate,
+		ServiceName: f.ServiceName,
+		ServiceID:   f.ServiceID,
+		Forecast:    make([]ForecastMonthDTO, 0, len(months)),
+	}
+	if f.UserID != nil {
+		s := f.UserID.String()
+		resp.UserID = &s
+	}
+	for _, m := range months {
+		resp.Forecast = append(resp.Forecast, ForecastMonthDTO{
+			Month:                  m.Month,
+			ActiveSubscriptions:    m.ActiveSubscriptions,
+			OpenEndedSubscriptions: m.OpenEndedSubscriptions,
+			Totals:                 toCurrencyTotalDTOs(m.Totals),
+			OpenEndedTotals:        toCurrencyTotalDTOs(m.OpenEndedTotals),
+			Confidence:             m.Confidence,
+			Note:                   m.Note,
+		})
+	}
+	if format != "" {
+		breakdown := make([]domain.MonthBreakdown, 0, len(months))
+		for _, m := range months {
+			breakdown = append(breakdown, domain.MonthBreakdown{Month: m.Month, ActiveSubscriptions: m.ActiveSubscriptions, Totals: m.Totals})
+		}
+		h.export(w, r, format, "forecast", "", "", f, breakdown, func(x export.Writer) error {
+			return writeForecastSheet(x, months)
+		})
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// @Summary      Settlements for shared subscriptions
+// @Description  Who owes whom for the period: every member owes the subscription owner their share of the charges; mutual debts are netted per currency.
+// @Tags         subscriptions
+// @Produce      json
+// @Param        from     query  string  true   "YYYY-MM"
+// @Param        to       query  string  true   "YYYY-MM"
+// @Param        user_id  query  string  false  "Only settlements involving the user"
+// @Success      200  {object}  SettlementsResponse
+// @Failure      400  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /settlements [get]
+func (h *AggregateRoutes) settlements(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	fromStr, toStr := q.Get("from"), q.Get("to")
 	if fromStr == "" || toStr == "" {
 		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "from and to are required (YYYY-MM)"})
 		return
 	}
-	var uid *uuid.UUID
+	resp := SettlementsResponse{From: fromStr, To: toStr}
+	var userID *uuid.UUID
 	if s := q.Get("user_id"); s != "" {
 		u, err := uuid.Parse(s)
 		if err != nil {
 			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid user_id"})
 			return
 		}
-		uid = &u
+		userID, resp.UserID = &u, &s
 	}
-	var service *string
-	if s := q.Get("service_name"); s != "" {
-		service = &s
+	items, err := h.svc.Settlements(r.Context(), fromStr, toStr, userID)
+	if err != nil {
+		writeTotalError(w, err)
+		return
 	}
+	resp.Settlements = make([]SettlementDTO, 0, len(items))
+	for _, s := range items {
+		resp.Settlements = append(resp.Settlements, SettlementDTO{FromUser: s.From, ToUser: s.To, Currency: s.Currency, Amount: s.Amount})
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
 
-	total, err := h.svc.Total(r.Context(), fromStr, toStr, uid, service)
+// export отдаёт агрегат файлом: fill пишет основной лист, в XLSX за ним идёт лист Totals из
+// помесячной разбивки months (если months nil — она запрашивается за from–to).
+func (h *AggregateRoutes) export(w http.ResponseWriter, r *http.Request, format, name, fromStr, toStr string, f domain.TotalFilter, months []domain.MonthBreakdown, fill func(export.Writer) error) {
+	if format == export.FormatXLSX && months == nil {
+		var err error
+		if months, err = h.svc.Breakdown(r.Context(), fromStr, toStr, f); err != nil {
+			writeTotalError(w, err)
+			return
+		}
+	}
+	out, x := startExport(w, format, name)
+	err := fill(x)
+	if err == nil && format == export.FormatXLSX {
+		err = writeTotalsSheet(x, months)
+	}
+	if err == nil {
+		err = x.Close()
+	}
 	if err != nil {
-		if err.Error() == "to must be >= from" || err.Error()[:7] == "invalid" {
-			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
-		} else {
-			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		failExport(out, err, writeTotalError)
+	}
+}
+
+// writeGroupedSheet: столбцы ключей в порядке group_by, затем валюта и сумма; в конце — общие
+// итоги по валютам.
+func writeGroupedSheet(x export.Writer, groupBy []string, res domain.GroupedTotals) error {
+	header := append(append([]string{}, groupBy...), "currency", "total", "other", "groups")
+	if err := x.Sheet("Grouped", header...); err != nil {
+		return err
+	}
+	for _, g := range res.Groups {
+		row := make([]any, 0, len(header))
+		for _, key := range groupBy {
+			row = append(row, g.Keys[key])
+		}
+		row = append(row, g.Currency, export.Decimal(g.Total), g.Other, g.Groups)
+		if err := x.Row(row...); err != nil {
+			return err
 		}
-		return
 	}
-	resp := TotalResponse{
-		From:  fromStr,
-		To:    toStr,
-		Total: total,
+	for _, t := range res.GrandTotals {
+		row := make([]any, len(groupBy), len(header))
+		row[0] = "Grand total"
+		row = append(row, t.Currency, export.Decimal(t.Total))
+		if err := x.Row(row...); err != nil {
+			return err
+		}
 	}
-	if uid != nil {
-		s := uid.String()
-		resp.UserID = &s
+	return nil
+}
+
+func writeForecastSheet(x export.Writer, months []domain.ForecastMonth) error {
+	err := x.Sheet("Forecast", "month", "currency", "amount", "open_ended_amount",
+		"active_subscriptions", "open_ended_subscriptions", "confidence", "note")
+	if err != nil {
+		return err
 	}
-	if service != nil {
-		resp.ServiceName = service
+	for _, m := range months {
+		openEnded := make(map[string]string, len(m.OpenEndedTotals))
+		for _, t := range m.OpenEndedTotals {
+			openEnded[t.Currency] = t.Total
+		}
+		for _, t := range m.Totals {
+			err := x.Row(m.Month, t.Currency, export.Decimal(t.Total), export.Decimal(openEnded[t.Currency]),
+				m.ActiveSubscriptions, m.OpenEndedSubscriptions, m.Confidence, m.Note)
+			if err != nil {
+				return err
+			}
+		}
 	}
-	writeJSON(w, http.StatusOK, resp)
+	return nil
+}
+
+func toCurrencyTotalDTOs(totals []domain.CurrencyTotal) []CurrencyTotalDTO {
+	out := make([]CurrencyTotalDTO, 0, len(totals))
+	for _, t := range totals {
+		out = append(out, CurrencyTotalDTO{Currency: t.Currency, Total: t.Total})
+	}
+	return out
+}
+
+// parseTotalQuery разбирает общие параметры агрегатов: период from–to и фильтры parseTotalFilter.
+// Непустой msg — текст ошибки для ответа 400.
+func parseTotalQuery(q url.Values) (fromStr, toStr string, f domain.TotalFilter, msg string) {
+	fromStr = q.Get("from")
+	toStr = q.Get("to")
+	if fromStr == "" || toStr == "" {
+		return "", "", f, "from and to are required (YYYY-MM)"
+	}
+	if f, msg = parseTotalFilter(q); msg != "" {
+		return "", "", f, msg
+	}
+	return fromStr, toStr, f, ""
+}
+
+// parseTotalFilter разбирает фильтры агрегатов без периода: user_id, service_name, service_id,
+// tag/tag_match, mode, prorate.
+func parseTotalFilter(q url.Values) (f domain.TotalFilter, msg string) {
+	if s := q.Get("user_id"); s != "" {
+		u, err := uuid.Parse(s)
+		if err != nil {
+			return f, "invalid user_id"
+		}
+		f.UserID = &u
+	}
+	if s := q.Get("service_name"); s != "" {
+		f.ServiceName = &s
+	}
+	if s := q.Get("service_id"); s != "" {
+		id, err := uuid.Parse(s)
+		if err != nil {
+			return f, "invalid service_id"
+		}
+		f.ServiceID = &id
+	}
+	f.Tags = parseTags(q)
+	f.TagMatch = q.Get("tag_match")
+	f.Mode = q.Get("mode")
+	if f.Mode == "" {
+		f.Mode = domain.TotalModeCharges
+	}
+	if v := q.Get("prorate"); v != "" {
+		p, err := strconv.ParseBool(v)
+		if err != nil {
+			return f, "invalid prorate"
+		}
+		f.Prorate = p
+	}
+	return f, ""
+}
+
+func writeTotalError(w http.ResponseWriter, err error) {
+	if err.Error() == "to must be >= from" || strings.HasPrefix(err.Error(), "invalid") {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+	} else {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+}
+
+func toConvertedDTO(c domain.ConvertedTotal) *ConvertedTotalDTO {
+	out := &ConvertedTotalDTO{
+		Currency:     c.Currency,
+		Total:        c.Total,
+		RatesUsed:    make([]AppliedRateDTO, 0, len(c.Rates)),
+		MissingRates: make([]MissingRateDTO, 0, len(c.Missing)),
+	}
+	for _, r := range c.Rates {
+		out.RatesUsed = append(out.RatesUsed, AppliedRateDTO{Month: r.Month, Currency: r.Currency, Rate: r.Rate, RateDate: r.RateDate})
+	}
+	for _, m := range c.Missing {
+		out.MissingRates = append(out.MissingRates, MissingRateDTO{Month: m.Month, Currency: m.Currency, Amount: m.Amount})
+	}
+	return out
 }
@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"crud_ef/internal/domain"
+	"crud_ef/internal/usecase/subscription"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/google/uuid"
+)
+
+// ActorHeader — заголовок с автором изменений для журнала.
+const ActorHeader = "X-Actor"
+
+// EventDTO — запись журнала изменений; before отсутствует у create, after — у purge.
+type EventDTO struct {
+	ID             int64            `json:"id"`
+	SubscriptionID uuid.UUID        `json:"subscription_id"`
+	Action         string           `json:"action" enums:"create,update,cancel,delete,restore,price,pause,resume,members,purge"`
+	Actor          string           `json:"actor,omitempty"`
+	RequestID      string           `json:"request_id,omitempty"`
+	Before         *SubscriptionDTO `json:"before,omitempty"`
+	After          *SubscriptionDTO `json:"after,omitempty"`
+	CreatedAt      time.Time        `json:"created_at"`
+}
+
+// Audit кладёт в контекст запроса автора (заголовок X-Actor) и ID запроса от middleware.RequestID.
+func Audit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := domain.WithAudit(r.Context(), domain.Audit{
+			Actor:     strings.TrimSpace(r.Header.Get(ActorHeader)),
+			RequestID: middleware.GetReqID(r.Context()),
+		})
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+type AuditRoutes struct {
+	svc *subscription.Service
+}
+
+func NewAuditRoutes(svc *subscription.Service) *AuditRoutes {
+	return &AuditRoutes{svc: svc}
+}
+
+func (h *AuditRoutes) Register(r chi.Router) {
+	r.Get("/audit", h.list)
+}
+
+// @Summary      Change log of all subscriptions
+// @Tags         audit
+// @Produce      json
+// @Param        from    query  string  false  "From (RFC 3339 or YYYY-MM-DD, inclusive)"
+// @Param        to      query  string  false  "To (RFC 3339 exclusive, or YYYY-MM-DD inclusive)"
+// @Param        actor   query  string  false  "Actor (X-Actor header of the change)"
+// @Param        limit   query  int     false  "Limit (1..200)"
+// @Param        offset  query  int     false  "Offset"
+// @Success      200  {array}   EventDTO
+// @Failure      400  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /audit [get]
+func (h *AuditRoutes) list(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	f := domain.EventFilter{}
+	var err error
+	if f.Limit, f.Offset, err = parseLimitOffset(q, maxPageLimit); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	if v := q.Get("actor"); v != "" {
+		f.Actor = &v
+	}
+	items, err := h.svc.Audit(r.Context(), q.Get("from"), q.Get("to"), f)
+	if err != nil {
+		if strings.HasPrefix(err.Error(), "invalid") {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		} else {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		return
+	}
+	writeJSON(w, http.StatusOK, toEventDTOs(items))
+}
+
+// maxPageLimit — наибольший limit журнала изменений и корзины.
+const maxPageLimit = 200
+
+func toEventDTOs(items []domain.Event) []EventDTO {
+	out := make([]EventDTO, 0, len(items))
+	for _, e := range items {
+		d := EventDTO{
+			ID:             e.ID,
+			SubscriptionID: e.SubscriptionID,
+			Action:         e.Action,
+			Actor:          e.Actor,
+			RequestID:      e.RequestID,
+			CreatedAt:      e.CreatedAt,
+		}
+		if e.Before != nil {
+			b := toDTO(*e.Before)
+			d.Before = &b
+		}
+		if e.After != nil {
+			a := toDTO(*e.After)
+			d.After = &a
+		}
+		out = append(out, d)
+	}
+	return out
+}
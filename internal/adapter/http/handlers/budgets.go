@@ -0,0 +1,326 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"crud_ef/internal/domain"
+	"crud_ef/internal/usecase/budget"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+type BudgetDTO struct {
+	ID           uuid.UUID  `json:"id"`
+	Scope        string     `json:"scope" enums:"user,service,global"`
+	UserID       *uuid.UUID `json:"user_id,omitempty"`
+	ServiceName  *string    `json:"service_name,omitempty"`
+	MonthlyLimit string     `json:"monthly_limit"`
+	Currency     string     `json:"currency"`
+	Mode         string     `json:"mode" enums:"charges,spread"`
+	Thresholds   []int      `json:"thresholds" example:"80,100"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+// BudgetRequest: user_id обязателен для scope=user, service_name — для scope=service.
+// thresholds — проценты от лимита, по умолчанию 80 и 100.
+type BudgetRequest struct {
+	Scope        string  `json:"scope" enums:"user,service,global"`
+	UserID       *string `json:"user_id,omitempty"`
+	ServiceName  *string `json:"service_name,omitempty"`
+	MonthlyLimit string  `json:"monthly_limit" example:"1500.00"`
+	Currency     string  `json:"currency,omitempty"`
+	Mode         string  `json:"mode,omitempty" enums:"charges,spread"`
+	Thresholds   []int   `json:"thresholds,omitempty" example:"80,100"`
+}
+
+type BudgetStatusDTO struct {
+	BudgetID          uuid.UUID        `json:"budget_id"`
+	Month             string           `json:"month"`
+	MonthlyLimit      string           `json:"monthly_limit"`
+	Currency          string           `json:"currency"`
+	Spent             string           `json:"spent"`
+	Remaining         string           `json:"remaining"`
+	Percent           float64          `json:"percent"`
+	ThresholdsReached []int            `json:"thresholds_reached"`
+	OverLimit         bool             `json:"over_limit"`
+	MissingRates      []MissingRateDTO `json:"missing_rates"`
+}
+
+type BudgetAlertDTO struct {
+	BudgetID       uuid.UUID  `json:"budget_id"`
+	Month          string     `json:"month"`
+	Threshold      int        `json:"threshold"`
+	Spent          string     `json:"spent"`
+	MonthlyLimit   string     `json:"monthly_limit"`
+	Currency       string     `json:"currency"`
+	SubscriptionID *uuid.UUID `json:"subscription_id,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+type BudgetRoutes struct {
+	svc *budget.Service
+}
+
+func NewBudgetRoutes(svc *budget.Service) *BudgetRoutes {
+	return &BudgetRoutes{svc: svc}
+}
+
+func (h *BudgetRoutes) Register(r chi.Router) {
+	r.Route("/budgets", func(r chi.Router) {
+		r.Post("/", h.create)
+		r.Get("/", h.list)
+		r.Get("/{id}", h.get)
+		r.Put("/{id}", h.update)
+		r.Delete("/{id}", h.delete)
+		r.Get("/{id}/status", h.status)
+		r.Get("/{id}/alerts", h.alerts)
+	})
+}
+
+// @Summary      Create budget
+// @Tags         budgets
+// @Accept       json
+// @Produce      json
+// @Param        request  body  BudgetRequest  true  "payload"
+// @Success      201  {object}  BudgetDTO
+// @Failure      400  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /budgets [post]
+func (h *BudgetRoutes) create(w http.ResponseWriter, r *http.Request) {
+	in, ok := decodeBudget(w, r)
+	if !ok {
+		return
+	}
+	b, err := h.svc.Create(r.Context(), in)
+	if err != nil {
+		writeBudgetError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, toBudgetDTO(b))
+}
+
+// @Summary      List budgets
+// @Tags         budgets
+// @Produce      json
+// @Success      200  {array}   BudgetDTO
+// @Failure      500  {object}  map[string]string
+// @Router       /budgets [get]
+func (h *BudgetRoutes) list(w http.ResponseWriter, r *http.Request) {
+	items, err := h.svc.List(r.Context())
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	out := make([]BudgetDTO, 0, len(items))
+	for _, b := range items {
+		out = append(out, toBudgetDTO(b))
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+// @Summary      Get budget by id
+// @Tags         budgets
+// @Produce      json
+// @Param        id   path  string  true  "Budget ID"
+// @Success      200  {object}  BudgetDTO
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Router       /budgets/{id} [get]
+func (h *BudgetRoutes) get(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid id"})
+		return
+	}
+	b, err := h.svc.Get(r.Context(), id)
+	if err != nil {
+		writeBudgetError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, toBudgetDTO(b))
+}
+
+// @Summary      Replace budget settings
+// @Tags         budgets
+// @Accept       json
+// @Produce      json
+// @Param        id       path  string         true  "Budget ID"
+// @Param        request  body  BudgetRequest  true  "payload"
+// @Success      200  {object}  BudgetDTO
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Router       /budgets/{id} [put]
+func (h *BudgetRoutes) update(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid id"})
+		return
+	}
+	in, ok := decodeBudget(w, r)
+	if !ok {
+		return
+	}
+	b, err := h.svc.Update(r.Context(), id, in)
+	if err != nil {
+		writeBudgetError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, toBudgetDTO(b))
+}
+
+// @Summary      Delete budget
+// @Tags         budgets
+// @Param        id   path  string  true  "Budget ID"
+// @Success      204  {object}  map[string]string
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Router       /budgets/{id} [delete]
+func (h *BudgetRoutes) delete(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid id"})
+		return
+	}
+	ok, err := h.svc.Delete(r.Context(), id)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "not found"})
+		return
+	}
+	writeJSON(w, http.StatusNoContent, map[string]string{"status": "deleted"})
+}
+
+// @Summary      Budget status for a month
+// @Description  Compares the monthly limit with the spend computed like /subscriptions/total for the budget scope, converted to the budget currency. A user budget includes shares of subscriptions the user is a member of; a service budget matches the whole service name, case-insensitively.
+// @Tags         budgets
+// @Produce      json
+// @Param        id     path   string  true   "Budget ID"
+// @Param        month  query  string  false  "YYYY-MM (default: current month)"
+// @Success      200  {object}  BudgetStatusDTO
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /budgets/{id}/status [get]
+func (h *BudgetRoutes) status(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid id"})
+		return
+	}
+	st, err := h.svc.Status(r.Context(), id, r.URL.Query().Get("month"))
+	if err != nil {
+		writeBudgetError(w, err)
+		return
+	}
+	out := BudgetStatusDTO{
+		BudgetID:          st.Budget.ID,
+		Month:             st.Month,
+		MonthlyLimit:      st.Budget.MonthlyLimit,
+		Currency:          st.Budget.Currency,
+		Spent:             st.Spent,
+		Remaining:         st.Remaining,
+		Percent:           st.Percent,
+		ThresholdsReached: st.Reached,
+		OverLimit:         st.Over,
+		MissingRates:      make([]MissingRateDTO, 0, len(st.Missing)),
+	}
+	for _, m := range st.Missing {
+		out.MissingRates = append(out.MissingRates, MissingRateDTO{Month: m.Month, Currency: m.Currency, Amount: m.Amount})
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+// @Summary      Fired budget alerts
+// @Tags         budgets
+// @Produce      json
+// @Param        id   path  string  true  "Budget ID"
+// @Success      200  {array}   BudgetAlertDTO
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Router       /budgets/{id}/alerts [get]
+func (h *BudgetRoutes) alerts(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid id"})
+		return
+	}
+	items, err := h.svc.Alerts(r.Context(), id)
+	if err != nil {
+		writeBudgetError(w, err)
+		return
+	}
+	out := make([]BudgetAlertDTO, 0, len(items))
+	for _, a := range items {
+		out = append(out, BudgetAlertDTO{
+			BudgetID:       a.BudgetID,
+			Month:          a.Month,
+			Threshold:      a.Threshold,
+			Spent:          a.Spent,
+			MonthlyLimit:   a.MonthlyLimit,
+			Currency:       a.Currency,
+			SubscriptionID: a.SubscriptionID,
+			CreatedAt:      a.CreatedAt,
+		})
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func decodeBudget(w http.ResponseWriter, r *http.Request) (domain.BudgetInput, bool) {
+	var req BudgetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON"})
+		return domain.BudgetInput{}, false
+	}
+	in := domain.BudgetInput{
+		Scope:        req.Scope,
+		ServiceName:  req.ServiceName,
+		MonthlyLimit: req.MonthlyLimit,
+		Currency:     req.Currency,
+		Mode:         req.Mode,
+		Thresholds:   req.Thresholds,
+	}
+	if req.UserID != nil {
+		uid, err := uuid.Parse(*req.UserID)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid user_id"})
+			return domain.BudgetInput{}, false
+		}
+		in.UserID = &uid
+	}
+	return in, true
+}
+
+func writeBudgetError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, domain.ErrNotFound):
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "not found"})
+	case strings.HasPrefix(err.Error(), "invalid"):
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+	default:
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+}
+
+func toBudgetDTO(b domain.Budget) BudgetDTO {
+	return BudgetDTO{
+		ID:           b.ID,
+		Scope:        b.Scope,
+		UserID:       b.UserID,
+		ServiceName:  b.ServiceName,
+		MonthlyLimit: b.MonthlyLimit,
+		Currency:     b.Currency,
+		Mode:         b.Mode,
+		Thresholds:   b.Thresholds,
+		CreatedAt:    b.CreatedAt,
+		UpdatedAt:    b.UpdatedAt,
+	}
+}
@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+
+	"crud_ef/internal/adapter/ical"
+	"crud_ef/internal/usecase/calendar"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// CalendarTokenDTO — секретный токен ленты; url — путь для подписки в календаре.
+type CalendarTokenDTO struct {
+	Token string `json:"token"`
+	URL   string `json:"url" example:"/users/0b6f.../calendar.ics?token=..."`
+}
+
+type CalendarRoutes struct {
+	svc *calendar.Service
+}
+
+func NewCalendarRoutes(svc *calendar.Service) *CalendarRoutes {
+	return &CalendarRoutes{svc: svc}
+}
+
+func (h *CalendarRoutes) Register(r chi.Router) {
+	r.Get("/users/{user_id}/calendar.ics", h.feed)
+	r.Post("/users/{user_id}/calendar/token", h.issueToken)
+	r.Delete("/users/{user_id}/calendar/token", h.revokeToken)
+}
+
+// @Summary      iCalendar feed of upcoming charges
+// @Description  RFC 5545 feed for calendar apps: one recurring all-day event per active, paused or upcoming subscription of the user, repeating with the billing period from the first paid charge until the end date. Charges inside pauses are excluded (EXDATE); an open-ended pause ends the series. The summary shows the price; alarms fire the day before a charge and notice_days before it. Authorized by the secret token from POST /users/{user_id}/calendar/token instead of API auth.
+// @Tags         calendar
+// @Produce      text/calendar
+// @Param        user_id  path   string  true  "User UUID"
+// @Param        token    query  string  true  "Secret calendar token"
+// @Success      200  {string}  string
+// @Failure      400  {object}  map[string]string
+// @Failure      403  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /users/{user_id}/calendar.ics [get]
+func (h *CalendarRoutes) feed(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(chi.URLParam(r, "user_id"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid user_id"})
+		return
+	}
+	events, err := h.svc.Feed(r.Context(), userID, r.URL.Query().Get("token"))
+	if errors.Is(err, calendar.ErrInvalidToken) {
+		writeJSON(w, http.StatusForbidden, map[string]string{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	w.Header().Set("Content-Type", ical.ContentType)
+	w.Header().Set("Content-Disposition", `inline; filename="calendar.ics"`)
+	w.Header().Set("Cache-Control", "private, max-age=300")
+	_ = ical.Write(w, "Подписки", events)
+}
+
+// @Summary      Issue calendar token
+// @Description  Creates a new secret token for the user's calendar feed; the previous token stops working. The token is shown only once.
+// @Tags         calendar
+// @Produce      json
+// @Param        user_id  path  string  true  "User UUID"
+// @Success      201  {object}  CalendarTokenDTO
+// @Failure      400  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /users/{user_id}/calendar/token [post]
+func (h *CalendarRoutes) issueToken(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(chi.URLParam(r, "user_id"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid user_id"})
+		return
+	}
+	token, err := h.svc.IssueToken(r.Context(), userID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusCreated, CalendarTokenDTO{
+		Token: token,
+		URL:   "/users/" + userID.String() + "/calendar.ics?token=" + url.QueryEscape(token),
+	})
+}
+
+// @Summary      Revoke calendar token
+// @Tags         calendar
+// @Param        user_id  path  string  true  "User UUID"
+// @Success      204  {object}  map[string]string
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Router       /users/{user_id}/calendar/token [delete]
+func (h *CalendarRoutes) revokeToken(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(chi.URLParam(r, "user_id"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid user_id"})
+		return
+	}
+	ok, err := h.svc.RevokeToken(r.Context(), userID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "not found"})
+		return
+	}
+	writeJSON(w, http.StatusNoContent, map[string]string{"status": "deleted"})
+}
@@ -0,0 +1,219 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"crud_ef/internal/domain"
+	"crud_ef/internal/usecase/catalog"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// CatalogServiceDTO — запись каталога; subscriptions — число привязанных подписок.
+type CatalogServiceDTO struct {
+	ID            uuid.UUID `json:"id"`
+	Name          string    `json:"name" example:"Yandex Plus"`
+	Aliases       []string  `json:"aliases" example:"Яндекс Плюс"`
+	Category      *string   `json:"category,omitempty" example:"media"`
+	VendorURL     *string   `json:"vendor_url,omitempty" example:"https://plus.yandex.ru"`
+	Subscriptions int       `json:"subscriptions"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+type CatalogServiceRequest struct {
+	Name      string   `json:"name"`
+	Aliases   []string `json:"aliases,omitempty"`
+	Category  *string  `json:"category,omitempty"`
+	VendorURL *string  `json:"vendor_url,omitempty"`
+}
+
+type CatalogRoutes struct {
+	svc *catalog.Service
+}
+
+func NewCatalogRoutes(svc *catalog.Service) *CatalogRoutes {
+	return &CatalogRoutes{svc: svc}
+}
+
+func (h *CatalogRoutes) Register(r chi.Router) {
+	r.Route("/services", func(r chi.Router) {
+		r.Post("/", h.create)
+		r.Get("/", h.list)
+		r.Get("/{id}", h.get)
+		r.Put("/{id}", h.update)
+		r.Delete("/{id}", h.delete)
+	})
+}
+
+// @Summary      Create catalog service
+// @Description  Subscriptions without a catalog link whose service_name matches the name or an alias are linked automatically.
+// @Tags         services
+// @Accept       json
+// @Produce      json
+// @Param        request  body  CatalogServiceRequest  true  "payload"
+// @Success      201  {object}  CatalogServiceDTO
+// @Failure      400  {object}  map[string]string
+// @Failure      409  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /services [post]
+func (h *CatalogRoutes) create(w http.ResponseWriter, r *http.Request) {
+	var req CatalogServiceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON"})
+		return
+	}
+	c, err := h.svc.Create(r.Context(), toCatalogInput(req))
+	if err != nil {
+		writeCatalogError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, toCatalogDTO(c))
+}
+
+// @Summary      List catalog services
+// @Tags         services
+// @Produce      json
+// @Param        q         query  string  false  "Search in name and aliases"
+// @Param        category  query  string  false  "Category"
+// @Success      200  {array}   CatalogServiceDTO
+// @Failure      500  {object}  map[string]string
+// @Router       /services [get]
+func (h *CatalogRoutes) list(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	var f domain.CatalogFilter
+	if v := q.Get("q"); v != "" {
+		f.Query = &v
+	}
+	if v := q.Get("category"); v != "" {
+		f.Category = &v
+	}
+	items, err := h.svc.List(r.Context(), f)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	out := make([]CatalogServiceDTO, 0, len(items))
+	for _, c := range items {
+		out = append(out, toCatalogDTO(c))
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+// @Summary      Get catalog service by id
+// @Tags         services
+// @Produce      json
+// @Param        id   path  string  true  "Service ID"
+// @Success      200  {object}  CatalogServiceDTO
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Router       /services/{id} [get]
+func (h *CatalogRoutes) get(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid id"})
+		return
+	}
+	c, err := h.svc.Get(r.Context(), id)
+	if err != nil {
+		writeCatalogError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, toCatalogDTO(c))
+}
+
+// @Summary      Replace catalog service
+// @Tags         services
+// @Accept       json
+// @Produce      json
+// @Param        id       path  string                 true  "Service ID"
+// @Param        request  body  CatalogServiceRequest  true  "payload"
+// @Success      200  {object}  CatalogServiceDTO
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      409  {object}  map[string]string
+// @Router       /services/{id} [put]
+func (h *CatalogRoutes) update(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid id"})
+		return
+	}
+	var req CatalogServiceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON"})
+		return
+	}
+	c, err := h.svc.Update(r.Context(), id, toCatalogInput(req))
+	if err != nil {
+		writeCatalogError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, toCatalogDTO(c))
+}
+
+// @Summary      Delete catalog service
+// @Description  Linked subscriptions are kept and lose their service_id.
+// @Tags         services
+// @Param        id   path  string  true  "Service ID"
+// @Success      204  {object}  map[string]string
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Router       /services/{id} [delete]
+func (h *CatalogRoutes) delete(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid id"})
+		return
+	}
+	ok, err := h.svc.Delete(r.Context(), id)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "not found"})
+		return
+	}
+	writeJSON(w, http.StatusNoContent, map[string]string{"status": "deleted"})
+}
+
+func writeCatalogError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, domain.ErrNotFound):
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "not found"})
+	case errors.Is(err, domain.ErrAlreadyExists):
+		writeJSON(w, http.StatusConflict, map[string]string{"error": "service with this name already exists"})
+	case strings.HasPrefix(err.Error(), "invalid"):
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+	default:
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+}
+
+func toCatalogInput(req CatalogServiceRequest) domain.CatalogServiceInput {
+	return domain.CatalogServiceInput{
+		Name:      req.Name,
+		Aliases:   req.Aliases,
+		Category:  req.Category,
+		VendorURL: req.VendorURL,
+	}
+}
+
+func toCatalogDTO(c domain.CatalogService) CatalogServiceDTO {
+	return CatalogServiceDTO{
+		ID:            c.ID,
+		Name:          c.Name,
+		Aliases:       c.Aliases,
+		Category:      c.Category,
+		VendorURL:     c.VendorURL,
+		Subscriptions: c.Subscriptions,
+		CreatedAt:     c.CreatedAt,
+		UpdatedAt:     c.UpdatedAt,
+	}
+}
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"crud_ef/internal/domain"
+
+	"github.com/google/uuid"
+)
+
+// cursorJSON — содержимое курсора; клиенту он отдаётся непрозрачной строкой base64url.
+type cursorJSON struct {
+	Sort string    `json:"s"`
+	Key  string    `json:"k"`
+	ID   uuid.UUID `json:"id"`
+}
+
+func encodeCursor(c domain.ListCursor) string {
+	b, _ := json.Marshal(cursorJSON{Sort: c.Sort, Key: c.Key, ID: c.ID})
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeCursor(s string) (*domain.ListCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errors.New("invalid cursor")
+	}
+	var c cursorJSON
+	if err := json.Unmarshal(b, &c); err != nil || c.ID == uuid.Nil || c.Sort == "" {
+		return nil, errors.New("invalid cursor")
+	}
+	return &domain.ListCursor{Sort: c.Sort, Key: c.Key, ID: c.ID}, nil
+}
+
+// parseLimitOffset разбирает limit (1..max, по умолчанию 50) и offset (>= 0); некорректное
+// значение — ошибка, а не значение по умолчанию.
+func parseLimitOffset(q url.Values, maxLimit int) (limit, offset int, err error) {
+	limit = 50
+	if v := q.Get("limit"); v != "" {
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > maxLimit {
+			return 0, 0, errors.New("invalid limit (1.." + strconv.Itoa(maxLimit) + ")")
+		}
+	}
+	if v := q.Get("offset"); v != "" {
+		offset, err = strconv.Atoi(v)
+		if err != nil || offset < 0 {
+			return 0, 0, errors.New("invalid offset")
+		}
+	}
+	return limit, offset, nil
+}
+
+// nextLink — ссылка на следующую страницу для заголовка Link: тот же запрос с cursor вместо offset.
+func nextLink(r *http.Request, cursor string) string {
+	q := r.URL.Query()
+	q.Del("offset")
+	q.Set("cursor", cursor)
+	return "<" + r.URL.Path + "?" + q.Encode() + `>; rel="next"`
+}
@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"crud_ef/internal/domain"
+	"crud_ef/internal/usecase/subscription"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	id := uuid.MustParse("6f1c1a3e-2b7d-4c1e-9a53-0f2d8c7b1e44")
+	tests := []domain.ListCursor{
+		{Sort: "created_at:desc", Key: "2025-03-01T10:00:00.123456Z", ID: id},
+		{Sort: "end_month:asc", Key: "infinity", ID: id},
+		{Sort: "price:asc", Key: "9.99", ID: id},
+		{Sort: "end_month:desc", Key: "", ID: id},
+		{Sort: "service_name:asc", Key: "Яндекс Плюс & co", ID: id},
+	}
+	for _, want := range tests {
+		t.Run(want.Sort, func(t *testing.T) {
+			s := encodeCursor(want)
+			if url.QueryEscape(s) != s {
+				t.Errorf("cursor %q is not URL-safe", s)
+			}
+			got, err := decodeCursor(s)
+			if err != nil {
+				t.Fatalf("decodeCursor: %v", err)
+			}
+			if *got != want {
+				t.Errorf("got %+v, want %+v", *got, want)
+			}
+		})
+	}
+}
+
+func TestDecodeCursorRejects(t *testing.T) {
+	valid := encodeCursor(domain.ListCursor{Sort: "created_at:desc", Key: "k", ID: uuid.New()})
+	b64 := func(s string) string { return base64.RawURLEncoding.EncodeToString([]byte(s)) }
+	tests := []struct {
+		name   string
+		cursor string
+	}{
+		{"not base64", "!!!"},
+		{"padded base64", valid + "="},
+		{"truncated", valid[:len(valid)-3]},
+		{"not json", b64("created_at:desc|k|id")},
+		{"no id", b64(`{"s":"created_at:desc","k":"k"}`)},
+		{"nil id", b64(`{"s":"created_at:desc","k":"k","id":"00000000-0000-0000-0000-000000000000"}`)},
+		{"bad id", b64(`{"s":"created_at:desc","k":"k","id":"42"}`)},
+		{"no sort", b64(`{"k":"k","id":"` + uuid.NewString() + `"}`)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if c, err := decodeCursor(tt.cursor); err == nil {
+				t.Errorf("decodeCursor(%q) = %+v, want error", tt.cursor, *c)
+			}
+		})
+	}
+}
+
+// Отклонённый курсор не доходит до репозитория, поэтому сервису он не нужен.
+func TestListRejectsCursor(t *testing.T) {
+	h := NewSubscriptionRoutes(subscription.NewService(nil), SubscriptionOptions{})
+	byPrice := encodeCursor(domain.ListCursor{Sort: "price:asc", Key: "9.99", ID: uuid.New()})
+	badKey := func(sort, key string) string {
+		return encodeCursor(domain.ListCursor{Sort: sort, Key: key, ID: uuid.New()})
+	}
+	tests := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{"tampered", "cursor=" + byPrice[:len(byPrice)-2] + "xx", `"invalid cursor"`},
+		{"foreign sort", "cursor=" + byPrice, "issued for sort price:asc"},
+		{"foreign direction", "sort=price:desc&cursor=" + byPrice, "issued for sort price:asc"},
+		{"with offset", "sort=price:asc&offset=10&cursor=" + byPrice, "cannot be combined with cursor"},
+		{"bad timestamp key", "cursor=" + badKey("created_at:desc", "x"), `"invalid cursor"`},
+		{"date key", "sort=updated_at&cursor=" + badKey("updated_at:asc", "2025-03-01"), `"invalid cursor"`},
+		{"bad price key", "sort=price&cursor=" + badKey("price:asc", "9.99; DROP"), `"invalid cursor"`},
+		{"bad date key", "sort=start_month&cursor=" + badKey("start_month:asc", "2025-13-01"), `"invalid cursor"`},
+		{"nul in name key", "sort=service_name&cursor=" + badKey("service_name:asc", "a\x00b"), `"invalid cursor"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			h.list(w, httptest.NewRequest(http.MethodGet, "/subscriptions?"+tt.query, nil))
+			if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), tt.want) {
+				t.Errorf("got %d %s, want 400 with %s", w.Code, w.Body, tt.want)
+			}
+		})
+	}
+}
+
+func TestPagesRejectLimitOffset(t *testing.T) {
+	svc := subscription.NewService(nil)
+	r := chi.NewRouter()
+	NewSubscriptionRoutes(svc, SubscriptionOptions{}).Register(r)
+	NewAuditRoutes(svc).Register(r)
+	paths := []string{"/subscriptions/trash", "/subscriptions/" + uuid.NewString() + "/history", "/audit"}
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"limit=0", "invalid limit (1..200)"},
+		{"limit=201", "invalid limit (1..200)"},
+		{"limit=ten", "invalid limit (1..200)"},
+		{"offset=-1", "invalid offset"},
+		{"offset=x", "invalid offset"},
+	}
+	for _, p := range paths {
+		for _, tt := range tests {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, p+"?"+tt.query, nil))
+			if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), tt.want) {
+				t.Errorf("%s?%s: got %d %s, want 400 with %s", p, tt.query, w.Code, w.Body, tt.want)
+			}
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// etag — сильный ETag версии подписки.
+func etag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// ifMatch разбирает If-Match в ожидаемую версию. nil — проверка не нужна (заголовка нет или *).
+// Ответ уже записан, если ok == false: 428 без обязательного заголовка, 412 для ETag, который
+// не может совпасть (слабый или чужой), 400 для нескольких ETag.
+func ifMatch(w http.ResponseWriter, r *http.Request, required bool) (*int, bool) {
+	v := strings.TrimSpace(r.Header.Get("If-Match"))
+	if v == "" {
+		if required {
+			writeJSON(w, http.StatusPreconditionRequired, map[string]string{"error": "If-Match header is required"})
+			return nil, false
+		}
+		return nil, true
+	}
+	if v == "*" {
+		return nil, true
+	}
+	if strings.Contains(v, ",") {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid If-Match (single ETag expected)"})
+		return nil, false
+	}
+	version, err := strconv.Atoi(strings.Trim(v, `"`))
+	if err != nil || !strings.HasPrefix(v, `"`) || !strings.HasSuffix(v, `"`) {
+		writePreconditionFailed(w)
+		return nil, false
+	}
+	return &version, true
+}
+
+// noneMatch сообщает, совпадает ли If-None-Match с текущим ETag (слабое сравнение).
+func noneMatch(r *http.Request, current string) bool {
+	v := r.Header.Get("If-None-Match")
+	if v == "" {
+		return false
+	}
+	for _, t := range strings.Split(v, ",") {
+		t = strings.TrimPrefix(strings.TrimSpace(t), "W/")
+		if t == "*" || t == current {
+			return true
+		}
+	}
+	return false
+}
+
+func writePreconditionFailed(w http.ResponseWriter) {
+	writeJSON(w, http.StatusPreconditionFailed, map[string]string{"error": "subscription was modified (ETag mismatch)"})
+}
@@ -0,0 +1,205 @@
+package handlers
+
+import (
+	"errors"
+	"mime"
+	"net/http"
+	"strings"
+
+	"crud_ef/internal/adapter/export"
+	"crud_ef/internal/domain"
+)
+
+// exportFormat выбирает формат ответа: параметр format, иначе заголовок Accept.
+// Пустая строка — обычный JSON.
+func exportFormat(r *http.Request) (string, error) {
+	switch v := r.URL.Query().Get("format"); v {
+	case "json":
+		return "", nil
+	case export.FormatCSV, export.FormatXLSX:
+		return v, nil
+	case "":
+	default:
+		return "", errors.New("invalid format (json|csv|xlsx)")
+	}
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		mt, _, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		switch mt {
+		case "text/csv":
+			return export.FormatCSV, nil
+		case export.ContentTypeXLSX:
+			return export.FormatXLSX, nil
+		case "application/json", "*/*":
+			return "", nil
+		}
+	}
+	return "", nil
+}
+
+// exportResponse запоминает, ушли ли клиенту байты выгрузки: до этого ошибку ещё можно
+// вернуть обычным JSON-ответом.
+type exportResponse struct {
+	http.ResponseWriter
+	written bool
+}
+
+func (e *exportResponse) Write(p []byte) (int, error) {
+	e.written = true
+	return e.ResponseWriter.Write(p)
+}
+
+// startExport выставляет заголовки вложения name.csv / name.xlsx и возвращает писатель формата.
+func startExport(w http.ResponseWriter, format, name string) (*exportResponse, export.Writer) {
+	out := &exportResponse{ResponseWriter: w}
+	if format == export.FormatXLSX {
+		w.Header().Set("Content-Type", export.ContentTypeXLSX)
+		w.Header().Set("Content-Disposition", `attachment; filename="`+name+`.xlsx"`)
+		return out, export.NewXLSX(out)
+	}
+	w.Header().Set("Content-Type", export.ContentTypeCSV)
+	w.Header().Set("Content-Disposition", `attachment; filename="`+name+`.csv"`)
+	return out, export.NewCSV(out)
+}
+
+// failExport отвечает ошибкой, если выгрузка ещё не началась, иначе обрывает соединение,
+// чтобы клиент не принял обрезанный файл за целый.
+func failExport(out *exportResponse, err error, writeErr func(http.ResponseWriter, error)) {
+	if out.written {
+		panic(http.ErrAbortHandler)
+	}
+	out.Header().Del("Content-Disposition")
+	writeErr(out.ResponseWriter, err)
+}
+
+func writeExportError(w http.ResponseWriter, err error) {
+	if strings.HasPrefix(err.Error(), "invalid") {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+	} else {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+}
+
+// exportColumns — порядок столбцов выгрузки подписок.
+var exportColumns = []string{
+	"id", "service_name", "service_id", "price", "billing_period", "billing_interval", "monthly_price",
+	"currency", "user_id", "start_month", "end_month", "trial_end_month", "status", "notice_days",
+	"tags", "created_at", "updated_at",
+}
+
+func subscriptionRow(s domain.Subscription) []any {
+	var serviceID any
+	if s.ServiceID != nil {
+		serviceID = s.ServiceID.String()
+	}
+	return []any{
+		s.ID.String(), s.ServiceName, serviceID, export.Decimal(s.Price), s.BillingPeriod, s.BillingInterval,
+		export.Decimal(s.MonthlyPrice), s.Currency, s.UserID.String(), s.StartMonth, s.EndMonth, s.TrialEndMonth,
+		s.Status, s.NoticeDays, strings.Join(s.Tags, ","), s.CreatedAt, s.UpdatedAt,
+	}
+}
+
+// currencySums накапливает суммы по валютам в порядке первого появления.
+type currencySums struct {
+	order  []string
+	totals map[string]export.Decimal
+}
+
+func (c *currencySums) add(currency string, amount export.Decimal) {
+	if c.totals == nil {
+		c.totals = map[string]export.Decimal{}
+	}
+	if _, ok := c.totals[currency]; !ok {
+		c.order = append(c.order, currency)
+	}
+	c.totals[currency] = export.AddDecimal(c.totals[currency], amount)
+}
+
+// writeTotalsSheet добавляет лист Totals: начисления по месяцам и валютам из помесячной
+// разбивки и итог по каждой валюте.
+func writeTotalsSheet(x export.Writer, months []domain.MonthBreakdown) error {
+	if err := x.Sheet("Totals", "month", "currency", "amount", "active_subscriptions"); err != nil {
+		return err
+	}
+	var sums currencySums
+	for _, m := range months {
+		for _, t := range m.Totals {
+			if err := x.Row(m.Month, t.Currency, export.Decimal(t.Total), m.ActiveSubscriptions); err != nil {
+				return err
+			}
+			sums.add(t.Currency, export.Decimal(t.Total))
+		}
+	}
+	for _, cur := range sums.order {
+		if err := x.Row("Total", cur, sums.totals[cur], nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeCurrencyRows(x export.Writer, label string, totals []domain.CurrencyTotal) error {
+	for _, t := range totals {
+		if err := x.Row(label, t.Currency, export.Decimal(t.Total)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// listTotalFilter переводит фильтр списка в фильтр помесячной разбивки для листа Totals.
+// Условия, которых разбивка не поддерживает, — ошибка: иначе итоги не совпали бы со списком.
+func listTotalFilter(f domain.ListFilter) (domain.TotalFilter, error) {
+	if len(f.UserIDs) > 1 || f.ServiceMatch == domain.ServiceMatchExact || f.MinPrice != nil || f.MaxPrice != nil ||
+		f.ActiveAt != nil || len(f.Statuses) > 0 || f.StartFrom != nil || f.StartTo != nil || f.CreatedAfter != nil {
+		return domain.TotalFilter{}, errors.New("invalid filter for the Totals sheet (only user_id, service_name, service_id, tag, tag_match)")
+	}
+	tf := domain.TotalFilter{
+		ServiceName: f.ServiceName,
+		ServiceID:   f.ServiceID,
+		Tags:        f.Tags,
+		TagMatch:    f.TagMatch,
+		Mode:        domain.TotalModeCharges,
+	}
+	if len(f.UserIDs) == 1 {
+		tf.UserID = &f.UserIDs[0]
+	}
+	return tf, nil
+}
+
+// exportList выгружает все подписки под фильтром (без limit/offset). В XLSX с параметрами
+// from и to второй лист — помесячная разбивка за этот период с тем же фильтром.
+func (h *SubscriptionRoutes) exportList(w http.ResponseWriter, r *http.Request, f domain.ListFilter, format string) {
+	var months []domain.MonthBreakdown
+	fromStr, toStr := r.URL.Query().Get("from"), r.URL.Query().Get("to")
+	totals := format == export.FormatXLSX && (fromStr != "" || toStr != "")
+	if totals {
+		tf, err := listTotalFilter(f)
+		if err == nil {
+			months, err = h.svc.Breakdown(r.Context(), fromStr, toStr, tf)
+		}
+		if err != nil {
+			writeTotalError(w, err)
+			return
+		}
+	}
+	out, x := startExport(w, format, "subscriptions")
+	if err := x.Sheet("Subscriptions", exportColumns...); err != nil {
+		failExport(out, err, writeExportError)
+		return
+	}
+	err := h.svc.Stream(r.Context(), f, func(s domain.Subscription) error {
+		return x.Row(subscriptionRow(s)...)
+	})
+	if err == nil && totals {
+		err = writeTotalsSheet(x, months)
+	}
+	if err == nil {
+		err = x.Close()
+	}
+	if err != nil {
+		failExport(out, err, writeExportError)
+	}
+}
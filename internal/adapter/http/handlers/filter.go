@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"net/url"
+	"strings"
+	"time"
+
+	"crud_ef/internal/domain"
+
+	"github.com/google/uuid"
+)
+
+// parseListFilter разбирает фильтры и сортировку GET /subscriptions (без limit, offset и cursor).
+// Непустой msg — текст ошибки для ответа 400.
+func parseListFilter(q url.Values) (f domain.ListFilter, msg string) {
+	for _, v := range splitValues(q["user_id"]) {
+		uid, err := uuid.Parse(v)
+		if err != nil {
+			return f, "invalid user_id"
+		}
+		f.UserIDs = append(f.UserIDs, uid)
+	}
+	if v := q.Get("service_name"); v != "" {
+		f.ServiceName = &v
+	}
+	f.ServiceMatch = q.Get("service_match")
+	if v := q.Get("service_id"); v != "" {
+		sid, err := uuid.Parse(v)
+		if err != nil {
+			return f, "invalid service_id"
+		}
+		f.ServiceID = &sid
+	}
+	f.Tags = parseTags(q)
+	f.TagMatch = q.Get("tag_match")
+	if v := q.Get("min_price"); v != "" {
+		f.MinPrice = &v
+	}
+	if v := q.Get("max_price"); v != "" {
+		f.MaxPrice = &v
+	}
+	f.Statuses = splitValues(q["status"])
+
+	dates := []struct {
+		name  string
+		dst   **time.Time
+		parse func(string) (time.Time, error)
+	}{
+		{"active_at", &f.ActiveAt, func(v string) (time.Time, error) { return time.Parse("2006-01", v) }},
+		{"start_from", &f.StartFrom, func(v string) (time.Time, error) { return parseListDay(v, false) }},
+		{"start_to", &f.StartTo, func(v string) (time.Time, error) { return parseListDay(v, true) }},
+		{"created_after", &f.CreatedAfter, parseListInstant},
+	}
+	for _, d := range dates {
+		if v := q.Get(d.name); v != "" {
+			t, err := d.parse(v)
+			if err != nil {
+				return f, "invalid " + d.name
+			}
+			*d.dst = &t
+		}
+	}
+
+	if v := q.Get("sort"); v != "" {
+		field, dir, _ := strings.Cut(v, ":")
+		switch dir {
+		case "", "asc":
+		case "desc":
+			f.SortDesc = true
+		default:
+			return f, "invalid sort direction (asc, desc)"
+		}
+		f.SortBy = field
+	}
+	return f, ""
+}
+
+// splitValues собирает повторяющиеся и перечисленные через запятую значения параметра.
+func splitValues(values []string) []string {
+	var out []string
+	for _, v := range values {
+		for _, s := range strings.Split(v, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				out = append(out, s)
+			}
+		}
+	}
+	return out
+}
+
+// parseListDay принимает YYYY-MM-DD или YYYY-MM: первое число месяца, для end — последнее.
+func parseListDay(v string, end bool) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02", v); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse("2006-01", v)
+	if err != nil {
+		return t, err
+	}
+	if end {
+		return t.AddDate(0, 1, -1), nil
+	}
+	return t, nil
+}
+
+// parseListInstant принимает момент RFC 3339 или дату YYYY-MM-DD (начало дня UTC).
+func parseListInstant(v string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", v)
+}
@@ -0,0 +1,280 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"crud_ef/internal/domain"
+	"crud_ef/internal/usecase/subscription"
+
+	"github.com/google/uuid"
+)
+
+// maxImportBytes ограничивает размер файла импорта.
+const maxImportBytes = 32 << 20
+
+// ImportRowDTO — итог строки: created (valid при dry_run), duplicate или invalid с причиной.
+// Для дубля conflicts — существующие подписки, duplicate_of — строка того же файла.
+type ImportRowDTO struct {
+	Line           int         `json:"line"`
+	Status         string      `json:"status" enums:"created,valid,duplicate,invalid"`
+	SubscriptionID *uuid.UUID  `json:"subscription_id,omitempty"`
+	Error          string      `json:"error,omitempty"`
+	Conflicts      []uuid.UUID `json:"conflicts,omitempty"`
+	DuplicateOf    int         `json:"duplicate_of,omitempty"`
+}
+
+type ImportResponse struct {
+	DryRun     bool           `json:"dry_run"`
+	Created    int            `json:"created"`
+	Valid      int            `json:"valid"`
+	Duplicates int            `json:"duplicates"`
+	Invalid    int            `json:"invalid"`
+	Rows       []ImportRowDTO `json:"rows"`
+}
+
+// @Summary      Import subscriptions
+// @Description  CSV (header row with CreateRequest field names, tags comma-separated in one cell) or NDJSON (one CreateRequest per line). Every row is validated like POST /subscriptions; valid rows are created in one transaction. Rows overlapping an existing subscription or an earlier row are skipped as duplicates unless allow_overlap=true.
+// @Tags         subscriptions
+// @Accept       text/csv
+// @Accept       application/x-ndjson
+// @Produce      json
+// @Param        format         query  string  false  "csv or ndjson (default: from Content-Type)"  Enums(csv, ndjson)
+// @Param        dry_run        query  bool    false  "Only validate, do not create"
+// @Param        allow_overlap  query  bool    false  "Create rows overlapping other subscriptions of the same user and service"
+// @Success      200  {object}  ImportResponse
+// @Failure      400  {object}  map[string]string
+// @Failure      413  {object}  map[string]string
+// @Failure      415  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /subscriptions/import [post]
+func (h *SubscriptionRoutes) importFile(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	dryRun := false
+	if v := q.Get("dry_run"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid dry_run"})
+			return
+		}
+		dryRun = b
+	}
+	overlap, err := allowOverlap(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid allow_overlap"})
+		return
+	}
+	format := q.Get("format")
+	if format == "" {
+		format = importFormat(r.Header.Get("Content-Type"))
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxImportBytes))
+	if err != nil {
+		writeJSON(w, http.StatusRequestEntityTooLarge, map[string]string{"error": "file is too large"})
+		return
+	}
+	var rows []domain.ImportRow
+	switch format {
+	case "csv":
+		rows, err = csvImportRows(body)
+	case "ndjson":
+		rows, err = ndjsonImportRows(body)
+	default:
+		writeJSON(w, http.StatusUnsupportedMediaType, map[string]string{"error": "use text/csv or application/x-ndjson (or format=csv|ndjson)"})
+		return
+	}
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	if len(rows) > subscription.MaxImportRows {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid import: more than " + strconv.Itoa(subscription.MaxImportRows) + " rows"})
+		return
+	}
+
+	results, err := h.svc.Import(r.Context(), rows, dryRun, overlap)
+	if err != nil {
+		if strings.HasPrefix(err.Error(), "invalid") {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		} else {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		return
+	}
+	resp := ImportResponse{DryRun: dryRun, Rows: make([]ImportRowDTO, 0, len(results))}
+	for _, res := range results {
+		switch res.Status {
+		case domain.ImportCreated:
+			resp.Created++
+		case domain.ImportValid:
+			resp.Valid++
+		case domain.ImportDuplicate:
+			resp.Duplicates++
+		case domain.ImportInvalid:
+			resp.Invalid++
+		}
+		resp.Rows = append(resp.Rows, ImportRowDTO{
+			Line:           res.Line,
+			Status:         res.Status,
+			SubscriptionID: res.SubscriptionID,
+			Error:          res.Error,
+			Conflicts:      res.Conflicts,
+			DuplicateOf:    res.DuplicateOf,
+		})
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// importFormat определяет формат файла по Content-Type.
+func importFormat(contentType string) string {
+	mt, _, _ := mime.ParseMediaType(contentType)
+	switch mt {
+	case "text/csv", "application/csv":
+		return "csv"
+	case "application/x-ndjson", "application/ndjson", "application/jsonl":
+		return "ndjson"
+	}
+	return ""
+}
+
+// csvImportRows разбирает CSV с заголовком из имён полей CreateRequest; пустая ячейка — поле не задано.
+func csvImportRows(body []byte) ([]domain.ImportRow, error) {
+	cr := csv.NewReader(bytes.NewReader(body))
+	cr.FieldsPerRecord = -1
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil, errors.New("invalid CSV: empty file")
+	}
+	if err != nil {
+		return nil, errors.New("invalid CSV: " + err.Error())
+	}
+	for i, col := range header {
+		col = strings.TrimSpace(strings.TrimPrefix(col, "\ufeff"))
+		if !csvColumns[col] {
+			return nil, errors.New("invalid CSV: unknown column " + col)
+		}
+		header[i] = col
+	}
+
+	var rows []domain.ImportRow
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			var pe *csv.ParseError
+			if !errors.As(err, &pe) {
+				return nil, err
+			}
+			rows = append(rows, domain.ImportRow{Line: pe.StartLine, Error: "invalid CSV row: " + pe.Err.Error()})
+			continue
+		}
+		line, _ := cr.FieldPos(0)
+		if len(record) != len(header) {
+			rows = append(rows, domain.ImportRow{Line: line, Error: "invalid CSV row: expected " + strconv.Itoa(len(header)) + " fields"})
+			continue
+		}
+		fields := make(map[string]string, len(header))
+		for i, col := range header {
+			if v := strings.TrimSpace(record[i]); v != "" {
+				fields[col] = v
+			}
+		}
+		row := domain.ImportRow{Line: line}
+		req, err := csvRequest(fields)
+		if err == nil {
+			row.Input, err = createInput(req)
+		}
+		if err != nil {
+			row.Error = err.Error()
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+var csvColumns = map[string]bool{
+	"service_name": true, "service_id": true, "price": true, "monthly_price": true,
+	"billing_period": true, "billing_interval": true, "currency": true, "user_id": true,
+	"start_month": true, "end_month": true, "trial_months": true, "trial_end_month": true,
+	"notice_days": true, "tags": true,
+}
+
+func csvRequest(f map[string]string) (CreateRequest, error) {
+	req := CreateRequest{
+		ServiceName:   f["service_name"],
+		Price:         f["price"],
+		MonthlyPrice:  f["monthly_price"],
+		BillingPeriod: f["billing_period"],
+		Currency:      f["currency"],
+		UserID:        f["user_id"],
+		StartMonth:    f["start_month"],
+	}
+	for _, p := range []struct {
+		col string
+		dst **string
+	}{{"service_id", &req.ServiceID}, {"end_month", &req.EndMonth}, {"trial_end_month", &req.TrialEndMonth}} {
+		if v, ok := f[p.col]; ok {
+			*p.dst = &v
+		}
+	}
+	for _, p := range []struct {
+		col string
+		dst *int
+	}{{"billing_interval", &req.BillingInterval}, {"trial_months", &req.TrialMonths}, {"notice_days", &req.NoticeDays}} {
+		if v, ok := f[p.col]; ok {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return req, errors.New("invalid " + p.col)
+			}
+			*p.dst = n
+		}
+	}
+	if v, ok := f["tags"]; ok {
+		for _, t := range strings.Split(v, ",") {
+			if t = strings.TrimSpace(t); t != "" {
+				req.Tags = append(req.Tags, t)
+			}
+		}
+	}
+	return req, nil
+}
+
+// ndjsonImportRows разбирает NDJSON: по объекту CreateRequest в строке, пустые строки пропускаются.
+func ndjsonImportRows(body []byte) ([]domain.ImportRow, error) {
+	sc := bufio.NewScanner(bytes.NewReader(body))
+	sc.Buffer(make([]byte, 0, 64*1024), 1<<20)
+	var rows []domain.ImportRow
+	for line := 1; sc.Scan(); line++ {
+		text := bytes.TrimSpace(sc.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+		row := domain.ImportRow{Line: line}
+		var req CreateRequest
+		err := json.Unmarshal(text, &req)
+		if err != nil {
+			err = errors.New("invalid JSON")
+		} else {
+			row.Input, err = createInput(req)
+		}
+		if err != nil {
+			row.Error = err.Error()
+		}
+		rows = append(rows, row)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, errors.New("invalid NDJSON: " + err.Error())
+	}
+	return rows, nil
+}
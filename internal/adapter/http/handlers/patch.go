@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"mime"
+	"net/http"
+
+	"crud_ef/internal/domain"
+
+	"github.com/google/uuid"
+)
+
+// MergePatchType — тип тела PATCH по RFC 7396.
+const MergePatchType = "application/merge-patch+json"
+
+// isMergePatch принимает application/merge-patch+json и, для старых клиентов, application/json.
+func isMergePatch(r *http.Request) bool {
+	mt, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return err == nil && (mt == MergePatchType || mt == "application/json")
+}
+
+// decodeMergePatch переводит JSON Merge Patch в UpdateInput. null очищает необязательные поля
+// (service_id, end_month, trial_end_month, tags) и недопустим для обязательных; пустая строка
+// вместо null не принимается. Неизвестные поля — ошибка.
+func decodeMergePatch(body []byte) (domain.UpdateInput, error) {
+	var in domain.UpdateInput
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(body, &doc); err != nil || doc == nil {
+		return in, errors.New("invalid merge patch (JSON object expected)")
+	}
+	clear := ""
+	for field, raw := range doc {
+		isNull := string(raw) == "null"
+		var err error
+		switch field {
+		case "service_name":
+			in.ServiceName, err = patchString(field, raw, isNull)
+		case "service_id":
+			if isNull {
+				unlink := uuid.Nil
+				in.ServiceID = &unlink
+				continue
+			}
+			var v *string
+			if v, err = patchString(field, raw, false); err == nil {
+				var id uuid.UUID
+				if id, err = uuid.Parse(*v); err != nil {
+					err = errors.New("invalid service_id")
+				}
+				in.ServiceID = &id
+			}
+		case "price", "monthly_price":
+			var v *string
+			if v, err = patchString(field, raw, isNull); err == nil && (field == "price" || in.Price == nil) {
+				in.Price = v
+			}
+		case "price_effective_month":
+			in.PriceEffectiveMonth, err = patchString(field, raw, isNull)
+		case "billing_period":
+			in.BillingPeriod, err = patchString(field, raw, isNull)
+		case "billing_interval":
+			in.BillingInterval, err = patchInt(field, raw, isNull)
+		case "currency":
+			in.Currency, err = patchString(field, raw, isNull)
+		case "start_month":
+			in.StartMonth, err = patchString(field, raw, isNull)
+		case "end_month":
+			if isNull {
+				in.EndMonth = &clear
+				continue
+			}
+			in.EndMonth, err = patchString(field, raw, false)
+		case "trial_months":
+			in.TrialMonths, err = patchInt(field, raw, isNull)
+		case "trial_end_month":
+			if isNull {
+				in.TrialEndMonth = &clear
+				continue
+			}
+			in.TrialEndMonth, err = patchString(field, raw, false)
+		case "notice_days":
+			in.NoticeDays, err = patchInt(field, raw, isNull)
+		case "tags":
+			tags := []string{}
+			if !isNull && json.Unmarshal(raw, &tags) != nil {
+				err = errors.New("invalid tags (array of strings)")
+			}
+			in.Tags = &tags
+		default:
+			err = errors.New("invalid field " + field)
+		}
+		if err != nil {
+			return in, err
+		}
+	}
+	return in, nil
+}
+
+func patchString(field string, raw json.RawMessage, isNull bool) (*string, error) {
+	if isNull {
+		return nil, errors.New("invalid " + field + " (cannot be null)")
+	}
+	var v string
+	if err := json.Unmarshal(raw, &v); err != nil || v == "" {
+		return nil, errors.New("invalid " + field)
+	}
+	return &v, nil
+}
+
+func patchInt(field string, raw json.RawMessage, isNull bool) (*int, error) {
+	if isNull {
+		return nil, errors.New("invalid " + field + " (cannot be null)")
+	}
+	var v int
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return nil, errors.New("invalid " + field)
+	}
+	return &v, nil
+}
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"crud_ef/internal/domain"
+
+	"github.com/google/uuid"
+)
+
+func ptr[T any](v T) *T { return &v }
+
+func TestDecodeMergePatch(t *testing.T) {
+	sid := uuid.MustParse("0b6f6a0e-6f7c-4b8e-9d1a-2c3e4f5a6b7c")
+	tests := []struct {
+		name string
+		body string
+		want domain.UpdateInput
+	}{
+		{"empty object changes nothing", `{}`, domain.UpdateInput{}},
+		{
+			"absent fields stay nil",
+			`{"service_name":"Netflix","notice_days":3}`,
+			domain.UpdateInput{ServiceName: ptr("Netflix"), NoticeDays: ptr(3)},
+		},
+		{"null end_month clears it", `{"end_month":null}`, domain.UpdateInput{EndMonth: ptr("")}},
+		{"null trial_end_month clears it", `{"trial_end_month":null}`, domain.UpdateInput{TrialEndMonth: ptr("")}},
+		{"null service_id unlinks", `{"service_id":null}`, domain.UpdateInput{ServiceID: ptr(uuid.Nil)}},
+		{"null tags removes all", `{"tags":null}`, domain.UpdateInput{Tags: ptr([]string{})}},
+		{"empty tags removes all", `{"tags":[]}`, domain.UpdateInput{Tags: ptr([]string{})}},
+		{
+			"values are set",
+			`{"service_id":"` + sid.String() + `","end_month":"2025-12","tags":["video","family"],"billing_interval":2}`,
+			domain.UpdateInput{
+				ServiceID:       &sid,
+				EndMonth:        ptr("2025-12"),
+				Tags:            ptr([]string{"video", "family"}),
+				BillingInterval: ptr(2),
+			},
+		},
+		{"monthly_price alias", `{"monthly_price":"9.99"}`, domain.UpdateInput{Price: ptr("9.99")}},
+		{"price wins over alias", `{"monthly_price":"1","price":"2"}`, domain.UpdateInput{Price: ptr("2")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeMergePatch([]byte(tt.body))
+			if err != nil {
+				t.Fatalf("decodeMergePatch(%s): %v", tt.body, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("decodeMergePatch(%s) = %+v, want %+v", tt.body, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeMergePatchErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"not json", `service_name=x`, "JSON object expected"},
+		{"null document", `null`, "JSON object expected"},
+		{"array document", `[{"service_name":"x"}]`, "JSON object expected"},
+		{"null required string", `{"service_name":null}`, "invalid service_name (cannot be null)"},
+		{"null required int", `{"notice_days":null}`, "invalid notice_days (cannot be null)"},
+		{"empty string instead of null", `{"end_month":""}`, "invalid end_month"},
+		{"unknown field", `{"owner":"x"}`, "invalid field owner"},
+		{"nested object in string field", `{"service_name":{"ru":"Кинопоиск"}}`, "invalid service_name"},
+		{"nested object in int field", `{"billing_interval":{"n":1}}`, "invalid billing_interval"},
+		{"nested object in tags", `{"tags":{"video":true}}`, "invalid tags"},
+		{"nested object in end_month", `{"end_month":{"month":"2025-12"}}`, "invalid end_month"},
+		{"nested object in service_id", `{"service_id":{"id":"x"}}`, "invalid service_id"},
+		{"unknown nested object", `{"cancellation":{"reason":"x"}}`, "invalid field cancellation"},
+		{"bad uuid", `{"service_id":"42"}`, "invalid service_id"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := decodeMergePatch([]byte(tt.body))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("decodeMergePatch(%s) error = %v, want %q", tt.body, err, tt.want)
+			}
+			if err != nil && !strings.HasPrefix(err.Error(), "invalid") {
+				t.Errorf("error %q does not map to 400", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"crud_ef/internal/domain"
+	"crud_ef/internal/usecase/rates"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type ExchangeRateDTO struct {
+	Date  string `json:"date"`
+	Base  string `json:"base"`
+	Quote string `json:"quote"`
+	Rate  string `json:"rate"`
+}
+
+type UpsertRatesResponse struct {
+	Imported int `json:"imported"`
+}
+
+type RateRoutes struct {
+	svc *rates.Service
+}
+
+func NewRateRoutes(svc *rates.Service) *RateRoutes {
+	return &RateRoutes{svc: svc}
+}
+
+func (h *RateRoutes) Register(r chi.Router) {
+	r.Route("/admin/exchange-rates", func(r chi.Router) {
+		r.Get("/", h.list)
+		r.Post("/", h.upsert)
+		r.Delete("/{date}/{base}/{quote}", h.delete)
+	})
+}
+
+// @Summary      List exchange rates
+// @Tags         exchange-rates
+// @Produce      json
+// @Param        base   query  string  false  "Base currency (ISO 4217)"
+// @Param        quote  query  string  false  "Quote currency (ISO 4217)"
+// @Param        from   query  string  false  "YYYY-MM-DD"
+// @Param        to     query  string  false  "YYYY-MM-DD"
+// @Success      200  {array}   ExchangeRateDTO
+// @Failure      400  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /admin/exchange-rates [get]
+func (h *RateRoutes) list(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	var f domain.RateFilter
+	if v := q.Get("base"); v != "" {
+		f.Base = &v
+	}
+	if v := q.Get("quote"); v != "" {
+		f.Quote = &v
+	}
+	if v := q.Get("from"); v != "" {
+		f.From = &v
+	}
+	if v := q.Get("to"); v != "" {
+		f.To = &v
+	}
+	items, err := h.svc.List(r.Context(), f)
+	if err != nil {
+		if strings.HasPrefix(err.Error(), "invalid") {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		} else {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		return
+	}
+	out := make([]ExchangeRateDTO, 0, len(items))
+	for _, rt := range items {
+		out = append(out, ExchangeRateDTO{Date: rt.Date, Base: rt.Base, Quote: rt.Quote, Rate: rt.Rate})
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+// @Summary      Create or replace exchange rates
+// @Tags         exchange-rates
+// @Accept       json
+// @Produce      json
+// @Param        request  body  []ExchangeRateDTO  true  "rates: 1 base = rate quote"
+// @Success      200  {object}  UpsertRatesResponse
+// @Failure      400  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /admin/exchange-rates [post]
+func (h *RateRoutes) upsert(w http.ResponseWriter, r *http.Request) {
+	var req []ExchangeRateDTO
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON"})
+		return
+	}
+	in := make([]domain.ExchangeRate, 0, len(req))
+	for _, rt := range req {
+		in = append(in, domain.ExchangeRate{Date: rt.Date, Base: rt.Base, Quote: rt.Quote, Rate: rt.Rate})
+	}
+	n, err := h.svc.Upsert(r.Context(), in)
+	if err != nil {
+		if strings.HasPrefix(err.Error(), "invalid") {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		} else {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		return
+	}
+	writeJSON(w, http.StatusOK, UpsertRatesResponse{Imported: n})
+}
+
+// @Summary      Delete exchange rate
+// @Tags         exchange-rates
+// @Param        date   path  string  true  "YYYY-MM-DD"
+// @Param        base   path  string  true  "Base currency"
+// @Param        quote  path  string  true  "Quote currency"
+// @Success      204  {object}  map[string]string
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Router       /admin/exchange-rates/{date}/{base}/{quote} [delete]
+func (h *RateRoutes) delete(w http.ResponseWriter, r *http.Request) {
+	ok, err := h.svc.Delete(r.Context(), chi.URLParam(r, "date"), chi.URLParam(r, "base"), chi.URLParam(r, "quote"))
+	if err != nil {
+		if strings.HasPrefix(err.Error(), "invalid") {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		} else {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		return
+	}
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "not found"})
+		return
+	}
+	writeJSON(w, http.StatusNoContent, map[string]string{"status": "deleted"})
+}
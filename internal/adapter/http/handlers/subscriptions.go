@@ -2,6 +2,8 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
 	"strconv"
 	"strings"
@@ -15,46 +17,183 @@ import (
 )
 
 type SubscriptionDTO struct {
-	ID           uuid.UUID `json:"id"`
-	ServiceName  string    `json:"service_name"`
-	MonthlyPrice string    `json:"monthly_price"`
-	UserID       uuid.UUID `json:"user_id"`
-	StartMonth   string    `json:"start_month"`
-	EndMonth     *string   `json:"end_month,omitempty"`
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
+	ID              uuid.UUID        `json:"id"`
+	ServiceName     string           `json:"service_name"`
+	ServiceID       *uuid.UUID       `json:"service_id,omitempty"`
+	Price           string           `json:"price"`
+	BillingPeriod   string           `json:"billing_period"`
+	BillingInterval int              `json:"billing_interval"`
+	MonthlyPrice    string           `json:"monthly_price"`
+	Currency        string           `json:"currency"`
+	UserID          uuid.UUID        `json:"user_id"`
+	StartMonth      string           `json:"start_month" example:"2025-07"`
+	EndMonth        *string          `json:"end_month,omitempty" example:"2025-12"`
+	TrialEndMonth   *string          `json:"trial_end_month,omitempty" example:"2025-08"`
+	Status          string           `json:"status" enums:"upcoming,active,paused,ended"`
+	NoticeDays      int              `json:"notice_days"`
+	Cancellation    *CancellationDTO `json:"cancellation,omitempty"`
+	Tags            []string         `json:"tags" example:"work,team-backend"`
+	CreatedAt       time.Time        `json:"created_at"`
+	UpdatedAt       time.Time        `json:"updated_at"`
+	DeletedAt       *time.Time       `json:"deleted_at,omitempty"`
+	// Version совпадает со значением ETag.
+	Version int `json:"version"`
 }
 
+// CreateRequest: price — сумма одного списания; monthly_price оставлен для старых клиентов
+// и используется, если price не передан. service_id — запись каталога, service_name тогда
+// необязателен.
 type CreateRequest struct {
-	ServiceName  string  `json:"service_name"`
-	MonthlyPrice string  `json:"monthly_price"`
-	UserID       string  `json:"user_id"`
-	StartMonth   string  `json:"start_month"`
-	EndMonth     *string `json:"end_month,omitempty"`
+	ServiceName     string   `json:"service_name"`
+	ServiceID       *string  `json:"service_id,omitempty"`
+	Price           string   `json:"price,omitempty"`
+	MonthlyPrice    string   `json:"monthly_price,omitempty"`
+	BillingPeriod   string   `json:"billing_period,omitempty" enums:"week,month,quarter,year"`
+	BillingInterval int      `json:"billing_interval,omitempty"`
+	Currency        string   `json:"currency,omitempty"`
+	UserID          string   `json:"user_id"`
+	StartMonth      string   `json:"start_month" example:"2025-07-20"` // YYYY-MM или YYYY-MM-DD
+	EndMonth        *string  `json:"end_month,omitempty" example:"2025-12"`
+	TrialMonths     int      `json:"trial_months,omitempty" example:"1"`
+	TrialEndMonth   *string  `json:"trial_end_month,omitempty"`
+	NoticeDays      int      `json:"notice_days,omitempty" example:"14"`
+	Tags            []string `json:"tags,omitempty" example:"work,team-backend"`
 }
 
+// UpdateRequest — тело PATCH (merge patch, null очищает поле) и PUT в режиме LegacyPut.
 type UpdateRequest struct {
 	ServiceName  *string `json:"service_name,omitempty"`
+	ServiceID    *string `json:"service_id,omitempty"` // "" отвязывает от каталога
+	Price        *string `json:"price,omitempty"`
 	MonthlyPrice *string `json:"monthly_price,omitempty"`
-	StartMonth   *string `json:"start_month,omitempty"`
-	EndMonth     *string `json:"end_month,omitempty"`
+	// С какого месяца действует новая цена (YYYY-MM), по умолчанию — текущий месяц
+	PriceEffectiveMonth *string   `json:"price_effective_month,omitempty" example:"2025-09"`
+	BillingPeriod       *string   `json:"billing_period,omitempty" enums:"week,month,quarter,year"`
+	BillingInterval     *int      `json:"billing_interval,omitempty"`
+	Currency            *string   `json:"currency,omitempty"`
+	StartMonth          *string   `json:"start_month,omitempty"`
+	EndMonth            *string   `json:"end_month,omitempty"`
+	TrialMonths         *int      `json:"trial_months,omitempty"`    // 0 убирает пробный период
+	TrialEndMonth       *string   `json:"trial_end_month,omitempty"` // "" убирает пробный период
+	NoticeDays          *int      `json:"notice_days,omitempty"`
+	Tags                *[]string `json:"tags,omitempty"` // заменяет все теги, [] удаляет
+}
+
+type PriceChangeDTO struct {
+	EffectiveMonth string    `json:"effective_month"`
+	Price          string    `json:"price"`
+	Scheduled      bool      `json:"scheduled"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+type AddPriceRequest struct {
+	EffectiveMonth string `json:"effective_month" example:"2026-01"`
+	Price          string `json:"price"`
+}
+
+type CancellationDTO struct {
+	RequestedAt string    `json:"requested_at"`
+	By          string    `json:"cancelled_by,omitempty"`
+	Reason      string    `json:"reason,omitempty"`
+	CancelledAt time.Time `json:"cancelled_at"`
+}
+
+type CancelRequest struct {
+	RequestedAt string `json:"requested_at,omitempty" example:"2025-09-10"` // по умолчанию сегодня
+	CancelledBy string `json:"cancelled_by,omitempty"`
+	Reason      string `json:"reason,omitempty"`
+}
+
+type PauseRequest struct {
+	From     string `json:"from,omitempty" example:"2025-09-01"`      // по умолчанию сегодня
+	ResumeAt string `json:"resume_at,omitempty" example:"2025-12-01"` // первый день после паузы, необязательно
+}
+
+type ResumeRequest struct {
+	At string `json:"at,omitempty" example:"2025-11-15"` // по умолчанию сегодня
+}
+
+type PauseDTO struct {
+	ID        uuid.UUID `json:"id"`
+	From      string    `json:"from"`
+	ResumeAt  *string   `json:"resume_at,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// MemberDTO — участник совместной подписки: share equal (value пуст), percent (процент от
+// начисления) или fixed (сумма с каждого списания в валюте подписки).
+type MemberDTO struct {
+	UserID    uuid.UUID `json:"user_id"`
+	Share     string    `json:"share" enums:"equal,percent,fixed"`
+	Value     *string   `json:"value,omitempty" example:"25"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type MemberRequest struct {
+	UserID string  `json:"user_id"`
+	Share  string  `json:"share,omitempty" enums:"equal,percent,fixed"` // по умолчанию equal
+	Value  *string `json:"value,omitempty" example:"25"`
+}
+
+// ConflictResponse — ответ 409: conflicts — пересекающиеся подписки того же пользователя и сервиса.
+type ConflictResponse struct {
+	Error     string      `json:"error"`
+	Conflicts []uuid.UUID `json:"conflicts"`
+}
+
+// SubscriptionListResponse — страница списка при envelope=true; next_cursor отсутствует на
+// последней странице, total_count — только при include_total=true.
+type SubscriptionListResponse struct {
+	Items      []SubscriptionDTO `json:"items"`
+	NextCursor *string           `json:"next_cursor,omitempty"`
+	TotalCount *int              `json:"total_count,omitempty"`
+}
+
+type DuplicateGroupDTO struct {
+	UserID        uuid.UUID         `json:"user_id"`
+	ServiceName   string            `json:"service_name"`
+	Subscriptions []SubscriptionDTO `json:"subscriptions"`
+}
+
+// SubscriptionOptions — настройки обработчиков подписок.
+type SubscriptionOptions struct {
+	// RequireIfMatch запрещает PUT, PATCH и DELETE без заголовка If-Match (428).
+	RequireIfMatch bool
+	// LegacyPut — PUT как частичное обновление (UpdateRequest) на переходный период.
+	LegacyPut bool
 }
 
 type SubscriptionRoutes struct {
-	svc *subscription.Service
+	svc  *subscription.Service
+	opts SubscriptionOptions
 }
 
-func NewSubscriptionRoutes(svc *subscription.Service) *SubscriptionRoutes {
-	return &SubscriptionRoutes{svc: svc}
+func NewSubscriptionRoutes(svc *subscription.Service, opts SubscriptionOptions) *SubscriptionRoutes {
+	return &SubscriptionRoutes{svc: svc, opts: opts}
 }
 
 func (h *SubscriptionRoutes) Register(r chi.Router) {
 	r.Route("/subscriptions", func(r chi.Router) {
 		r.Post("/", h.create)
+		r.Post("/import", h.importFile)
 		r.Get("/{id}", h.get)
 		r.Get("/", h.list)
-		r.Put("/{id}", h.update)
+		r.Get("/trials/ending", h.trialsEnding)
+		r.Get("/duplicates", h.duplicates)
+		r.Get("/trash", h.trash)
+		r.Put("/{id}", h.replace)
+		r.Patch("/{id}", h.patch)
 		r.Delete("/{id}", h.delete)
+		r.Get("/{id}/prices", h.prices)
+		r.Post("/{id}/prices", h.addPrice)
+		r.Get("/{id}/pauses", h.pauses)
+		r.Get("/{id}/members", h.members)
+		r.Put("/{id}/members", h.setMembers)
+		r.Post("/{id}/pause", h.pause)
+		r.Post("/{id}/resume", h.resume)
+		r.Post("/{id}/cancel", h.cancel)
+		r.Post("/{id}/restore", h.restore)
+		r.Get("/{id}/history", h.history)
 	})
 }
 
@@ -68,9 +207,11 @@ func writeJSON(w http.ResponseWriter, code int, v any) {
 // @Tags         subscriptions
 // @Accept       json
 // @Produce      json
-// @Param        request  body  CreateRequest  true  "payload"
+// @Param        request        body   CreateRequest  true   "payload"
+// @Param        allow_overlap  query  bool           false  "Allow overlap with a subscription of the same user and service"
 // @Success      201  {object}  SubscriptionDTO
 // @Failure      400  {object}  map[string]string
+// @Failure      409  {object}  ConflictResponse
 // @Failure      500  {object}  map[string]string
 // @Router       /subscriptions [post]
 func (h *SubscriptionRoutes) create(w http.ResponseWriter, r *http.Request) {
@@ -79,20 +220,20 @@ func (h *SubscriptionRoutes) create(w http.ResponseWriter, r *http.Request) {
 		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON"})
 		return
 	}
-	uid, err := uuid.Parse(req.UserID)
+	in, err := createInput(req)
 	if err != nil {
-		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid user_id"})
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
 		return
 	}
-	in := domain.CreateInput{
-		ServiceName:  req.ServiceName,
-		MonthlyPrice: req.MonthlyPrice,
-		UserID:       uid,
-		StartMonth:   req.StartMonth,
-		EndMonth:     req.EndMonth,
+	if in.AllowOverlap, err = allowOverlap(r); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid allow_overlap"})
+		return
 	}
 	s, err := h.svc.Create(r.Context(), in)
 	if err != nil {
+		if writeOverlapError(w, err) {
+			return
+		}
 		if strings.HasPrefix(err.Error(), "invalid") {
 			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
 		} else {
@@ -104,10 +245,13 @@ func (h *SubscriptionRoutes) create(w http.ResponseWriter, r *http.Request) {
 }
 
 // @Summary      Get subscription by id
+// @Description  The ETag header carries the subscription version; If-None-Match with the current ETag returns 304.
 // @Tags         subscriptions
 // @Produce      json
-// @Param        id   path  string  true  "Subscription ID"
+// @Param        id             path    string  true   "Subscription ID"
+// @Param        If-None-Match  header  string  false  "ETag from a previous response"
 // @Success      200  {object}  SubscriptionDTO
+// @Success      304  "Not modified"
 // @Failure      400  {object}  map[string]string
 // @Failure      404  {object}  map[string]string
 // @Router       /subscriptions/{id} [get]
@@ -122,52 +266,138 @@ func (h *SubscriptionRoutes) get(w http.ResponseWriter, r *http.Request) {
 		writeJSON(w, http.StatusNotFound, map[string]string{"error": "not found"})
 		return
 	}
+	tag := etag(s.Version)
+	w.Header().Set("ETag", tag)
+	if noneMatch(r, tag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
 	writeJSON(w, http.StatusOK, toDTO(s))
 }
 
 // @Summary      List subscriptions
+// @Description  Ordered by the sort field (default created_at:desc), then id. Pages are fetched with the opaque cursor (next_cursor in the envelope or the Link header), which is stable when rows are added or deleted between requests and is only valid for the sort it was issued for; offset is kept for old clients. With envelope=true the response is SubscriptionListResponse.
+// @Description  With format=csv|xlsx (or Accept: text/csv / the XLSX MIME type) all matching subscriptions are exported as a file, limit and offset are ignored. The XLSX file has a second sheet with counts and monthly totals per currency.
 // @Tags         subscriptions
 // @Produce      json
-// @Param        user_id       query  string  false  "Filter by user UUID"
-// @Param        service_name  query  string  false  "Filter by service (ILIKE)"
-// @Param        limit         query  int     false  "Limit (1..200)"
-// @Param        offset        query  int     false  "Offset"
+// @Produce      text/csv
+// @Produce      application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Param        user_id       query  []string  false  "Filter by user UUIDs (repeat or comma-separated)"  collectionFormat(multi)
+// @Param        service_name  query  string  false  "Filter by service name"
+// @Param        service_match query  string  false  "contains: case-insensitive substring (default); exact: whole name, ignoring case and extra spaces"  Enums(contains, exact)
+// @Param        service_id    query  string  false  "Filter by catalog service UUID"
+// @Param        tag           query  []string  false  "Filter by tags (repeat or comma-separated)"  collectionFormat(multi)
+// @Param        tag_match     query  string  false  "any (default) or all of the tags"  Enums(any, all)
+// @Param        min_price     query  string  false  "Current price per charge >= min_price"
+// @Param        max_price     query  string  false  "Current price per charge <= max_price"
+// @Param        active_at     query  string  false  "Active at least one day in the month (YYYY-MM)"
+// @Param        status        query  []string  false  "Status on the current date (repeat or comma-separated)"  collectionFormat(multi)  Enums(upcoming, active, paused, ended)
+// @Param        start_from    query  string  false  "Start date >= (YYYY-MM or YYYY-MM-DD)"
+// @Param        start_to      query  string  false  "Start date <= (YYYY-MM means the end of the month, or YYYY-MM-DD)"
+// @Param        created_after query  string  false  "Created after (RFC 3339 or YYYY-MM-DD)"
+// @Param        sort          query  string  false  "field[:asc|:desc], ties broken by id; default created_at:desc. Fields: created_at, updated_at, start_month, end_month, service_name, price, monthly_price"
+// @Param        limit         query  int     false  "Limit (1..200, default 50)"
+// @Param        offset        query  int     false  "Offset (prefer cursor; not allowed together with it)"
+// @Param        cursor        query  string  false  "Opaque cursor from next_cursor or the Link header"
+// @Param        include_total query  bool    false  "Count all matching subscriptions (total_count / X-Total-Count)"
+// @Param        envelope      query  bool    false  "Return SubscriptionListResponse instead of a bare array"
+// @Param        format        query  string  false  "Export format (default: JSON or from Accept)"  Enums(json, csv, xlsx)
+// @Param        from          query  string  false  "YYYY-MM: with to, XLSX adds a Totals sheet from the monthly breakdown of the same filter"
+// @Param        to            query  string  false  "YYYY-MM: end of the Totals sheet period"
 // @Success      200  {array}   SubscriptionDTO
+// @Header       200  {string}  Link           "Next page (rel=next) when there are more rows"
+// @Header       200  {integer} X-Total-Count  "With include_total=true"
 // @Failure      400  {object}  map[string]string
 // @Failure      500  {object}  map[string]string
 // @Router       /subscriptions [get]
 func (h *SubscriptionRoutes) list(w http.ResponseWriter, r *http.Request) {
 	q := r.URL.Query()
-	var f domain.ListFilter
-	if v := q.Get("user_id"); v != "" {
-		uid, err := uuid.Parse(v)
-		if err != nil {
-			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid user_id"})
+	f, msg := parseListFilter(q)
+	if msg != "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": msg})
+		return
+	}
+	format, err := exportFormat(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	if format != "" {
+		h.exportList(w, r, f, format)
+		return
+	}
+	limit, offset, err := parseLimitOffset(q, subscription.MaxListLimit)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	f.Limit, f.Offset = limit, offset
+	if v := q.Get("cursor"); v != "" {
+		if f.After, err = decodeCursor(v); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
 			return
 		}
-		f.UserID = &uid
 	}
-	if v := q.Get("service_name"); v != "" {
-		f.ServiceName = &v
-	}
-	limit := 50
-	offset := 0
-	if v := q.Get("limit"); v != "" {
-		if n, err := strconv.Atoi(v); err == nil && n > 0 && n <= 200 {
-			limit = n
+	var withTotal, envelope bool
+	for _, p := range []struct {
+		name string
+		dst  *bool
+	}{{"include_total", &withTotal}, {"envelope", &envelope}} {
+		if v := q.Get(p.name); v != "" {
+			if *p.dst, err = strconv.ParseBool(v); err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid " + p.name})
+				return
+			}
 		}
 	}
-	if v := q.Get("offset"); v != "" {
-		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
-			offset = n
+
+	page, err := h.svc.List(r.Context(), f, withTotal)
+	if err != nil {
+		if strings.HasPrefix(err.Error(), "invalid") {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		} else {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
 		}
+		return
+	}
+	out := make([]SubscriptionDTO, 0, len(page.Items))
+	for _, s := range page.Items {
+		out = append(out, toDTO(s))
+	}
+	var next *string
+	if page.Next != nil {
+		c := encodeCursor(*page.Next)
+		next = &c
+	}
+	if envelope {
+		writeJSON(w, http.StatusOK, SubscriptionListResponse{Items: out, NextCursor: next, TotalCount: page.Total})
+		return
+	}
+	if next != nil {
+		w.Header().Set("Link", nextLink(r, *next))
 	}
-	f.Limit = limit
-	f.Offset = offset
+	if page.Total != nil {
+		w.Header().Set("X-Total-Count", strconv.Itoa(*page.Total))
+	}
+	writeJSON(w, http.StatusOK, out)
+}
 
-	items, err := h.svc.List(r.Context(), f)
+// @Summary      Subscriptions whose free trial ends soon
+// @Tags         subscriptions
+// @Produce      json
+// @Param        within  query  string  false  "Horizon: 30d (default), 2w or number of days"
+// @Success      200  {array}   SubscriptionDTO
+// @Failure      400  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /subscriptions/trials/ending [get]
+func (h *SubscriptionRoutes) trialsEnding(w http.ResponseWriter, r *http.Request) {
+	items, err := h.svc.TrialsEnding(r.Context(), r.URL.Query().Get("within"))
 	if err != nil {
-		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		if strings.HasPrefix(err.Error(), "invalid") {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		} else {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
 		return
 	}
 	out := make([]SubscriptionDTO, 0, len(items))
@@ -177,52 +407,227 @@ func (h *SubscriptionRoutes) list(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, out)
 }
 
-// @Summary      Update subscription
+// @Summary      Overlapping subscriptions
+// @Description  Groups of subscriptions of the same user and service (case and whitespace insensitive) whose periods overlap.
+// @Tags         subscriptions
+// @Produce      json
+// @Param        user_id  query  string  false  "User UUID"
+// @Success      200  {array}   DuplicateGroupDTO
+// @Failure      400  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /subscriptions/duplicates [get]
+func (h *SubscriptionRoutes) duplicates(w http.ResponseWriter, r *http.Request) {
+	var userID *uuid.UUID
+	if v := r.URL.Query().Get("user_id"); v != "" {
+		u, err := uuid.Parse(v)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid user_id"})
+			return
+		}
+		userID = &u
+	}
+	groups, err := h.svc.Duplicates(r.Context(), userID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	out := make([]DuplicateGroupDTO, 0, len(groups))
+	for _, g := range groups {
+		items := make([]SubscriptionDTO, 0, len(g.Subscriptions))
+		for _, s := range g.Subscriptions {
+			items = append(items, toDTO(s))
+		}
+		out = append(out, DuplicateGroupDTO{UserID: g.UserID, ServiceName: g.ServiceName, Subscriptions: items})
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+// updatePartial — прежний PUT: частичное обновление, пустые end_month, trial_end_month и
+// service_id очищают поля. Включается SubscriptionOptions.LegacyPut.
+func (h *SubscriptionRoutes) updatePartial(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid id"})
+		return
+	}
+	version, ok := ifMatch(w, r, h.opts.RequireIfMatch)
+	if !ok {
+		return
+	}
+	var req UpdateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON"})
+		return
+	}
+	price := req.Price
+	if price == nil {
+		price = req.MonthlyPrice
+	}
+	in := domain.UpdateInput{
+		ServiceName:         req.ServiceName,
+		Price:               price,
+		PriceEffectiveMonth: req.PriceEffectiveMonth,
+		BillingPeriod:       req.BillingPeriod,
+		BillingInterval:     req.BillingInterval,
+		Currency:            req.Currency,
+		StartMonth:          req.StartMonth,
+		EndMonth:            req.EndMonth,
+		TrialMonths:         req.TrialMonths,
+		TrialEndMonth:       req.TrialEndMonth,
+		NoticeDays:          req.NoticeDays,
+		Tags:                req.Tags,
+		IfVersion:           version,
+	}
+	if req.ServiceID != nil {
+		sid := uuid.Nil
+		if *req.ServiceID != "" {
+			if sid, err = uuid.Parse(*req.ServiceID); err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid service_id"})
+				return
+			}
+		}
+		in.ServiceID = &sid
+	}
+	if in.AllowOverlap, err = allowOverlap(r); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid allow_overlap"})
+		return
+	}
+	s, err := h.svc.Update(r.Context(), id, in)
+	writeUpdateResult(w, s, err)
+}
+
+// @Summary      Replace subscription
+// @Description  Full replacement validated like create: omitted optional fields (end_month, trial, service_id, tags) are cleared, user_id must stay the same. A price different from the current one applies from the current month. With LEGACY_PUT enabled PUT keeps the old partial-update semantics of UpdateRequest.
 // @Tags         subscriptions
 // @Accept       json
 // @Produce      json
-// @Param        id       path   string          true  "Subscription ID"
-// @Param        request  body   UpdateRequest   true  "payload"
+// @Param        id             path    string         true   "Subscription ID"
+// @Param        request        body    CreateRequest  true   "payload"
+// @Param        allow_overlap  query   bool           false  "Allow overlap with a subscription of the same user and service"
+// @Param        If-Match       header  string         false  "ETag of the version being changed"
 // @Success      200  {object}  SubscriptionDTO
 // @Failure      400  {object}  map[string]string
 // @Failure      404  {object}  map[string]string
+// @Failure      409  {object}  ConflictResponse
+// @Failure      412  {object}  map[string]string
+// @Failure      428  {object}  map[string]string
 // @Failure      500  {object}  map[string]string
 // @Router       /subscriptions/{id} [put]
-func (h *SubscriptionRoutes) update(w http.ResponseWriter, r *http.Request) {
+func (h *SubscriptionRoutes) replace(w http.ResponseWriter, r *http.Request) {
+	if h.opts.LegacyPut {
+		h.updatePartial(w, r)
+		return
+	}
 	id, err := uuid.Parse(chi.URLParam(r, "id"))
 	if err != nil {
 		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid id"})
 		return
 	}
-	var req UpdateRequest
+	version, ok := ifMatch(w, r, h.opts.RequireIfMatch)
+	if !ok {
+		return
+	}
+	var req CreateRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON"})
 		return
 	}
-	in := domain.UpdateInput{
-		ServiceName:  req.ServiceName,
-		MonthlyPrice: req.MonthlyPrice,
-		StartMonth:   req.StartMonth,
-		EndMonth:     req.EndMonth,
+	in, err := createInput(req)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	if in.AllowOverlap, err = allowOverlap(r); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid allow_overlap"})
+		return
+	}
+	s, err := h.svc.Replace(r.Context(), id, in, version)
+	writeUpdateResult(w, s, err)
+}
+
+// @Summary      Patch subscription
+// @Description  JSON Merge Patch (RFC 7396): only present fields change; null clears end_month, trial_end_month, service_id and tags. A new price applies from price_effective_month (current month by default).
+// @Tags         subscriptions
+// @Accept       application/merge-patch+json
+// @Produce      json
+// @Param        id             path    string         true   "Subscription ID"
+// @Param        request        body    UpdateRequest  true   "merge patch"
+// @Param        allow_overlap  query   bool           false  "Allow overlap with a subscription of the same user and service"
+// @Param        If-Match       header  string         false  "ETag of the version being changed"
+// @Success      200  {object}  SubscriptionDTO
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      409  {object}  ConflictResponse
+// @Failure      412  {object}  map[string]string
+// @Failure      415  {object}  map[string]string
+// @Failure      428  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /subscriptions/{id} [patch]
+func (h *SubscriptionRoutes) patch(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid id"})
+		return
+	}
+	if !isMergePatch(r) {
+		writeJSON(w, http.StatusUnsupportedMediaType, map[string]string{"error": "Content-Type must be " + MergePatchType})
+		return
+	}
+	version, ok := ifMatch(w, r, h.opts.RequireIfMatch)
+	if !ok {
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid body"})
+		return
+	}
+	in, err := decodeMergePatch(body)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	in.IfVersion = version
+	if in.AllowOverlap, err = allowOverlap(r); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid allow_overlap"})
+		return
 	}
 	s, err := h.svc.Update(r.Context(), id, in)
+	writeUpdateResult(w, s, err)
+}
+
+// writeUpdateResult отвечает на PUT и PATCH: подписка с ETag или ошибка.
+func writeUpdateResult(w http.ResponseWriter, s domain.Subscription, err error) {
 	if err != nil {
-		if strings.HasPrefix(err.Error(), "invalid") {
-			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
-		} else {
+		if writeOverlapError(w, err) {
+			return
+		}
+		switch {
+		case errors.Is(err, domain.ErrVersionMismatch):
+			writePreconditionFailed(w)
+		case errors.Is(err, domain.ErrNotFound):
 			writeJSON(w, http.StatusNotFound, map[string]string{"error": "not found"})
+		case strings.HasPrefix(err.Error(), "invalid"):
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		default:
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
 		}
 		return
 	}
+	w.Header().Set("ETag", etag(s.Version))
 	writeJSON(w, http.StatusOK, toDTO(s))
 }
 
 // @Summary      Delete subscription
+// @Description  Moves the subscription to the trash; it is purged after the retention period.
 // @Tags         subscriptions
-// @Param        id  path  string  true  "Subscription ID"
+// @Param        id        path    string  true   "Subscription ID"
+// @Param        If-Match  header  string  false  "ETag of the version being deleted"
 // @Success      204  {object}  map[string]string
 // @Failure      400  {object}  map[string]string
 // @Failure      404  {object}  map[string]string
+// @Failure      412  {object}  map[string]string
+// @Failure      428  {object}  map[string]string
 // @Router       /subscriptions/{id} [delete]
 func (h *SubscriptionRoutes) delete(w http.ResponseWriter, r *http.Request) {
 	id, err := uuid.Parse(chi.URLParam(r, "id"))
@@ -230,7 +635,15 @@ func (h *SubscriptionRoutes) delete(w http.ResponseWriter, r *http.Request) {
 		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid id"})
 		return
 	}
-	ok, err := h.svc.Delete(r.Context(), id)
+	version, ok := ifMatch(w, r, h.opts.RequireIfMatch)
+	if !ok {
+		return
+	}
+	ok, err = h.svc.Delete(r.Context(), id, version)
+	if errors.Is(err, domain.ErrVersionMismatch) {
+		writePreconditionFailed(w)
+		return
+	}
 	if err != nil {
 		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
 		return
@@ -242,15 +655,489 @@ func (h *SubscriptionRoutes) delete(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusNoContent, map[string]string{"status": "deleted"})
 }
 
+// @Summary      Deleted subscriptions
+// @Tags         subscriptions
+// @Produce      json
+// @Param        user_id  query  string  false  "Filter by user UUID"
+// @Param        limit    query  int     false  "Limit (1..200)"
+// @Param        offset   query  int     false  "Offset"
+// @Success      200  {array}   SubscriptionDTO
+// @Failure      400  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /subscriptions/trash [get]
+func (h *SubscriptionRoutes) trash(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	var f domain.ListFilter
+	if v := q.Get("user_id"); v != "" {
+		uid, err := uuid.Parse(v)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid user_id"})
+			return
+		}
+		f.UserID = &uid
+	}
+	var err error
+	if f.Limit, f.Offset, err = parseLimitOffset(q, maxPageLimit); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	items, err := h.svc.Trash(r.Context(), f)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	out := make([]SubscriptionDTO, 0, len(items))
+	for _, s := range items {
+		out = append(out, toDTO(s))
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+// @Summary      Restore deleted subscription
+// @Description  Returns 409 with the conflicting IDs if the restored period overlaps another subscription of the user to the same service, unless allow_overlap=true.
+// @Tags         subscriptions
+// @Produce      json
+// @Param        id             path   string  true   "Subscription ID"
+// @Param        allow_overlap  query  bool    false  "Restore even if the period overlaps another subscription to the same service"
+// @Success      200  {object}  SubscriptionDTO
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      409  {object}  ConflictResponse
+// @Failure      500  {object}  map[string]string
+// @Router       /subscriptions/{id}/restore [post]
+func (h *SubscriptionRoutes) restore(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid id"})
+		return
+	}
+	overlap, err := allowOverlap(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid allow_overlap"})
+		return
+	}
+	s, err := h.svc.Restore(r.Context(), id, overlap)
+	if writeOverlapError(w, err) {
+		return
+	}
+	if errors.Is(err, domain.ErrNotFound) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "not found"})
+		return
+	}
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, toDTO(s))
+}
+
+// @Summary      Change history of subscription
+// @Description  Events are returned in the order they were recorded; deleted subscriptions keep their history.
+// @Tags         subscriptions
+// @Produce      json
+// @Param        id      path   string  true   "Subscription ID"
+// @Param        limit   query  int     false  "Limit (1..200)"
+// @Param        offset  query  int     false  "Offset"
+// @Success      200  {array}   EventDTO
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /subscriptions/{id}/history [get]
+func (h *SubscriptionRoutes) history(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid id"})
+		return
+	}
+	limit, offset, err := parseLimitOffset(r.URL.Query(), maxPageLimit)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	items, err := h.svc.History(r.Context(), id, limit, offset)
+	if err != nil {
+		if errors.Is(err, domain.ErrNotFound) {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "not found"})
+		} else {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		return
+	}
+	writeJSON(w, http.StatusOK, toEventDTOs(items))
+}
+
+// @Summary      Price timeline of subscription
+// @Tags         subscriptions
+// @Produce      json
+// @Param        id   path  string  true  "Subscription ID"
+// @Success      200  {array}   PriceChangeDTO
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /subscriptions/{id}/prices [get]
+func (h *SubscriptionRoutes) prices(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid id"})
+		return
+	}
+	items, err := h.svc.Prices(r.Context(), id)
+	if err != nil {
+		writeSubscriptionError(w, err)
+		return
+	}
+	out := make([]PriceChangeDTO, 0, len(items))
+	for _, p := range items {
+		out = append(out, toPriceDTO(p))
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+// @Summary      Change price from a month (past, current or scheduled)
+// @Tags         subscriptions
+// @Accept       json
+// @Produce      json
+// @Param        id       path  string           true  "Subscription ID"
+// @Param        request  body  AddPriceRequest  true  "payload"
+// @Success      201  {object}  PriceChangeDTO
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /subscriptions/{id}/prices [post]
+func (h *SubscriptionRoutes) addPrice(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid id"})
+		return
+	}
+	var req AddPriceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON"})
+		return
+	}
+	p, err := h.svc.AddPrice(r.Context(), id, req.EffectiveMonth, req.Price)
+	if err != nil {
+		writeSubscriptionError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, toPriceDTO(p))
+}
+
+// decodeOptional разбирает необязательное JSON-тело: пустое тело допустимо.
+func decodeOptional(r *http.Request, v any) error {
+	err := json.NewDecoder(r.Body).Decode(v)
+	if err == io.EOF {
+		return nil
+	}
+	return err
+}
+
+// @Summary      Pause subscription
+// @Tags         subscriptions
+// @Accept       json
+// @Produce      json
+// @Param        id       path  string        true   "Subscription ID"
+// @Param        request  body  PauseRequest  false  "payload"
+// @Success      200  {object}  SubscriptionDTO
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /subscriptions/{id}/pause [post]
+func (h *SubscriptionRoutes) pause(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid id"})
+		return
+	}
+	var req PauseRequest
+	if err := decodeOptional(r, &req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON"})
+		return
+	}
+	s, err := h.svc.Pause(r.Context(), id, req.From, req.ResumeAt)
+	if err != nil {
+		writeSubscriptionError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, toDTO(s))
+}
+
+// @Summary      Resume paused subscription
+// @Tags         subscriptions
+// @Accept       json
+// @Produce      json
+// @Param        id       path  string         true   "Subscription ID"
+// @Param        request  body  ResumeRequest  false  "payload"
+// @Success      200  {object}  SubscriptionDTO
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /subscriptions/{id}/resume [post]
+func (h *SubscriptionRoutes) resume(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid id"})
+		return
+	}
+	var req ResumeRequest
+	if err := decodeOptional(r, &req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON"})
+		return
+	}
+	s, err := h.svc.Resume(r.Context(), id, req.At)
+	if err != nil {
+		writeSubscriptionError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, toDTO(s))
+}
+
+// @Summary      Pauses of subscription
+// @Tags         subscriptions
+// @Produce      json
+// @Param        id   path  string  true  "Subscription ID"
+// @Success      200  {array}   PauseDTO
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /subscriptions/{id}/pauses [get]
+func (h *SubscriptionRoutes) pauses(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid id"})
+		return
+	}
+	items, err := h.svc.Pauses(r.Context(), id)
+	if err != nil {
+		writeSubscriptionError(w, err)
+		return
+	}
+	out := make([]PauseDTO, 0, len(items))
+	for _, p := range items {
+		out = append(out, PauseDTO{ID: p.ID, From: p.From, ResumeAt: p.ResumeAt, CreatedAt: p.CreatedAt})
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+// @Summary      Members sharing the subscription cost
+// @Tags         subscriptions
+// @Produce      json
+// @Param        id   path  string  true  "Subscription ID"
+// @Success      200  {array}   MemberDTO
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /subscriptions/{id}/members [get]
+func (h *SubscriptionRoutes) members(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid id"})
+		return
+	}
+	items, err := h.svc.Members(r.Context(), id)
+	if err != nil {
+		writeSubscriptionError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, toMemberDTOs(items))
+}
+
+// @Summary      Replace subscription members
+// @Description  The owner (user_id of the subscription) pays every charge; members owe their share to the owner. An owner not listed among members shares the remainder equally with "equal" members. An empty list makes the subscription personal again.
+// @Tags         subscriptions
+// @Accept       json
+// @Produce      json
+// @Param        id       path  string           true  "Subscription ID"
+// @Param        request  body  []MemberRequest  true  "members"
+// @Success      200  {array}   MemberDTO
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /subscriptions/{id}/members [put]
+func (h *SubscriptionRoutes) setMembers(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid id"})
+		return
+	}
+	var req []MemberRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON"})
+		return
+	}
+	in := make([]domain.Member, 0, len(req))
+	for _, m := range req {
+		uid, err := uuid.Parse(m.UserID)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid user_id"})
+			return
+		}
+		in = append(in, domain.Member{UserID: uid, Share: m.Share, Value: m.Value})
+	}
+	items, err := h.svc.SetMembers(r.Context(), id, in)
+	if err != nil {
+		writeSubscriptionError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, toMemberDTOs(items))
+}
+
+// @Summary      Cancel subscription
+// @Description  Subscription stays active until the end of the billing term in which the notice period (notice_days) expires; billing terms start on the first day after the trial. If the notice expires during the trial, the subscription ends with the trial.
+// @Tags         subscriptions
+// @Accept       json
+// @Produce      json
+// @Param        id       path  string         true   "Subscription ID"
+// @Param        request  body  CancelRequest  false  "payload"
+// @Success      200  {object}  SubscriptionDTO
+// @Failure      400  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      409  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /subscriptions/{id}/cancel [post]
+func (h *SubscriptionRoutes) cancel(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid id"})
+		return
+	}
+	var req CancelRequest
+	if err := decodeOptional(r, &req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON"})
+		return
+	}
+	s, err := h.svc.Cancel(r.Context(), id, domain.CancelInput{
+		RequestedAt: req.RequestedAt,
+		By:          req.CancelledBy,
+		Reason:      req.Reason,
+	})
+	if errors.Is(err, subscription.ErrAlreadyEnded) || errors.Is(err, subscription.ErrAlreadyCancelled) {
+		writeJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		writeSubscriptionError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, toDTO(s))
+}
+
+// writeSubscriptionError отвечает на ошибку операции с подпиской: "invalid…" — 400,
+// domain.ErrNotFound — 404, остальные (сбой базы, таймаут) — 500.
+func writeSubscriptionError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, domain.ErrNotFound):
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "not found"})
+	case strings.HasPrefix(err.Error(), "invalid"):
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+	default:
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+}
+
+// createInput переводит документ подписки (POST и PUT) во входные данные сервиса.
+func createInput(req CreateRequest) (domain.CreateInput, error) {
+	uid, err := uuid.Parse(req.UserID)
+	if err != nil {
+		return domain.CreateInput{}, errors.New("invalid user_id")
+	}
+	price := req.Price
+	if price == "" {
+		price = req.MonthlyPrice
+	}
+	in := domain.CreateInput{
+		ServiceName:     req.ServiceName,
+		Price:           price,
+		BillingPeriod:   req.BillingPeriod,
+		BillingInterval: req.BillingInterval,
+		Currency:        req.Currency,
+		UserID:          uid,
+		StartMonth:      req.StartMonth,
+		EndMonth:        req.EndMonth,
+		TrialMonths:     req.TrialMonths,
+		TrialEndMonth:   req.TrialEndMonth,
+		NoticeDays:      req.NoticeDays,
+		Tags:            req.Tags,
+	}
+	if req.ServiceID != nil {
+		sid, err := uuid.Parse(*req.ServiceID)
+		if err != nil {
+			return in, errors.New("invalid service_id")
+		}
+		in.ServiceID = &sid
+	}
+	return in, nil
+}
+
+func toCancellationDTO(c *domain.Cancellation) *CancellationDTO {
+	if c == nil {
+		return nil
+	}
+	return &CancellationDTO{
+		RequestedAt: c.RequestedAt,
+		By:          c.By,
+		Reason:      c.Reason,
+		CancelledAt: c.CancelledAt,
+	}
+}
+
+func toPriceDTO(p domain.PriceChange) PriceChangeDTO {
+	return PriceChangeDTO{
+		EffectiveMonth: p.EffectiveMonth,
+		Price:          p.Price,
+		Scheduled:      p.Scheduled,
+		CreatedAt:      p.CreatedAt,
+	}
+}
+
+func toMemberDTOs(items []domain.Member) []MemberDTO {
+	out := make([]MemberDTO, 0, len(items))
+	for _, m := range items {
+		out = append(out, MemberDTO{UserID: m.UserID, Share: m.Share, Value: m.Value, CreatedAt: m.CreatedAt})
+	}
+	return out
+}
+
+func allowOverlap(r *http.Request) (bool, error) {
+	v := r.URL.Query().Get("allow_overlap")
+	if v == "" {
+		return false, nil
+	}
+	return strconv.ParseBool(v)
+}
+
+// writeOverlapError отвечает 409, если err — пересечение с другой подпиской.
+func writeOverlapError(w http.ResponseWriter, err error) bool {
+	var oe *subscription.OverlapError
+	if !errors.As(err, &oe) {
+		return false
+	}
+	writeJSON(w, http.StatusConflict, ConflictResponse{Error: oe.Error(), Conflicts: oe.Conflicts})
+	return true
+}
+
 func toDTO(s domain.Subscription) SubscriptionDTO {
 	return SubscriptionDTO{
-		ID:           s.ID,
-		ServiceName:  s.ServiceName,
-		MonthlyPrice: s.MonthlyPrice,
-		UserID:       s.UserID,
-		StartMonth:   s.StartMonth,
-		EndMonth:     s.EndMonth,
-		CreatedAt:    s.CreatedAt,
-		UpdatedAt:    s.UpdatedAt,
+		ID:              s.ID,
+		ServiceName:     s.ServiceName,
+		ServiceID:       s.ServiceID,
+		Price:           s.Price,
+		BillingPeriod:   s.BillingPeriod,
+		BillingInterval: s.BillingInterval,
+		MonthlyPrice:    s.MonthlyPrice,
+		Currency:        s.Currency,
+		UserID:          s.UserID,
+		StartMonth:      s.StartMonth,
+		EndMonth:        s.EndMonth,
+		TrialEndMonth:   s.TrialEndMonth,
+		Status:          s.Status,
+		NoticeDays:      s.NoticeDays,
+		Cancellation:    toCancellationDTO(s.Cancellation),
+		Tags:            s.Tags,
+		CreatedAt:       s.CreatedAt,
+		UpdatedAt:       s.UpdatedAt,
+		DeletedAt:       s.DeletedAt,
+		Version:         s.Version,
 	}
 }
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"crud_ef/internal/usecase/subscription"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+type TagCountDTO struct {
+	Tag           string `json:"tag"`
+	Subscriptions int    `json:"subscriptions"`
+}
+
+type TagRoutes struct {
+	svc *subscription.Service
+}
+
+func NewTagRoutes(svc *subscription.Service) *TagRoutes {
+	return &TagRoutes{svc: svc}
+}
+
+func (h *TagRoutes) Register(r chi.Router) {
+	r.Get("/tags", h.list)
+}
+
+// @Summary      Tags with usage counts
+// @Tags         tags
+// @Produce      json
+// @Param        user_id  query  string  false  "Count only subscriptions of the user"
+// @Success      200  {array}   TagCountDTO
+// @Failure      400  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /tags [get]
+func (h *TagRoutes) list(w http.ResponseWriter, r *http.Request) {
+	var userID *uuid.UUID
+	if v := r.URL.Query().Get("user_id"); v != "" {
+		u, err := uuid.Parse(v)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid user_id"})
+			return
+		}
+		userID = &u
+	}
+	items, err := h.svc.Tags(r.Context(), userID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	out := make([]TagCountDTO, 0, len(items))
+	for _, t := range items {
+		out = append(out, TagCountDTO{Tag: t.Tag, Subscriptions: t.Subscriptions})
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+// parseTags собирает теги из повторяющихся и перечисленных через запятую параметров tag.
+func parseTags(q url.Values) []string {
+	var tags []string
+	for _, v := range q["tag"] {
+		for _, t := range strings.Split(v, ",") {
+			if t = strings.TrimSpace(t); t != "" {
+				tags = append(tags, t)
+			}
+		}
+	}
+	return tags
+}
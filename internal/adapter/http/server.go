@@ -5,6 +5,10 @@ import (
 
 	"crud_ef/internal/adapter/http/handlers"
 	"crud_ef/internal/config"
+	"crud_ef/internal/usecase/budget"
+	"crud_ef/internal/usecase/calendar"
+	"crud_ef/internal/usecase/catalog"
+	"crud_ef/internal/usecase/rates"
 	"crud_ef/internal/usecase/subscription"
 
 	"github.com/go-chi/chi/v5"
@@ -17,9 +21,10 @@ type Server struct {
 	router *chi.Mux
 }
 
-func New(cfg config.Config, svc *subscription.Service) *Server {
+func New(cfg config.Config, svc *subscription.Service, ratesSvc *rates.Service, budgetSvc *budget.Service, catalogSvc *catalog.Service, calendarSvc *calendar.Service) *Server {
 	r := chi.NewRouter()
 	r.Use(middleware.RequestID)
+	r.Use(handlers.Audit)
 	r.Use(middleware.RealIP)
 	r.Use(middleware.Logger)
 	r.Use(middleware.Recoverer)
@@ -32,12 +37,33 @@ func New(cfg config.Config, svc *subscription.Service) *Server {
 
 	r.Get("/swagger/*", httpSwagger.WrapHandler)
 
-	sub := handlers.NewSubscriptionRoutes(svc)
+	sub := handlers.NewSubscriptionRoutes(svc, handlers.SubscriptionOptions{
+		RequireIfMatch: cfg.RequireIfMatch,
+		LegacyPut:      cfg.LegacyPut,
+	})
 	sub.Register(r)
 
 	agg := handlers.NewAggregateRoutes(svc)
 	agg.Register(r)
 
+	tg := handlers.NewTagRoutes(svc)
+	tg.Register(r)
+
+	rt := handlers.NewRateRoutes(ratesSvc)
+	rt.Register(r)
+
+	bg := handlers.NewBudgetRoutes(budgetSvc)
+	bg.Register(r)
+
+	ct := handlers.NewCatalogRoutes(catalogSvc)
+	ct.Register(r)
+
+	au := handlers.NewAuditRoutes(svc)
+	au.Register(r)
+
+	cl := handlers.NewCalendarRoutes(calendarSvc)
+	cl.Register(r)
+
 	return &Server{
 		addr:   cfg.Addr(),
 		router: r,
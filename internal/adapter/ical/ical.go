@@ -0,0 +1,137 @@
+// Package ical пишет календарную ленту списаний в формате iCalendar (RFC 5545).
+package ical
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"crud_ef/internal/domain"
+)
+
+const ContentType = "text/calendar; charset=utf-8"
+
+const (
+	dateLayout  = "20060102"
+	stampLayout = "20060102T150405Z"
+	// lineLimit — наибольшая длина строки в октетах без CRLF (RFC 5545, 3.1).
+	lineLimit = 75
+)
+
+// Write пишет календарь name: по событию на весь день в дату каждого списания с RRULE,
+// напоминанием накануне и, если задан срок уведомления, напоминанием об отмене.
+func Write(w io.Writer, name string, events []domain.CalendarEvent) error {
+	b := bufio.NewWriter(w)
+	line := func(s string) { fold(b, s) }
+
+	line("BEGIN:VCALENDAR")
+	line("VERSION:2.0")
+	line("PRODID:-//crud_ef//Subscriptions//RU")
+	line("CALSCALE:GREGORIAN")
+	line("METHOD:PUBLISH")
+	line("X-WR-CALNAME:" + escape(name))
+	stamp := time.Now().UTC().Format(stampLayout)
+	for _, ev := range events {
+		summary := ev.ServiceName + " — " + ev.Price + " " + ev.Currency
+		line("BEGIN:VEVENT")
+		line("UID:" + ev.SubscriptionID.String() + "@subscriptions")
+		line("DTSTAMP:" + stamp)
+		line("LAST-MODIFIED:" + ev.UpdatedAt.UTC().Format(stampLayout))
+		line("SEQUENCE:" + strconv.Itoa(ev.Version))
+		line("DTSTART;VALUE=DATE:" + ev.First.Format(dateLayout))
+		line("DTEND;VALUE=DATE:" + ev.First.AddDate(0, 0, 1).Format(dateLayout))
+		line("RRULE:" + rrule(ev))
+		if len(ev.Skipped) > 0 {
+			dates := make([]string, len(ev.Skipped))
+			for i, d := range ev.Skipped {
+				dates[i] = d.Format(dateLayout)
+			}
+			line("EXDATE;VALUE=DATE:" + strings.Join(dates, ","))
+		}
+		line("SUMMARY:" + escape(summary))
+		line("DESCRIPTION:" + escape(description(ev)))
+		line("TRANSP:TRANSPARENT")
+		alarm(line, "-PT15H", "Завтра списание: "+summary)
+		if ev.NoticeDays > 0 {
+			alarm(line, "-P"+strconv.Itoa(ev.NoticeDays)+"D",
+				"Последний день для отмены до списания: "+summary)
+		}
+		line("END:VEVENT")
+	}
+	line("END:VCALENDAR")
+	return b.Flush()
+}
+
+// rrule повторяет событие с шагом списаний; UNTIL — дата окончания подписки включительно.
+// Если числа ev.Day нет в части месяцев, BYMONTHDAY с BYSETPOS=-1 переносит списание на последний
+// день месяца: без этого RFC 5545 пропускает несуществующие даты.
+func rrule(ev domain.CalendarEvent) string {
+	freq, interval := "MONTHLY", ev.BillingInterval
+	switch ev.BillingPeriod {
+	case domain.PeriodWeek:
+		freq = "WEEKLY"
+	case domain.PeriodQuarter:
+		interval *= 3
+	case domain.PeriodYear:
+		freq = "YEARLY"
+	}
+	r := "FREQ=" + freq + ";INTERVAL=" + strconv.Itoa(interval)
+	if freq != "WEEKLY" && ev.Day > 28 {
+		if freq == "YEARLY" {
+			r += ";BYMONTH=" + strconv.Itoa(int(ev.First.Month()))
+		}
+		days := make([]string, 0, ev.Day-27)
+		for d := 28; d <= ev.Day; d++ {
+			days = append(days, strconv.Itoa(d))
+		}
+		r += ";BYMONTHDAY=" + strings.Join(days, ",") + ";BYSETPOS=-1"
+	}
+	if ev.Until != nil {
+		r += ";UNTIL=" + ev.Until.Format(dateLayout)
+	}
+	return r
+}
+
+func description(ev domain.CalendarEvent) string {
+	s := "Списание " + ev.Price + " " + ev.Currency
+	if ev.Until != nil {
+		s += "\nПодписка до " + ev.Until.Format("2006-01-02")
+	}
+	if ev.NoticeDays > 0 {
+		s += "\nСрок уведомления об отмене: " + strconv.Itoa(ev.NoticeDays) + " дн."
+	}
+	return s
+}
+
+func alarm(line func(string), trigger, text string) {
+	line("BEGIN:VALARM")
+	line("ACTION:DISPLAY")
+	line("TRIGGER:" + trigger)
+	line("DESCRIPTION:" + escape(text))
+	line("END:VALARM")
+}
+
+// escape экранирует TEXT-значение (RFC 5545, 3.3.11).
+func escape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`).Replace(s)
+}
+
+// fold переносит строку длиннее 75 октетов, не разрывая символы UTF-8, и завершает её CRLF.
+func fold(b *bufio.Writer, s string) {
+	limit := lineLimit
+	for len(s) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(s[cut]) {
+			cut--
+		}
+		b.WriteString(s[:cut])
+		b.WriteString("\r\n ")
+		s = s[cut:]
+		limit = lineLimit - 1
+	}
+	b.WriteString(s)
+	b.WriteString("\r\n")
+}
@@ -0,0 +1,92 @@
+package ical
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"crud_ef/internal/domain"
+)
+
+func TestFold(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want []string
+	}{
+		{"short", "SUMMARY:Netflix", []string{"SUMMARY:Netflix"}},
+		{"exactly 75", strings.Repeat("a", 75), []string{strings.Repeat("a", 75)}},
+		{"76 ascii", strings.Repeat("a", 76), []string{strings.Repeat("a", 75), " a"}},
+		{
+			"continuations are 74 octets",
+			strings.Repeat("b", 75+74+1),
+			[]string{strings.Repeat("b", 75), " " + strings.Repeat("b", 74), " b"},
+		},
+		{
+			// «я» занимает 2 октета: 37 символов — 74 октета, 38-й не помещается целиком.
+			"utf-8 not split",
+			strings.Repeat("я", 40),
+			[]string{strings.Repeat("я", 37), " " + strings.Repeat("я", 3)},
+		},
+		{"empty", "", []string{""}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sb strings.Builder
+			b := bufio.NewWriter(&sb)
+			fold(b, tt.in)
+			b.Flush()
+
+			out := sb.String()
+			if !strings.HasSuffix(out, "\r\n") {
+				t.Fatalf("output %q does not end with CRLF", out)
+			}
+			lines := strings.Split(strings.TrimSuffix(out, "\r\n"), "\r\n")
+			if len(lines) != len(tt.want) {
+				t.Fatalf("got %d lines %q, want %q", len(lines), lines, tt.want)
+			}
+			for i, l := range lines {
+				if l != tt.want[i] {
+					t.Errorf("line %d = %q, want %q", i, l, tt.want[i])
+				}
+				if len(l) > lineLimit {
+					t.Errorf("line %d is %d octets, limit %d", i, len(l), lineLimit)
+				}
+				if !utf8.ValidString(l) {
+					t.Errorf("line %d splits a UTF-8 sequence: %q", i, l)
+				}
+			}
+			unfolded := strings.ReplaceAll(strings.TrimSuffix(out, "\r\n"), "\r\n ", "")
+			if unfolded != tt.in {
+				t.Errorf("unfolded = %q, want %q", unfolded, tt.in)
+			}
+		})
+	}
+}
+
+func TestWriteSkipped(t *testing.T) {
+	ev := domain.CalendarEvent{
+		ServiceName: "Netflix", Price: "900", Currency: "RUB",
+		BillingPeriod: domain.PeriodMonth, BillingInterval: 1, Day: 31,
+		First:   time.Date(2025, 1, 31, 0, 0, 0, 0, time.UTC),
+		Skipped: []time.Time{time.Date(2025, 2, 28, 0, 0, 0, 0, time.UTC), time.Date(2025, 3, 31, 0, 0, 0, 0, time.UTC)},
+	}
+	var sb strings.Builder
+	if err := Write(&sb, "test", []domain.CalendarEvent{ev}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(sb.String(), "\r\nEXDATE;VALUE=DATE:20250228,20250331\r\n") {
+		t.Errorf("no EXDATE line in\n%s", sb.String())
+	}
+
+	sb.Reset()
+	ev.Skipped = nil
+	if err := Write(&sb, "test", []domain.CalendarEvent{ev}); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(sb.String(), "EXDATE") {
+		t.Errorf("unexpected EXDATE in\n%s", sb.String())
+	}
+}
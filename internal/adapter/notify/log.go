@@ -0,0 +1,20 @@
+package notify
+
+import (
+	"context"
+	"log"
+
+	"crud_ef/internal/domain"
+)
+
+// Log пишет оповещения о бюджетах в стандартный лог.
+type Log struct{}
+
+func (Log) BudgetAlert(_ context.Context, a domain.BudgetAlert) {
+	sub := "-"
+	if a.SubscriptionID != nil {
+		sub = a.SubscriptionID.String()
+	}
+	log.Printf("budget %s: %d%% threshold reached for %s (spent %s of %s %s, subscription %s)",
+		a.BudgetID, a.Threshold, a.Month, a.Spent, a.MonthlyLimit, a.Currency, sub)
+}
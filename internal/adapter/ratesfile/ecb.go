@@ -0,0 +1,123 @@
+// Package ratesfile читает курсы валют из локальных файлов в формате ЕЦБ.
+package ratesfile
+
+import (
+	"encoding/csv"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"crud_ef/internal/domain"
+)
+
+// ECBBase — базовая валюта в файлах ЕЦБ: все курсы указаны за 1 EUR.
+const ECBBase = "EUR"
+
+type ecbEnvelope struct {
+	Cube struct {
+		Days []struct {
+			Time  string `xml:"time,attr"`
+			Rates []struct {
+				Currency string `xml:"currency,attr"`
+				Rate     string `xml:"rate,attr"`
+			} `xml:"Cube"`
+		} `xml:"Cube"`
+	} `xml:"Cube"`
+}
+
+// ParseXML разбирает eurofxref-*.xml (gesmes:Envelope с вложенными Cube).
+func ParseXML(r io.Reader) ([]domain.ExchangeRate, error) {
+	var env ecbEnvelope
+	if err := xml.NewDecoder(r).Decode(&env); err != nil {
+		return nil, fmt.Errorf("decode xml: %w", err)
+	}
+	var out []domain.ExchangeRate
+	for _, d := range env.Cube.Days {
+		for _, rt := range d.Rates {
+			out = append(out, domain.ExchangeRate{Date: d.Time, Base: ECBBase, Quote: rt.Currency, Rate: rt.Rate})
+		}
+	}
+	return out, nil
+}
+
+// ParseCSV разбирает CSV в одном из двух видов:
+//   - широкий формат ЕЦБ (eurofxref-hist.csv): "Date,USD,JPY,..." и строки с курсами за 1 EUR,
+//     значения "N/A" и пустые колонки пропускаются;
+//   - длинный формат с заголовком "date,base,quote,rate".
+func ParseCSV(r io.Reader) ([]domain.ExchangeRate, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("read header: %w", err)
+	}
+	for i := range header {
+		header[i] = strings.TrimSpace(header[i])
+	}
+	if len(header) == 0 || !strings.EqualFold(header[0], "date") {
+		return nil, errors.New("csv: first column must be date")
+	}
+	long := len(header) == 4 &&
+		strings.EqualFold(header[1], "base") &&
+		strings.EqualFold(header[2], "quote") &&
+		strings.EqualFold(header[3], "rate")
+
+	var out []domain.ExchangeRate
+	for {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(rec) == 0 || strings.TrimSpace(rec[0]) == "" {
+			continue
+		}
+		date := strings.TrimSpace(rec[0])
+		if long {
+			if len(rec) != 4 {
+				return nil, fmt.Errorf("csv: line for %s: expected 4 fields, got %d", date, len(rec))
+			}
+			out = append(out, domain.ExchangeRate{
+				Date:  date,
+				Base:  strings.TrimSpace(rec[1]),
+				Quote: strings.TrimSpace(rec[2]),
+				Rate:  strings.TrimSpace(rec[3]),
+			})
+			continue
+		}
+		for i := 1; i < len(rec) && i < len(header); i++ {
+			v := strings.TrimSpace(rec[i])
+			if header[i] == "" || v == "" || strings.EqualFold(v, "N/A") {
+				continue
+			}
+			out = append(out, domain.ExchangeRate{Date: date, Base: ECBBase, Quote: header[i], Rate: v})
+		}
+	}
+	return out, nil
+}
+
+// ParseFile выбирает парсер по расширению файла (.xml или .csv).
+func ParseFile(path string) ([]domain.ExchangeRate, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".xml":
+		return ParseXML(f)
+	case ".csv":
+		return ParseCSV(f)
+	default:
+		return nil, fmt.Errorf("unsupported file type %q (want .xml or .csv)", filepath.Ext(path))
+	}
+}
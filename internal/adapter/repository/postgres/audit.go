@@ -0,0 +1,191 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"crud_ef/internal/domain"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// snapshot — состояние подписки в журнале (jsonb).
+type snapshot struct {
+	ID              uuid.UUID   `json:"id"`
+	ServiceName     string      `json:"service_name"`
+	ServiceID       *uuid.UUID  `json:"service_id,omitempty"`
+	Price           string      `json:"price"`
+	BillingPeriod   string      `json:"billing_period"`
+	BillingInterval int         `json:"billing_interval"`
+	MonthlyPrice    string      `json:"monthly_price"`
+	Currency        string      `json:"currency"`
+	UserID          uuid.UUID   `json:"user_id"`
+	StartMonth      string      `json:"start_month"`
+	EndMonth        *string     `json:"end_month,omitempty"`
+	TrialEndMonth   *string     `json:"trial_end_month,omitempty"`
+	Status          string      `json:"status"`
+	NoticeDays      int         `json:"notice_days"`
+	Cancellation    *cancelSnap `json:"cancellation,omitempty"`
+	Tags            []string    `json:"tags"`
+	CreatedAt       time.Time   `json:"created_at"`
+	UpdatedAt       time.Time   `json:"updated_at"`
+	DeletedAt       *time.Time  `json:"deleted_at,omitempty"`
+	Version         int         `json:"version"`
+}
+
+type cancelSnap struct {
+	RequestedAt string    `json:"requested_at"`
+	By          string    `json:"by,omitempty"`
+	Reason      string    `json:"reason,omitempty"`
+	CancelledAt time.Time `json:"cancelled_at"`
+}
+
+func marshalSnapshot(s *domain.Subscription) ([]byte, error) {
+	if s == nil {
+		return nil, nil
+	}
+	v := snapshot{
+		ID: s.ID, ServiceName: s.ServiceName, ServiceID: s.ServiceID, Price: s.Price,
+		BillingPeriod: s.BillingPeriod, BillingInterval: s.BillingInterval, MonthlyPrice: s.MonthlyPrice,
+		Currency: s.Currency, UserID: s.UserID, StartMonth: s.StartMonth, EndMonth: s.EndMonth,
+		TrialEndMonth: s.TrialEndMonth, Status: s.Status, NoticeDays: s.NoticeDays, Tags: s.Tags,
+		CreatedAt: s.CreatedAt, UpdatedAt: s.UpdatedAt, DeletedAt: s.DeletedAt, Version: s.Version,
+	}
+	if c := s.Cancellation; c != nil {
+		v.Cancellation = &cancelSnap{RequestedAt: c.RequestedAt, By: c.By, Reason: c.Reason, CancelledAt: c.CancelledAt}
+	}
+	return json.Marshal(v)
+}
+
+func unmarshalSnapshot(data []byte) (*domain.Subscription, error) {
+	if data == nil {
+		return nil, nil
+	}
+	var v snapshot
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, err
+	}
+	s := &domain.Subscription{
+		ID: v.ID, ServiceName: v.ServiceName, ServiceID: v.ServiceID, Price: v.Price,
+		BillingPeriod: v.BillingPeriod, BillingInterval: v.BillingInterval, MonthlyPrice: v.MonthlyPrice,
+		Currency: v.Currency, UserID: v.UserID, StartMonth: v.StartMonth, EndMonth: v.EndMonth,
+		TrialEndMonth: v.TrialEndMonth, Status: v.Status, NoticeDays: v.NoticeDays, Tags: v.Tags,
+		CreatedAt: v.CreatedAt, UpdatedAt: v.UpdatedAt, DeletedAt: v.DeletedAt, Version: v.Version,
+	}
+	if c := v.Cancellation; c != nil {
+		s.Cancellation = &domain.Cancellation{RequestedAt: c.RequestedAt, By: c.By, Reason: c.Reason, CancelledAt: c.CancelledAt}
+	}
+	return s, nil
+}
+
+// lockSubscription читает подписку (удалённую, если deleted) для снимка «до» и блокирует её
+// до конца транзакции.
+func lockSubscription(ctx context.Context, tx pgx.Tx, id uuid.UUID, deleted bool) (domain.Subscription, error) {
+	q := `
+SELECT ` + subscriptionColumns + `
+FROM subscriptions
+WHERE id = $1 AND (deleted_at IS NOT NULL) = $2
+FOR UPDATE;
+`
+	var s domain.Subscription
+	err := scanSubscription(tx.QueryRow(ctx, q, id, deleted), &s)
+	return s, err
+}
+
+// touchSubscription отмечает изменение связанных с подпиской данных (цены, паузы, участники):
+// увеличивает версию и пишет событие action; before — снимок из lockSubscription.
+func touchSubscription(ctx context.Context, tx pgx.Tx, before *domain.Subscription, action string) error {
+	q := `
+UPDATE subscriptions SET updated_at = now(), version = version + 1
+WHERE id = $1
+RETURNING ` + subscriptionColumns + `;
+`
+	var after domain.Subscription
+	if err := scanSubscription(tx.QueryRow(ctx, q, before.ID), &after); err != nil {
+		return err
+	}
+	return recordEvent(ctx, tx, before.ID, action, before, &after)
+}
+
+// recordEvent пишет событие в журнал в транзакции изменения; автор берётся из контекста.
+func recordEvent(ctx context.Context, tx pgx.Tx, id uuid.UUID, action string, before, after *domain.Subscription) error {
+	b, err := marshalSnapshot(before)
+	if err != nil {
+		return err
+	}
+	a, err := marshalSnapshot(after)
+	if err != nil {
+		return err
+	}
+	audit := domain.AuditFrom(ctx)
+	_, err = tx.Exec(ctx, `
+INSERT INTO subscription_events (subscription_id, action, actor, request_id, before, after)
+VALUES ($1, $2, NULLIF($3, ''), NULLIF($4, ''), $5, $6)`,
+		id, action, audit.Actor, audit.RequestID, b, a)
+	return err
+}
+
+// Events возвращает записи журнала в порядке записи.
+func (r *SubscriptionRepo) Events(ctx context.Context, f domain.EventFilter) ([]domain.Event, error) {
+	var (
+		whr  []string
+		args []any
+	)
+	if f.SubscriptionID != nil {
+		args = append(args, *f.SubscriptionID)
+		whr = append(whr, "subscription_id = $"+strconv.Itoa(len(args)))
+	}
+	if f.Actor != nil {
+		args = append(args, *f.Actor)
+		whr = append(whr, "actor = $"+strconv.Itoa(len(args)))
+	}
+	if f.From != nil {
+		args = append(args, *f.From)
+		whr = append(whr, "created_at >= $"+strconv.Itoa(len(args)))
+	}
+	if f.To != nil {
+		args = append(args, *f.To)
+		whr = append(whr, "created_at < $"+strconv.Itoa(len(args)))
+	}
+	where := ""
+	if len(whr) > 0 {
+		where = "WHERE " + strings.Join(whr, " AND ")
+	}
+	args = append(args, f.Limit, f.Offset)
+
+	q := `
+SELECT id, subscription_id, action, COALESCE(actor, ''), COALESCE(request_id, ''), before, after, created_at
+FROM subscription_events
+` + where + `
+ORDER BY id
+LIMIT $` + strconv.Itoa(len(args)-1) + ` OFFSET $` + strconv.Itoa(len(args)) + `;
+`
+	rows, err := r.pool.Query(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []domain.Event{}
+	for rows.Next() {
+		var (
+			e             domain.Event
+			before, after []byte
+		)
+		if err := rows.Scan(&e.ID, &e.SubscriptionID, &e.Action, &e.Actor, &e.RequestID, &before, &after, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		if e.Before, err = unmarshalSnapshot(before); err != nil {
+			return nil, err
+		}
+		if e.After, err = unmarshalSnapshot(after); err != nil {
+			return nil, err
+		}
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,150 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"crud_ef/internal/domain"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type BudgetRepo struct {
+	pool *pgxpool.Pool
+}
+
+func NewBudgetRepo(pool *pgxpool.Pool) *BudgetRepo {
+	return &BudgetRepo{pool: pool}
+}
+
+const budgetColumns = `id, scope, user_id, service_name, monthly_limit::text, currency, mode, thresholds, created_at, updated_at`
+
+// scanBudget сканирует бюджет; отсутствие строки возвращается как domain.ErrNotFound.
+func scanBudget(row pgx.Row, b *domain.Budget) error {
+	err := row.Scan(&b.ID, &b.Scope, &b.UserID, &b.ServiceName, &b.MonthlyLimit, &b.Currency, &b.Mode, &b.Thresholds, &b.CreatedAt, &b.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return domain.ErrNotFound
+	}
+	return err
+}
+
+func (r *BudgetRepo) Create(ctx context.Context, in domain.BudgetInput) (domain.Budget, error) {
+	q := `
+INSERT INTO budgets (scope, user_id, service_name, monthly_limit, currency, mode, thresholds)
+VALUES ($1, $2, $3, $4::numeric(12,2), $5, $6, $7)
+RETURNING ` + budgetColumns + `;
+`
+	var b domain.Budget
+	err := scanBudget(r.pool.QueryRow(ctx, q,
+		in.Scope, in.UserID, in.ServiceName, in.MonthlyLimit, in.Currency, in.Mode, in.Thresholds,
+	), &b)
+	return b, err
+}
+
+func (r *BudgetRepo) Get(ctx context.Context, id uuid.UUID) (domain.Budget, error) {
+	var b domain.Budget
+	err := scanBudget(r.pool.QueryRow(ctx, `SELECT `+budgetColumns+` FROM budgets WHERE id = $1`, id), &b)
+	return b, err
+}
+
+func (r *BudgetRepo) List(ctx context.Context) ([]domain.Budget, error) {
+	return r.query(ctx, `SELECT `+budgetColumns+` FROM budgets ORDER BY created_at`)
+}
+
+func (r *BudgetRepo) Update(ctx context.Context, id uuid.UUID, in domain.BudgetInput) (domain.Budget, error) {
+	q := `
+UPDATE budgets
+SET scope = $2, user_id = $3, service_name = $4, monthly_limit = $5::numeric(12,2),
+    currency = $6, mode = $7, thresholds = $8, updated_at = now()
+WHERE id = $1
+RETURNING ` + budgetColumns + `;
+`
+	var b domain.Budget
+	err := scanBudget(r.pool.QueryRow(ctx, q,
+		id, in.Scope, in.UserID, in.ServiceName, in.MonthlyLimit, in.Currency, in.Mode, in.Thresholds,
+	), &b)
+	return b, err
+}
+
+func (r *BudgetRepo) Delete(ctx context.Context, id uuid.UUID) (bool, error) {
+	cmd, err := r.pool.Exec(ctx, `DELETE FROM budgets WHERE id = $1`, id)
+	if err != nil {
+		return false, err
+	}
+	return cmd.RowsAffected() > 0, nil
+}
+
+// Matching подбирает бюджеты по тем же правилам, что и Budget.Filter: бюджет пользователя —
+// владельца или участника подписки, бюджет сервиса — с тем же названием после normalize_service_name.
+func (r *BudgetRepo) Matching(ctx context.Context, sub domain.Subscription) ([]domain.Budget, error) {
+	q := `
+SELECT ` + budgetColumns + `
+FROM budgets b
+WHERE b.scope = 'global'
+   OR (b.scope = 'user' AND (b.user_id = $2
+       OR EXISTS (SELECT 1 FROM subscription_members sm WHERE sm.subscription_id = $1 AND sm.user_id = b.user_id)))
+   OR (b.scope = 'service' AND normalize_service_name(b.service_name) = normalize_service_name($3))
+ORDER BY b.created_at;
+`
+	return r.query(ctx, q, sub.ID, sub.UserID, sub.ServiceName)
+}
+
+func (r *BudgetRepo) query(ctx context.Context, q string, args ...any) ([]domain.Budget, error) {
+	rows, err := r.pool.Query(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []domain.Budget
+	for rows.Next() {
+		var b domain.Budget
+		if err := scanBudget(rows, &b); err != nil {
+			return nil, err
+		}
+		out = append(out, b)
+	}
+	return out, rows.Err()
+}
+
+func (r *BudgetRepo) AddAlert(ctx context.Context, a domain.BudgetAlert) (bool, error) {
+	month, _ := time.Parse("2006-01", a.Month)
+	cmd, err := r.pool.Exec(ctx, `
+INSERT INTO budget_alerts (budget_id, month, threshold, spent, subscription_id, created_at)
+VALUES ($1, $2, $3, $4::numeric(14,2), $5, $6)
+ON CONFLICT (budget_id, month, threshold) DO NOTHING;
+`, a.BudgetID, month, a.Threshold, a.Spent, a.SubscriptionID, a.CreatedAt)
+	if err != nil {
+		return false, err
+	}
+	return cmd.RowsAffected() > 0, nil
+}
+
+func (r *BudgetRepo) Alerts(ctx context.Context, id uuid.UUID) ([]domain.BudgetAlert, error) {
+	q := `
+SELECT a.budget_id, to_char(a.month, 'YYYY-MM'), a.threshold, a.spent::text,
+       b.monthly_limit::text, b.currency, a.subscription_id, a.created_at
+FROM budget_alerts a
+JOIN budgets b ON b.id = a.budget_id
+WHERE a.budget_id = $1
+ORDER BY a.month DESC, a.threshold;
+`
+	rows, err := r.pool.Query(ctx, q, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []domain.BudgetAlert
+	for rows.Next() {
+		var a domain.BudgetAlert
+		if err := rows.Scan(&a.BudgetID, &a.Month, &a.Threshold, &a.Spent, &a.MonthlyLimit, &a.Currency, &a.SubscriptionID, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, a)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,66 @@
+package postgres_test
+
+import (
+	"context"
+	"testing"
+
+	"crud_ef/internal/adapter/repository/postgres"
+	"crud_ef/internal/domain"
+	"crud_ef/internal/usecase/subscription"
+
+	"github.com/google/uuid"
+)
+
+func TestBudgetMatching(t *testing.T) {
+	ctx := context.Background()
+	pool := testPool(t)
+	subs := subscription.NewService(postgres.NewSubscriptionRepo(pool))
+	budgets := postgres.NewBudgetRepo(pool)
+
+	owner, member, other := uuid.New(), uuid.New(), uuid.New()
+	sub, err := subs.Create(ctx, domain.CreateInput{
+		ServiceName: "Netflix", Price: "900", Currency: "RUB", UserID: owner, StartMonth: "2025-01",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := subs.SetMembers(ctx, sub.ID, []domain.Member{{UserID: member, Share: domain.ShareEqual}}); err != nil {
+		t.Fatal(err)
+	}
+
+	create := func(scope string, userID *uuid.UUID, service *string) uuid.UUID {
+		b, err := budgets.Create(ctx, domain.BudgetInput{
+			Scope: scope, UserID: userID, ServiceName: service,
+			MonthlyLimit: "1000", Currency: "RUB", Mode: domain.TotalModeCharges, Thresholds: domain.DefaultThresholds,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b.ID
+	}
+	name := func(s string) *string { return &s }
+	want := map[uuid.UUID]bool{
+		create(domain.BudgetScopeGlobal, nil, nil):                true,
+		create(domain.BudgetScopeUser, &owner, nil):               true,
+		create(domain.BudgetScopeUser, &member, nil):              true,
+		create(domain.BudgetScopeUser, &other, nil):               false,
+		create(domain.BudgetScopeService, nil, name(" netflix ")): true,
+		// Подстрока названия не считается тем же сервисом.
+		create(domain.BudgetScopeService, nil, name("Net")): false,
+		create(domain.BudgetScopeService, nil, name("%")):   false,
+	}
+
+	got, err := budgets.Matching(ctx, sub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	matched := make(map[uuid.UUID]bool, len(got))
+	for _, b := range got {
+		matched[b.ID] = true
+	}
+	for id, w := range want {
+		if matched[id] != w {
+			t.Errorf("budget %s matched = %v, want %v", id, matched[id], w)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+
+	"crud_ef/internal/domain"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type CalendarRepo struct {
+	pool *pgxpool.Pool
+}
+
+func NewCalendarRepo(pool *pgxpool.Pool) *CalendarRepo {
+	return &CalendarRepo{pool: pool}
+}
+
+func (r *CalendarRepo) SetToken(ctx context.Context, userID uuid.UUID, hash []byte) error {
+	q := `
+INSERT INTO calendar_tokens (user_id, token_hash)
+VALUES ($1, $2)
+ON CONFLICT (user_id) DO UPDATE SET token_hash = EXCLUDED.token_hash, created_at = now();
+`
+	_, err := r.pool.Exec(ctx, q, userID, hash)
+	return err
+}
+
+func (r *CalendarRepo) TokenHash(ctx context.Context, userID uuid.UUID) ([]byte, error) {
+	var hash []byte
+	err := r.pool.QueryRow(ctx, `SELECT token_hash FROM calendar_tokens WHERE user_id = $1`, userID).Scan(&hash)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, domain.ErrNotFound
+	}
+	return hash, err
+}
+
+func (r *CalendarRepo) DeleteToken(ctx context.Context, userID uuid.UUID) (bool, error) {
+	tag, err := r.pool.Exec(ctx, `DELETE FROM calendar_tokens WHERE user_id = $1`, userID)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
@@ -0,0 +1,205 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+
+	"crud_ef/internal/domain"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type CatalogRepo struct {
+	pool *pgxpool.Pool
+}
+
+func NewCatalogRepo(pool *pgxpool.Pool) *CatalogRepo {
+	return &CatalogRepo{pool: pool}
+}
+
+const catalogColumns = `c.id, c.name, c.aliases, c.category, c.vendor_url,
+       (SELECT count(*) FROM subscriptions s WHERE s.service_id = c.id AND s.deleted_at IS NULL)::int,
+       c.created_at, c.updated_at`
+
+// scanCatalog сканирует запись каталога; отсутствие строки — domain.ErrNotFound,
+// повтор названия — domain.ErrAlreadyExists.
+func scanCatalog(row pgx.Row, c *domain.CatalogService) error {
+	err := row.Scan(&c.ID, &c.Name, &c.Aliases, &c.Category, &c.VendorURL, &c.Subscriptions, &c.CreatedAt, &c.UpdatedAt)
+	var pgErr *pgconn.PgError
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		return domain.ErrNotFound
+	case errors.As(err, &pgErr) && pgErr.Code == "23505":
+		return domain.ErrAlreadyExists
+	}
+	return err
+}
+
+// linkSubscriptions привязывает к сервису подписки без service_id, название которых совпадает
+// с его именем или псевдонимом, и возвращает их число. Каждая привязка пишется в журнал как update.
+func linkSubscriptions(ctx context.Context, tx pgx.Tx, id uuid.UUID) (int, error) {
+	rows, err := tx.Query(ctx, `
+SELECT `+subscriptionColumns+`
+FROM subscriptions
+WHERE service_id IS NULL AND match_service(service_name) = $1
+FOR UPDATE;
+`, id)
+	if err != nil {
+		return 0, err
+	}
+	var linked []domain.Subscription
+	for rows.Next() {
+		var s domain.Subscription
+		if err := scanSubscription(rows, &s); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		linked = append(linked, s)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	q := `
+UPDATE subscriptions SET service_id = $2, updated_at = now(), version = version + 1
+WHERE id = $1
+RETURNING ` + subscriptionColumns + `;
+`
+	for i := range linked {
+		var after domain.Subscription
+		if err := scanSubscription(tx.QueryRow(ctx, q, linked[i].ID, id), &after); err != nil {
+			return 0, err
+		}
+		if err := recordEvent(ctx, tx, after.ID, domain.EventUpdate, &linked[i], &after); err != nil {
+			return 0, err
+		}
+	}
+	return len(linked), nil
+}
+
+func (r *CatalogRepo) Create(ctx context.Context, in domain.CatalogServiceInput) (domain.CatalogService, error) {
+	var c domain.CatalogService
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return c, err
+	}
+	defer tx.Rollback(ctx)
+
+	q := `
+WITH c AS (
+  INSERT INTO services (name, aliases, category, vendor_url)
+  VALUES ($1, $2, $3, $4)
+  RETURNING *
+)
+SELECT ` + catalogColumns + ` FROM c;
+`
+	if err := scanCatalog(tx.QueryRow(ctx, q, in.Name, in.Aliases, in.Category, in.VendorURL), &c); err != nil {
+		return c, err
+	}
+	n, err := linkSubscriptions(ctx, tx, c.ID)
+	if err != nil {
+		return c, err
+	}
+	c.Subscriptions += n
+	return c, tx.Commit(ctx)
+}
+
+func (r *CatalogRepo) Get(ctx context.Context, id uuid.UUID) (domain.CatalogService, error) {
+	var c domain.CatalogService
+	err := scanCatalog(r.pool.QueryRow(ctx, `SELECT `+catalogColumns+` FROM services c WHERE c.id = $1`, id), &c)
+	return c, err
+}
+
+// Match ищет в каталоге сервис по названию или псевдониму; nil — совпадений нет.
+func (r *CatalogRepo) Match(ctx context.Context, name string) (*uuid.UUID, error) {
+	var id *uuid.UUID
+	err := r.pool.QueryRow(ctx, `SELECT match_service($1)`, name).Scan(&id)
+	return id, err
+}
+
+func (r *CatalogRepo) List(ctx context.Context, f domain.CatalogFilter) ([]domain.CatalogService, error) {
+	var args []any
+	var whr []string
+	idx := 1
+	if f.Query != nil && *f.Query != "" {
+		p := "$" + strconv.Itoa(idx)
+		whr = append(whr, "(c.name ILIKE '%'||"+p+"||'%' OR EXISTS (SELECT 1 FROM unnest(c.aliases) a WHERE a ILIKE '%'||"+p+"||'%'))")
+		args = append(args, *f.Query)
+		idx++
+	}
+	if f.Category != nil && *f.Category != "" {
+		whr = append(whr, "c.category = $"+strconv.Itoa(idx))
+		args = append(args, *f.Category)
+		idx++
+	}
+	where := ""
+	if len(whr) > 0 {
+		where = "WHERE " + strings.Join(whr, " AND ")
+	}
+
+	q := `
+SELECT ` + catalogColumns + `
+FROM services c
+` + where + `
+ORDER BY c.name;
+`
+	rows, err := r.pool.Query(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []domain.CatalogService{}
+	for rows.Next() {
+		var c domain.CatalogService
+		if err := scanCatalog(rows, &c); err != nil {
+			return nil, err
+		}
+		out = append(out, c)
+	}
+	return out, rows.Err()
+}
+
+// Update заменяет запись каталога и привязывает подписки, подходящие под новые псевдонимы.
+func (r *CatalogRepo) Update(ctx context.Context, id uuid.UUID, in domain.CatalogServiceInput) (domain.CatalogService, error) {
+	var c domain.CatalogService
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return c, err
+	}
+	defer tx.Rollback(ctx)
+
+	q := `
+WITH c AS (
+  UPDATE services
+  SET name = $2, aliases = $3, category = $4, vendor_url = $5, updated_at = now()
+  WHERE id = $1
+  RETURNING *
+)
+SELECT ` + catalogColumns + ` FROM c;
+`
+	if err := scanCatalog(tx.QueryRow(ctx, q, id, in.Name, in.Aliases, in.Category, in.VendorURL), &c); err != nil {
+		return c, err
+	}
+	n, err := linkSubscriptions(ctx, tx, c.ID)
+	if err != nil {
+		return c, err
+	}
+	c.Subscriptions += n
+	return c, tx.Commit(ctx)
+}
+
+// Delete удаляет запись каталога; подписки остаются без service_id.
+func (r *CatalogRepo) Delete(ctx context.Context, id uuid.UUID) (bool, error) {
+	cmd, err := r.pool.Exec(ctx, `DELETE FROM services WHERE id = $1`, id)
+	if err != nil {
+		return false, err
+	}
+	return cmd.RowsAffected() > 0, nil
+}
@@ -0,0 +1,108 @@
+package postgres
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"crud_ef/internal/domain"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type ExchangeRateRepo struct {
+	pool *pgxpool.Pool
+}
+
+func NewExchangeRateRepo(pool *pgxpool.Pool) *ExchangeRateRepo {
+	return &ExchangeRateRepo{pool: pool}
+}
+
+// Upsert сохраняет курсы одной транзакцией; существующий курс на ту же дату перезаписывается.
+func (r *ExchangeRateRepo) Upsert(ctx context.Context, rates []domain.ExchangeRate) (int, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	q := `
+INSERT INTO exchange_rates (rate_date, base, quote, rate)
+VALUES ($1, $2, $3, $4::numeric(18,8))
+ON CONFLICT (rate_date, base, quote) DO UPDATE SET rate = EXCLUDED.rate;
+`
+	batch := &pgx.Batch{}
+	for _, rt := range rates {
+		d, _ := time.Parse("2006-01-02", rt.Date)
+		batch.Queue(q, d, rt.Base, rt.Quote, rt.Rate)
+	}
+	if err := tx.SendBatch(ctx, batch).Close(); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return 0, err
+	}
+	return len(rates), nil
+}
+
+func (r *ExchangeRateRepo) List(ctx context.Context, f domain.RateFilter) ([]domain.ExchangeRate, error) {
+	var args []any
+	var whr []string
+	idx := 1
+	if f.Base != nil {
+		whr = append(whr, "base = $"+strconv.Itoa(idx))
+		args = append(args, *f.Base)
+		idx++
+	}
+	if f.Quote != nil {
+		whr = append(whr, "quote = $"+strconv.Itoa(idx))
+		args = append(args, *f.Quote)
+		idx++
+	}
+	if f.From != nil {
+		whr = append(whr, "rate_date >= $"+strconv.Itoa(idx)+"::date")
+		args = append(args, *f.From)
+		idx++
+	}
+	if f.To != nil {
+		whr = append(whr, "rate_date <= $"+strconv.Itoa(idx)+"::date")
+		args = append(args, *f.To)
+		idx++
+	}
+	where := ""
+	if len(whr) > 0 {
+		where = "WHERE " + strings.Join(whr, " AND ")
+	}
+
+	q := `
+SELECT to_char(rate_date, 'YYYY-MM-DD'), base, quote, trim_scale(rate)::text
+FROM exchange_rates
+` + where + `
+ORDER BY rate_date DESC, base, quote;
+`
+	rows, err := r.pool.Query(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []domain.ExchangeRate
+	for rows.Next() {
+		var rt domain.ExchangeRate
+		if err := rows.Scan(&rt.Date, &rt.Base, &rt.Quote, &rt.Rate); err != nil {
+			return nil, err
+		}
+		out = append(out, rt)
+	}
+	return out, rows.Err()
+}
+
+func (r *ExchangeRateRepo) Delete(ctx context.Context, date time.Time, base, quote string) (bool, error) {
+	cmd, err := r.pool.Exec(ctx, `DELETE FROM exchange_rates WHERE rate_date = $1 AND base = $2 AND quote = $3`, date, base, quote)
+	if err != nil {
+		return false, err
+	}
+	return cmd.RowsAffected() > 0, nil
+}
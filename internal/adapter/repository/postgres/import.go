@@ -0,0 +1,209 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"crud_ef/internal/domain"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// copyThreshold — с какого числа подписок импорт идёт через COPY вместо отдельных INSERT.
+const copyThreshold = 100
+
+// Import создаёт подписки в одной транзакции; subs и conflicts идут в порядке items. При
+// checkOverlap пересечения с существующими подписками ищутся в той же транзакции одним
+// запросом; пересекающиеся items не создаются. При dryRun транзакция откатывается.
+func (r *SubscriptionRepo) Import(ctx context.Context, items []domain.CreateInput, checkOverlap, dryRun bool) ([]domain.Subscription, [][]uuid.UUID, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	conflicts := make([][]uuid.UUID, len(items))
+	if checkOverlap {
+		if err := importOverlaps(ctx, tx, items, conflicts); err != nil {
+			return nil, nil, err
+		}
+	}
+	subs := make([]domain.Subscription, len(items))
+	if dryRun {
+		return subs, conflicts, nil
+	}
+	var create []domain.CreateInput
+	var pos []int
+	for i, in := range items {
+		if len(conflicts[i]) == 0 {
+			create = append(create, in)
+			pos = append(pos, i)
+		}
+	}
+	if len(create) == 0 {
+		return subs, conflicts, nil
+	}
+
+	var out []domain.Subscription
+	if len(create) < copyThreshold {
+		for _, in := range create {
+			s, err := insertSubscription(ctx, tx, in)
+			if err != nil {
+				return nil, nil, err
+			}
+			out = append(out, s)
+		}
+	} else if out, err = copySubscriptions(ctx, tx, create); err != nil {
+		return nil, nil, err
+	}
+	if err := copyEvents(ctx, tx, domain.EventCreate, out); err != nil {
+		return nil, nil, err
+	}
+	for j, s := range out {
+		subs[pos[j]] = s
+	}
+	return subs, conflicts, tx.Commit(ctx)
+}
+
+// importOverlaps записывает в conflicts[i] существующие подписки, пересекающиеся с items[i]
+// по правилам Overlapping. Строки передаются массивами и разворачиваются через unnest.
+func importOverlaps(ctx context.Context, tx pgx.Tx, items []domain.CreateInput, conflicts [][]uuid.UUID) error {
+	users := make([]string, len(items))
+	names := make([]string, len(items))
+	serviceIDs := make([]*string, len(items))
+	starts := make([]string, len(items))
+	ends := make([]*string, len(items))
+	for i, in := range items {
+		users[i], names[i], starts[i], ends[i] = in.UserID.String(), in.ServiceName, in.StartMonth, in.EndMonth
+		if in.ServiceID != nil {
+			id := in.ServiceID.String()
+			serviceIDs[i] = &id
+		}
+	}
+	q := `
+SELECT i.n, s.id
+FROM unnest($1::text[], $2::text[], $3::text[], $4::text[], $5::text[]) WITH ORDINALITY
+       AS i(user_id, service_name, service_id, start_date, end_date, n)
+JOIN subscriptions s
+  ON s.user_id = i.user_id::uuid
+ AND s.deleted_at IS NULL
+ AND (normalize_service_name(s.service_name) = normalize_service_name(i.service_name) OR s.service_id = i.service_id::uuid)
+ AND daterange(s.start_date, s.end_date, '[]') && daterange(i.start_date::date, i.end_date::date, '[]')
+ORDER BY i.n, s.start_date, s.created_at;
+`
+	rows, err := tx.Query(ctx, q, users, names, serviceIDs, starts, ends)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var n int
+		var id uuid.UUID
+		if err := rows.Scan(&n, &id); err != nil {
+			return err
+		}
+		conflicts[n-1] = append(conflicts[n-1], id)
+	}
+	return rows.Err()
+}
+
+// copySubscriptions вставляет подписки, их начальные цены и теги через COPY. ID создаются
+// заранее, чтобы связать строки таблиц без RETURNING.
+func copySubscriptions(ctx context.Context, tx pgx.Tx, items []domain.CreateInput) ([]domain.Subscription, error) {
+	ids := make([]uuid.UUID, len(items))
+	subs := make([][]any, 0, len(items))
+	prices := make([][]any, 0, len(items))
+	var tags [][]any
+	for i, in := range items {
+		ids[i] = uuid.New()
+		var price pgtype.Numeric
+		if err := price.Scan(in.Price); err != nil {
+			return nil, err
+		}
+		start, _ := time.Parse("2006-01-02", in.StartMonth)
+		var end, trialEnd any
+		if in.EndMonth != nil {
+			end, _ = time.Parse("2006-01-02", *in.EndMonth)
+		}
+		if in.TrialEndMonth != nil {
+			trialEnd, _ = time.Parse("2006-01-02", *in.TrialEndMonth)
+		}
+		subs = append(subs, []any{
+			ids[i], in.ServiceName, price, in.BillingPeriod, in.BillingInterval, in.Currency, in.UserID,
+			start, end, trialEnd, in.NoticeDays, in.ServiceID,
+		})
+		month := time.Date(start.Year(), start.Month(), 1, 0, 0, 0, 0, time.UTC)
+		prices = append(prices, []any{ids[i], month, price})
+		for _, t := range in.Tags {
+			tags = append(tags, []any{ids[i], t})
+		}
+	}
+
+	_, err := tx.CopyFrom(ctx, pgx.Identifier{"subscriptions"},
+		[]string{"id", "service_name", "price", "billing_period", "billing_interval", "currency", "user_id",
+			"start_date", "end_date", "trial_end", "notice_days", "service_id"},
+		pgx.CopyFromRows(subs))
+	if err != nil {
+		return nil, err
+	}
+	_, err = tx.CopyFrom(ctx, pgx.Identifier{"subscription_prices"},
+		[]string{"subscription_id", "effective_month", "price"}, pgx.CopyFromRows(prices))
+	if err != nil {
+		return nil, err
+	}
+	if len(tags) > 0 {
+		_, err = tx.CopyFrom(ctx, pgx.Identifier{"subscription_tags"},
+			[]string{"subscription_id", "tag"}, pgx.CopyFromRows(tags))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	rows, err := tx.Query(ctx, `SELECT `+subscriptionColumns+` FROM subscriptions WHERE id = ANY($1)`, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	byID := make(map[uuid.UUID]domain.Subscription, len(ids))
+	for rows.Next() {
+		var s domain.Subscription
+		if err := scanSubscription(rows, &s); err != nil {
+			return nil, err
+		}
+		byID[s.ID] = s
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	out := make([]domain.Subscription, len(ids))
+	for i, id := range ids {
+		out[i] = byID[id]
+	}
+	return out, nil
+}
+
+// copyEvents пишет в журнал события action для подписок (снимок «после»).
+func copyEvents(ctx context.Context, tx pgx.Tx, action string, subs []domain.Subscription) error {
+	audit := domain.AuditFrom(ctx)
+	var actor, requestID *string
+	if audit.Actor != "" {
+		actor = &audit.Actor
+	}
+	if audit.RequestID != "" {
+		requestID = &audit.RequestID
+	}
+	rows := make([][]any, 0, len(subs))
+	for i := range subs {
+		after, err := marshalSnapshot(&subs[i])
+		if err != nil {
+			return err
+		}
+		rows = append(rows, []any{subs[i].ID, action, actor, requestID, after})
+	}
+	_, err := tx.CopyFrom(ctx, pgx.Identifier{"subscription_events"},
+		[]string{"subscription_id", "action", "actor", "request_id", "after"}, pgx.CopyFromRows(rows))
+	return err
+}
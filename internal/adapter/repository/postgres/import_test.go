@@ -0,0 +1,62 @@
+package postgres_test
+
+import (
+	"context"
+	"testing"
+
+	"crud_ef/internal/adapter/repository/postgres"
+	"crud_ef/internal/domain"
+	"crud_ef/internal/usecase/subscription"
+
+	"github.com/google/uuid"
+)
+
+func TestImportOverlap(t *testing.T) {
+	ctx := context.Background()
+	svc := subscription.NewService(postgres.NewSubscriptionRepo(testPool(t)))
+	user := uuid.New()
+	existing, err := svc.Create(ctx, domain.CreateInput{
+		ServiceName: "Netflix", Price: "900", Currency: "RUB", UserID: user, StartMonth: "2025-01",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	row := func(line int, name, start string) domain.ImportRow {
+		return domain.ImportRow{Line: line, Input: domain.CreateInput{
+			ServiceName: name, Price: "300", Currency: "RUB", UserID: user, StartMonth: start,
+		}}
+	}
+	rows := []domain.ImportRow{
+		row(2, " netflix ", "2025-06"),
+		row(3, "Spotify", "2025-01"),
+		row(4, "spotify", "2025-03"),
+		row(5, "Netflix", "2024-01"),
+	}
+	rows[3].Input.EndMonth = ptr("2024-12")
+
+	for _, dryRun := range []bool{true, false} {
+		results, err := svc.Import(ctx, rows, dryRun, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ok := domain.ImportCreated
+		if dryRun {
+			ok = domain.ImportValid
+		}
+		want := []string{domain.ImportDuplicate, ok, domain.ImportDuplicate, ok}
+		for i, r := range results {
+			if r.Status != want[i] {
+				t.Errorf("dryRun=%v line %d: status %q, want %q", dryRun, r.Line, r.Status, want[i])
+			}
+		}
+		if len(results[0].Conflicts) != 1 || results[0].Conflicts[0] != existing.ID {
+			t.Errorf("dryRun=%v: conflicts = %v, want [%s]", dryRun, results[0].Conflicts, existing.ID)
+		}
+		if results[2].DuplicateOf != 3 {
+			t.Errorf("dryRun=%v: duplicate_of = %d, want 3", dryRun, results[2].DuplicateOf)
+		}
+	}
+}
+
+func ptr(s string) *string { return &s }
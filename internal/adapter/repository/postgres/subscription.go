@@ -2,6 +2,7 @@ package postgres
 
 import (
 	"context"
+	"errors"
 	"strconv"
 	"strings"
 	"time"
@@ -9,9 +10,66 @@ import (
 	"crud_ef/internal/domain"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+// endDateExpr форматирует последний день периода: YYYY-MM, если это конец месяца, иначе YYYY-MM-DD.
+func endDateExpr(col string) string {
+	return `CASE WHEN ` + col + ` IS NULL THEN NULL
+            WHEN ` + col + ` = (date_trunc('month', ` + col + `) + interval '1 month' - interval '1 day')::date
+            THEN to_char(` + col + `, 'YYYY-MM') ELSE to_char(` + col + `, 'YYYY-MM-DD') END`
+}
+
+// subscriptionColumns — общий список колонок для SELECT/RETURNING, порядок совпадает со scanSubscription.
+// Даты без дневной точности (с первого по последнее число месяца) отдаются как YYYY-MM.
+// price — цена, действующая в текущем месяце по истории subscription_prices.
+var subscriptionColumns = `id, service_name, service_id, price_at(id, price, current_date)::text AS price,
+       billing_period, billing_interval,
+       ROUND(monthly_equivalent(price_at(id, price, current_date), billing_period, billing_interval), 2)::text AS monthly_price,
+       currency, user_id,
+       CASE WHEN extract(day FROM start_date) = 1
+            THEN to_char(start_date, 'YYYY-MM') ELSE to_char(start_date, 'YYYY-MM-DD') END AS start_month,
+       ` + endDateExpr("end_date") + ` AS end_month,
+       ` + endDateExpr("trial_end") + ` AS trial_end_month,
+       ` + statusSQL + ` AS status,
+       notice_days,
+       to_char(cancel_requested_at, 'YYYY-MM-DD'), cancelled_at, COALESCE(cancelled_by, ''), COALESCE(cancel_reason, ''),
+       ARRAY(SELECT t.tag FROM subscription_tags t WHERE t.subscription_id = id ORDER BY t.tag) AS tags,
+       created_at, updated_at, deleted_at, version`
+
+// statusSQL — статус подписки на текущую дату.
+const statusSQL = `CASE WHEN end_date < current_date THEN 'ended'
+            WHEN start_date > current_date THEN 'upcoming'
+            WHEN is_paused(id, current_date) THEN 'paused'
+            ELSE 'active' END`
+
+func scanSubscription(row pgx.Row, s *domain.Subscription) error {
+	var (
+		cancelRequested *string
+		cancelledAt     *time.Time
+		c               domain.Cancellation
+	)
+	err := row.Scan(
+		&s.ID, &s.ServiceName, &s.ServiceID, &s.Price, &s.BillingPeriod, &s.BillingInterval, &s.MonthlyPrice, &s.Currency, &s.UserID, &s.StartMonth, &s.EndMonth,
+		&s.TrialEndMonth, &s.Status, &s.NoticeDays,
+		&cancelRequested, &cancelledAt, &c.By, &c.Reason,
+		&s.Tags, &s.CreatedAt, &s.UpdatedAt, &s.DeletedAt, &s.Version,
+	)
+	if err != nil {
+		return err
+	}
+	s.Cancellation = nil
+	if cancelledAt != nil {
+		c.CancelledAt = *cancelledAt
+		if cancelRequested != nil {
+			c.RequestedAt = *cancelRequested
+		}
+		s.Cancellation = &c
+	}
+	return nil
+}
+
 type SubscriptionRepo struct {
 	pool *pgxpool.Pool
 }
@@ -21,92 +79,287 @@ func NewSubscriptionRepo(pool *pgxpool.Pool) *SubscriptionRepo {
 }
 
 func (r *SubscriptionRepo) Create(ctx context.Context, in domain.CreateInput) (domain.Subscription, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return domain.Subscription{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	s, err := insertSubscription(ctx, tx, in)
+	if err != nil {
+		return s, err
+	}
+	if err := recordEvent(ctx, tx, s.ID, domain.EventCreate, nil, &s); err != nil {
+		return s, err
+	}
+	return s, tx.Commit(ctx)
+}
+
+// insertSubscription добавляет подписку с начальной ценой и тегами.
+func insertSubscription(ctx context.Context, tx pgx.Tx, in domain.CreateInput) (domain.Subscription, error) {
 	var s domain.Subscription
 	var end any
 	if in.EndMonth != nil {
-		t, _ := time.Parse("2006-01", *in.EndMonth)
-		end = time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+		end, _ = time.Parse("2006-01-02", *in.EndMonth)
 	} else {
 		end = nil
 	}
-	startT, _ := time.Parse("2006-01", in.StartMonth)
-	start := time.Date(startT.Year(), startT.Month(), 1, 0, 0, 0, 0, time.UTC)
+	start, _ := time.Parse("2006-01-02", in.StartMonth)
+	var trialEnd any
+	if in.TrialEndMonth != nil {
+		trialEnd, _ = time.Parse("2006-01-02", *in.TrialEndMonth)
+	}
 
 	q := `
-INSERT INTO subscriptions (service_name, monthly_price, user_id, start_month, end_month)
-VALUES ($1, $2::numeric(12,2), $3, $4, $5)
-RETURNING id, service_name, monthly_price::text, user_id,
-         to_char(start_month, 'YYYY-MM') AS start_month,
-         CASE WHEN end_month IS NULL THEN NULL ELSE to_char(end_month, 'YYYY-MM') END AS end_month,
-         created_at, updated_at;
+INSERT INTO subscriptions (service_name, price, billing_period, billing_interval, currency, user_id, start_date, end_date, trial_end, notice_days, service_id)
+VALUES ($1, $2::numeric(12,2), $3, $4, $5, $6, $7, $8, $9, $10, $11)
+RETURNING ` + subscriptionColumns + `;
 `
-	err := r.pool.QueryRow(ctx, q, in.ServiceName, in.MonthlyPrice, in.UserID, start, end).Scan(
-		&s.ID, &s.ServiceName, &s.MonthlyPrice, &s.UserID, &s.StartMonth, &s.EndMonth, &s.CreatedAt, &s.UpdatedAt,
-	)
-	return s, err
+	err := scanSubscription(tx.QueryRow(ctx, q,
+		in.ServiceName, in.Price, in.BillingPeriod, in.BillingInterval, in.Currency, in.UserID, start, end, trialEnd, in.NoticeDays, in.ServiceID,
+	), &s)
+	if err != nil {
+		return s, err
+	}
+	month := time.Date(start.Year(), start.Month(), 1, 0, 0, 0, 0, time.UTC)
+	if _, err := insertPrice(ctx, tx, s.ID, month, in.Price); err != nil {
+		return s, err
+	}
+	// RETURNING выполняется до вставки тегов, поэтому они подставляются из входных данных.
+	if len(in.Tags) > 0 {
+		if err := replaceTags(ctx, tx, s.ID, in.Tags); err != nil {
+			return s, err
+		}
+		s.Tags = in.Tags
+	}
+	return s, nil
+}
+
+// replaceTags заменяет теги подписки.
+func replaceTags(ctx context.Context, tx pgx.Tx, id uuid.UUID, tags []string) error {
+	if _, err := tx.Exec(ctx, `DELETE FROM subscription_tags WHERE subscription_id = $1`, id); err != nil {
+		return err
+	}
+	if len(tags) == 0 {
+		return nil
+	}
+	_, err := tx.Exec(ctx, `
+INSERT INTO subscription_tags (subscription_id, tag)
+SELECT $1, unnest($2::text[]);
+`, id, tags)
+	return err
+}
+
+// insertPrice добавляет (или заменяет) цену, действующую с месяца month.
+func insertPrice(ctx context.Context, tx pgx.Tx, id uuid.UUID, month time.Time, price string) (domain.PriceChange, error) {
+	q := `
+INSERT INTO subscription_prices (subscription_id, effective_month, price)
+VALUES ($1, $2, $3::numeric(12,2))
+ON CONFLICT (subscription_id, effective_month) DO UPDATE SET price = EXCLUDED.price, created_at = now()
+RETURNING to_char(effective_month, 'YYYY-MM'), price::text, effective_month > date_trunc('month', current_date), created_at;
+`
+	var p domain.PriceChange
+	err := tx.QueryRow(ctx, q, id, month, price).Scan(&p.EffectiveMonth, &p.Price, &p.Scheduled, &p.CreatedAt)
+	return p, err
 }
 
 func (r *SubscriptionRepo) Get(ctx context.Context, id uuid.UUID) (domain.Subscription, error) {
 	q := `
-SELECT id, service_name, monthly_price::text, user_id,
-       to_char(start_month, 'YYYY-MM') AS start_month,
-       CASE WHEN end_month IS NULL THEN NULL ELSE to_char(end_month, 'YYYY-MM') END AS end_month,
-       created_at, updated_at
-FROM subscriptions WHERE id = $1;
+SELECT ` + subscriptionColumns + `
+FROM subscriptions WHERE id = $1 AND deleted_at IS NULL;
 `
 	var s domain.Subscription
-	err := r.pool.QueryRow(ctx, q, id).Scan(
-		&s.ID, &s.ServiceName, &s.MonthlyPrice, &s.UserID, &s.StartMonth, &s.EndMonth, &s.CreatedAt, &s.UpdatedAt,
-	)
+	err := scanSubscription(r.pool.QueryRow(ctx, q, id), &s)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return s, domain.ErrNotFound
+	}
 	return s, err
 }
 
-func (r *SubscriptionRepo) List(ctx context.Context, f domain.ListFilter) ([]domain.Subscription, error) {
+// listWhere строит условия фильтра списка; возвращает условия, аргументы и номер следующего
+// параметра. Значения фильтров передаются только параметрами.
+func listWhere(f domain.ListFilter) ([]string, []any, int) {
 	var args []any
-	var whr []string
+	whr := []string{"deleted_at IS NULL"}
 	idx := 1
-	if f.UserID != nil {
-		whr = append(whr, "user_id = $"+strconv.Itoa(idx))
-		args = append(args, *f.UserID)
+	arg := func(v any) string {
+		args = append(args, v)
 		idx++
+		return "$" + strconv.Itoa(idx-1)
+	}
+	if f.UserID != nil {
+		whr = append(whr, "user_id = "+arg(*f.UserID))
+	}
+	if len(f.UserIDs) > 0 {
+		whr = append(whr, "user_id = ANY("+arg(f.UserIDs)+"::uuid[])")
 	}
 	if f.ServiceName != nil && *f.ServiceName != "" {
-		whr = append(whr, "service_name ILIKE $"+strconv.Itoa(idx))
-		args = append(args, "%"+*f.ServiceName+"%")
-		idx++
+		if f.ServiceMatch == domain.ServiceMatchExact {
+			whr = append(whr, "normalize_service_name(service_name) = normalize_service_name("+arg(*f.ServiceName)+")")
+		} else {
+			whr = append(whr, "service_name ILIKE "+arg("%"+*f.ServiceName+"%"))
+		}
 	}
-	where := ""
-	if len(whr) > 0 {
-		where = "WHERE " + strings.Join(whr, " AND ")
+	if f.ServiceID != nil {
+		whr = append(whr, "service_id = "+arg(*f.ServiceID))
 	}
+	if len(f.Tags) > 0 {
+		p := arg(f.Tags)
+		if f.TagMatch == domain.TagMatchAll {
+			whr = append(whr, "(SELECT count(*) FROM subscription_tags t WHERE t.subscription_id = id AND t.tag = ANY("+p+"::text[])) = cardinality("+p+"::text[])")
+		} else {
+			whr = append(whr, "EXISTS (SELECT 1 FROM subscription_tags t WHERE t.subscription_id = id AND t.tag = ANY("+p+"::text[]))")
+		}
+	}
+	if f.MinPrice != nil {
+		whr = append(whr, "price_at(id, price, current_date) >= "+arg(*f.MinPrice)+"::numeric")
+	}
+	if f.MaxPrice != nil {
+		whr = append(whr, "price_at(id, price, current_date) <= "+arg(*f.MaxPrice)+"::numeric")
+	}
+	if f.ActiveAt != nil {
+		p := arg(*f.ActiveAt)
+		whr = append(whr, "start_date < "+p+"::date + interval '1 month' AND (end_date IS NULL OR end_date >= "+p+"::date)")
+	}
+	if len(f.Statuses) > 0 {
+		whr = append(whr, "("+statusSQL+") = ANY("+arg(f.Statuses)+"::text[])")
+	}
+	if f.StartFrom != nil {
+		whr = append(whr, "start_date >= "+arg(*f.StartFrom)+"::date")
+	}
+	if f.StartTo != nil {
+		whr = append(whr, "start_date <= "+arg(*f.StartTo)+"::date")
+	}
+	if f.CreatedAfter != nil {
+		whr = append(whr, "created_at > "+arg(*f.CreatedAfter))
+	}
+	return whr, args, idx
+}
+
+// listSortKeys — допустимые поля сортировки: выражение и тип для сравнения со значением курсора.
+// Бессрочные подписки при сортировке по end_month считаются заканчивающимися позже всех.
+var listSortKeys = map[string]struct{ expr, cast string }{
+	domain.SortCreatedAt:    {"created_at", "timestamptz"},
+	domain.SortUpdatedAt:    {"updated_at", "timestamptz"},
+	domain.SortStartMonth:   {"start_date", "date"},
+	domain.SortEndMonth:     {"COALESCE(end_date, 'infinity'::date)", "date"},
+	domain.SortServiceName:  {"service_name", "text"},
+	domain.SortPrice:        {"price_at(id, price, current_date)", "numeric"},
+	domain.SortMonthlyPrice: {"ROUND(monthly_equivalent(price_at(id, price, current_date), billing_period, billing_interval), 2)", "numeric"},
+}
+
+// listOrder возвращает выражение сортировки и направление; неизвестное поле заменяется created_at.
+func listOrder(f domain.ListFilter) (expr, cast, dir string) {
+	key, ok := listSortKeys[f.SortBy]
+	if !ok {
+		return "created_at", "timestamptz", "DESC"
+	}
+	if f.SortDesc {
+		return key.expr, key.cast, "DESC"
+	}
+	return key.expr, key.cast, "ASC"
+}
 
+// scanWithKey дописывает к колонкам подписки значение ключа сортировки.
+type scanWithKey struct {
+	rows pgx.Rows
+	key  *string
+}
+
+func (s scanWithKey) Scan(dest ...any) error {
+	return s.rows.Scan(append(dest, s.key)...)
+}
+
+// List возвращает страницу из f.Limit подписок в порядке f.Sort(): с позиции f.After, если
+// она задана, иначе со смещения f.Offset. Next указывает на последнюю подписку страницы,
+// если дальше есть ещё.
+func (r *SubscriptionRepo) List(ctx context.Context, f domain.ListFilter) (domain.SubscriptionPage, error) {
+	var page domain.SubscriptionPage
+	whr, args, idx := listWhere(f)
+	expr, cast, dir := listOrder(f)
+	if f.After != nil {
+		op := "<"
+		if dir == "ASC" {
+			op = ">"
+		}
+		whr = append(whr, "("+expr+", id) "+op+" ($"+strconv.Itoa(idx)+"::"+cast+", $"+strconv.Itoa(idx+1)+")")
+		args = append(args, f.After.Key, f.After.ID)
+		idx += 2
+	}
+	key := "(" + expr + ")::text"
+	if cast == "timestamptz" {
+		key = `to_char(` + expr + ` AT TIME ZONE 'UTC', 'YYYY-MM-DD"T"HH24:MI:SS.US"Z"')`
+	}
 	q := `
-SELECT id, service_name, monthly_price::text, user_id,
-       to_char(start_month, 'YYYY-MM') AS start_month,
-       CASE WHEN end_month IS NULL THEN NULL ELSE to_char(end_month, 'YYYY-MM') END AS end_month,
-       created_at, updated_at
+SELECT ` + subscriptionColumns + `, ` + key + `
 FROM subscriptions
-` + where + `
-ORDER BY created_at DESC
+WHERE ` + strings.Join(whr, " AND ") + `
+ORDER BY ` + expr + ` ` + dir + `, id ` + dir + `
 LIMIT $` + strconv.Itoa(idx) + ` OFFSET $` + strconv.Itoa(idx+1) + `;
 `
-	args = append(args, f.Limit, f.Offset)
+	args = append(args, f.Limit+1, f.Offset)
 
 	rows, err := r.pool.Query(ctx, q, args...)
 	if err != nil {
-		return nil, err
+		return page, err
 	}
 	defer rows.Close()
 
-	var out []domain.Subscription
+	var keys []string
 	for rows.Next() {
 		var s domain.Subscription
-		if err := rows.Scan(&s.ID, &s.ServiceName, &s.MonthlyPrice, &s.UserID, &s.StartMonth, &s.EndMonth, &s.CreatedAt, &s.UpdatedAt); err != nil {
-			return nil, err
+		var key string
+		if err := scanSubscription(scanWithKey{rows: rows, key: &key}, &s); err != nil {
+			return page, err
 		}
-		out = append(out, s)
+		page.Items = append(page.Items, s)
+		keys = append(keys, key)
 	}
-	return out, rows.Err()
+	if err := rows.Err(); err != nil {
+		return page, err
+	}
+	if len(page.Items) > f.Limit {
+		page.Items = page.Items[:f.Limit]
+		last := f.Limit - 1
+		page.Next = &domain.ListCursor{Sort: f.Sort(), Key: keys[last], ID: page.Items[last].ID}
+	}
+	return page, nil
+}
+
+// Count возвращает число подписок под фильтром списка (без limit, offset и курсора).
+func (r *SubscriptionRepo) Count(ctx context.Context, f domain.ListFilter) (int, error) {
+	whr, args, _ := listWhere(f)
+	var n int
+	err := r.pool.QueryRow(ctx, `SELECT count(*)::int FROM subscriptions WHERE `+strings.Join(whr, " AND "), args...).Scan(&n)
+	return n, err
+}
+
+// Stream передаёт в fn все подписки под фильтром в порядке f.Sort() без limit/offset, не собирая их в памяти.
+func (r *SubscriptionRepo) Stream(ctx context.Context, f domain.ListFilter, fn func(domain.Subscription) error) error {
+	whr, args, _ := listWhere(f)
+	expr, _, dir := listOrder(f)
+	q := `
+SELECT ` + subscriptionColumns + `
+FROM subscriptions
+WHERE ` + strings.Join(whr, " AND ") + `
+ORDER BY ` + expr + ` ` + dir + `, id ` + dir + `;
+`
+	rows, err := r.pool.Query(ctx, q, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var s domain.Subscription
+		if err := scanSubscription(rows, &s); err != nil {
+			return err
+		}
+		if err := fn(s); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
 }
 
 func (r *SubscriptionRepo) Update(ctx context.Context, id uuid.UUID, in domain.UpdateInput) (domain.Subscription, error) {
@@ -119,78 +372,1077 @@ func (r *SubscriptionRepo) Update(ctx context.Context, id uuid.UUID, in domain.U
 		args = append(args, *in.ServiceName)
 		i++
 	}
-	if in.MonthlyPrice != nil {
-		set = append(set, "monthly_price = $"+strconv.Itoa(i)+"::numeric(12,2)")
-		args = append(args, *in.MonthlyPrice)
+	if in.ServiceID != nil {
+		if *in.ServiceID == uuid.Nil {
+			set = append(set, "service_id = NULL")
+		} else {
+			set = append(set, "service_id = $"+strconv.Itoa(i))
+			args = append(args, *in.ServiceID)
+			i++
+		}
+	}
+	if in.BillingPeriod != nil {
+		set = append(set, "billing_period = $"+strconv.Itoa(i))
+		args = append(args, *in.BillingPeriod)
+		i++
+	}
+	if in.BillingInterval != nil {
+		set = append(set, "billing_interval = $"+strconv.Itoa(i))
+		args = append(args, *in.BillingInterval)
+		i++
+	}
+	if in.Currency != nil {
+		set = append(set, "currency = $"+strconv.Itoa(i))
+		args = append(args, *in.Currency)
 		i++
 	}
 	if in.StartMonth != nil {
-		smT, _ := time.Parse("2006-01", *in.StartMonth)
-		sm := time.Date(smT.Year(), smT.Month(), 1, 0, 0, 0, 0, time.UTC)
-		set = append(set, "start_month = $"+strconv.Itoa(i))
+		sm, _ := time.Parse("2006-01-02", *in.StartMonth)
+		set = append(set, "start_date = $"+strconv.Itoa(i))
 		args = append(args, sm)
 		i++
 	}
 	if in.EndMonth != nil {
 		if *in.EndMonth == "" {
-			set = append(set, "end_month = NULL")
+			set = append(set, "end_date = NULL")
 		} else {
-			emT, _ := time.Parse("2006-01", *in.EndMonth)
-			em := time.Date(emT.Year(), emT.Month(), 1, 0, 0, 0, 0, time.UTC)
-			set = append(set, "end_month = $"+strconv.Itoa(i))
+			em, _ := time.Parse("2006-01-02", *in.EndMonth)
+			set = append(set, "end_date = $"+strconv.Itoa(i))
 			args = append(args, em)
 			i++
 		}
 	}
-	set = append(set, "updated_at = now()")
+	if in.TrialEndMonth != nil {
+		if *in.TrialEndMonth == "" {
+			set = append(set, "trial_end = NULL")
+		} else {
+			te, _ := time.Parse("2006-01-02", *in.TrialEndMonth)
+			set = append(set, "trial_end = $"+strconv.Itoa(i))
+			args = append(args, te)
+			i++
+		}
+	}
+	if in.NoticeDays != nil {
+		set = append(set, "notice_days = $"+strconv.Itoa(i))
+		args = append(args, *in.NoticeDays)
+		i++
+	}
+	set = append(set, "updated_at = now()", "version = version + 1")
+	where := "id = $" + strconv.Itoa(i) + " AND deleted_at IS NULL"
 	args = append(args, id)
+	if in.IfVersion != nil {
+		i++
+		where += " AND version = $" + strconv.Itoa(i)
+		args = append(args, *in.IfVersion)
+	}
 
 	q := `
 UPDATE subscriptions
 SET ` + strings.Join(set, ", ") + `
-WHERE id = $` + strconv.Itoa(i) + `
-RETURNING id, service_name, monthly_price::text, user_id,
-          to_char(start_month, 'YYYY-MM') AS start_month,
-          CASE WHEN end_month IS NULL THEN NULL ELSE to_char(end_month, 'YYYY-MM') END AS end_month,
-          created_at, updated_at;
+WHERE ` + where + `
+RETURNING ` + subscriptionColumns + `;
 `
 	var s domain.Subscription
-	err := r.pool.QueryRow(ctx, q, args...).Scan(
-		&s.ID, &s.ServiceName, &s.MonthlyPrice, &s.UserID, &s.StartMonth, &s.EndMonth, &s.CreatedAt, &s.UpdatedAt,
-	)
-	return s, err
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return s, err
+	}
+	defer tx.Rollback(ctx)
+
+	before, err := lockSubscription(ctx, tx, id, false)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return s, domain.ErrNotFound
+	}
+	if err != nil {
+		return s, err
+	}
+	// Новая цена не перезаписывает прошлую, а добавляется в историю с месяца PriceEffectiveMonth.
+	if in.Price != nil {
+		month, _ := time.Parse("2006-01", *in.PriceEffectiveMonth)
+		if _, err := insertPrice(ctx, tx, id, month, *in.Price); err != nil {
+			return s, err
+		}
+	}
+	if in.Tags != nil {
+		if err := replaceTags(ctx, tx, id, *in.Tags); err != nil {
+			return s, err
+		}
+	}
+	// Строка уже заблокирована lockSubscription, поэтому отсутствие строки означает другую версию.
+	err = scanSubscription(tx.QueryRow(ctx, q, args...), &s)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return s, domain.ErrVersionMismatch
+	}
+	if err != nil {
+		return s, err
+	}
+	if err := recordEvent(ctx, tx, id, domain.EventUpdate, &before, &s); err != nil {
+		return s, err
+	}
+	return s, tx.Commit(ctx)
+}
+
+// AddPrice добавляет цену в историю; для будущего месяца это запланированное изменение.
+func (r *SubscriptionRepo) AddPrice(ctx context.Context, id uuid.UUID, month time.Time, price string) (domain.PriceChange, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return domain.PriceChange{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	before, err := lockSubscription(ctx, tx, id, false)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return domain.PriceChange{}, domain.ErrNotFound
+	}
+	if err != nil {
+		return domain.PriceChange{}, err
+	}
+	p, err := insertPrice(ctx, tx, id, month, price)
+	if err != nil {
+		return p, err
+	}
+	if err := touchSubscription(ctx, tx, &before, domain.EventPrice); err != nil {
+		return p, err
+	}
+	return p, tx.Commit(ctx)
+}
+
+func (r *SubscriptionRepo) Prices(ctx context.Context, id uuid.UUID) ([]domain.PriceChange, error) {
+	q := `
+SELECT to_char(effective_month, 'YYYY-MM'), price::text, effective_month > date_trunc('month', current_date), created_at
+FROM subscription_prices
+WHERE subscription_id = $1
+ORDER BY effective_month;
+`
+	rows, err := r.pool.Query(ctx, q, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []domain.PriceChange{}
+	for rows.Next() {
+		var p domain.PriceChange
+		if err := rows.Scan(&p.EffectiveMonth, &p.Price, &p.Scheduled, &p.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, p)
+	}
+	return out, rows.Err()
+}
+
+// Cancel устанавливает дату окончания по отмене (не позже текущей) и сохраняет, кто и почему
+// отменил. Под блокировкой строки проверяется, что отмена ещё не оформлена
+// (domain.ErrAlreadyCancelled) и подписка не закончилась до requestedAt (domain.ErrAlreadyEnded).
+func (r *SubscriptionRepo) Cancel(ctx context.Context, id uuid.UUID, requestedAt, end time.Time, by, reason string) (domain.Subscription, error) {
+	q := `
+UPDATE subscriptions
+SET end_date = LEAST(COALESCE(end_date, $2::date), $2::date), cancel_requested_at = $3, cancelled_at = now(),
+    cancelled_by = NULLIF($4, ''), cancel_reason = NULLIF($5, ''), updated_at = now(),
+    version = version + 1
+WHERE id = $1 AND cancelled_at IS NULL AND deleted_at IS NULL
+  AND (end_date IS NULL OR end_date >= $3::date)
+RETURNING ` + subscriptionColumns + `;
+`
+	var s domain.Subscription
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return s, err
+	}
+	defer tx.Rollback(ctx)
+
+	before, err := lockSubscription(ctx, tx, id, false)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return s, domain.ErrNotFound
+	}
+	if err != nil {
+		return s, err
+	}
+	if before.Cancellation != nil {
+		return s, domain.ErrAlreadyCancelled
+	}
+	// Строка заблокирована и не отменена, поэтому отсутствие строки означает, что подписка
+	// закончилась раньше запроса.
+	err = scanSubscription(tx.QueryRow(ctx, q, id, end, requestedAt, by, reason), &s)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return s, domain.ErrAlreadyEnded
+	}
+	if err != nil {
+		return s, err
+	}
+	if err := recordEvent(ctx, tx, id, domain.EventCancel, &before, &s); err != nil {
+		return s, err
+	}
+	return s, tx.Commit(ctx)
 }
 
-func (r *SubscriptionRepo) Delete(ctx context.Context, id uuid.UUID) (bool, error) {
-	cmd, err := r.pool.Exec(ctx, `DELETE FROM subscriptions WHERE id = $1`, id)
+// Pause добавляет паузу с from по resumeAt (nil — без даты возобновления).
+// Возвращает false, если период пересекается с уже существующей паузой.
+func (r *SubscriptionRepo) Pause(ctx context.Context, id uuid.UUID, from time.Time, resumeAt *time.Time) (bool, error) {
+	q := `
+INSERT INTO subscription_pauses (subscription_id, pause_from, resume_at)
+SELECT $1::uuid, $2::date, $3::date
+WHERE NOT EXISTS (
+  SELECT 1 FROM subscription_pauses p
+  WHERE p.subscription_id = $1
+    AND daterange(p.pause_from, p.resume_at) && daterange($2::date, $3::date)
+);
+`
+	tx, err := r.pool.Begin(ctx)
 	if err != nil {
 		return false, err
 	}
-	return cmd.RowsAffected() > 0, nil
+	defer tx.Rollback(ctx)
+
+	before, err := lockSubscription(ctx, tx, id, false)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, domain.ErrNotFound
+	}
+	if err != nil {
+		return false, err
+	}
+	cmd, err := tx.Exec(ctx, q, id, from, resumeAt)
+	if err != nil {
+		return false, err
+	}
+	if cmd.RowsAffected() == 0 {
+		return false, nil
+	}
+	if err := touchSubscription(ctx, tx, &before, domain.EventPause); err != nil {
+		return false, err
+	}
+	return true, tx.Commit(ctx)
 }
 
-func (r *SubscriptionRepo) Total(ctx context.Context, from, to time.Time, userID *uuid.UUID, service *string) (string, error) {
+// Resume завершает паузу, действующую на дату at; пауза, которая ещё не началась, отменяется.
+// Возвращает false, если паузы нет.
+func (r *SubscriptionRepo) Resume(ctx context.Context, id uuid.UUID, at time.Time) (bool, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	before, err := lockSubscription(ctx, tx, id, false)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, domain.ErrNotFound
+	}
+	if err != nil {
+		return false, err
+	}
+	upd, err := tx.Exec(ctx, `
+UPDATE subscription_pauses SET resume_at = $2
+WHERE subscription_id = $1 AND pause_from < $2 AND (resume_at IS NULL OR resume_at > $2)`, id, at)
+	if err != nil {
+		return false, err
+	}
+	del, err := tx.Exec(ctx, `
+DELETE FROM subscription_pauses
+WHERE subscription_id = $1 AND pause_from >= $2 AND resume_at IS NULL`, id, at)
+	if err != nil {
+		return false, err
+	}
+	if upd.RowsAffected()+del.RowsAffected() == 0 {
+		return false, nil
+	}
+	if err := touchSubscription(ctx, tx, &before, domain.EventResume); err != nil {
+		return false, err
+	}
+	return true, tx.Commit(ctx)
+}
+
+func (r *SubscriptionRepo) Pauses(ctx context.Context, id uuid.UUID) ([]domain.Pause, error) {
 	q := `
-WITH months AS (
-  SELECT generate_series($1::date, $2::date, interval '1 month')::date AS m
+SELECT id, to_char(pause_from, 'YYYY-MM-DD'), to_char(resume_at, 'YYYY-MM-DD'), created_at
+FROM subscription_pauses
+WHERE subscription_id = $1
+ORDER BY pause_from;
+`
+	rows, err := r.pool.Query(ctx, q, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []domain.Pause{}
+	for rows.Next() {
+		var p domain.Pause
+		if err := rows.Scan(&p.ID, &p.From, &p.ResumeAt, &p.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, p)
+	}
+	return out, rows.Err()
+}
+
+// UserPauses возвращает паузы неудалённых подписок пользователя по ID подписки.
+func (r *SubscriptionRepo) UserPauses(ctx context.Context, userID uuid.UUID) (map[uuid.UUID][]domain.Pause, error) {
+	q := `
+SELECT p.subscription_id, p.id, to_char(p.pause_from, 'YYYY-MM-DD'), to_char(p.resume_at, 'YYYY-MM-DD'), p.created_at
+FROM subscription_pauses p
+JOIN subscriptions s ON s.id = p.subscription_id
+WHERE s.user_id = $1 AND s.deleted_at IS NULL
+ORDER BY p.subscription_id, p.pause_from;
+`
+	rows, err := r.pool.Query(ctx, q, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := map[uuid.UUID][]domain.Pause{}
+	for rows.Next() {
+		var subID uuid.UUID
+		var p domain.Pause
+		if err := rows.Scan(&subID, &p.ID, &p.From, &p.ResumeAt, &p.CreatedAt); err != nil {
+			return nil, err
+		}
+		out[subID] = append(out[subID], p)
+	}
+	return out, rows.Err()
+}
+
+// TrialsEnding возвращает подписки, у которых пробный период заканчивается в ближайшие days дней.
+func (r *SubscriptionRepo) TrialsEnding(ctx context.Context, days int) ([]domain.Subscription, error) {
+	q := `
+SELECT ` + subscriptionColumns + `
+FROM subscriptions
+WHERE deleted_at IS NULL
+  AND trial_end >= current_date
+  AND trial_end <= current_date + $1::int
+  AND (end_date IS NULL OR end_date > trial_end)
+ORDER BY trial_end, created_at;
+`
+	rows, err := r.pool.Query(ctx, q, days)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []domain.Subscription{}
+	for rows.Next() {
+		var s domain.Subscription
+		if err := scanSubscription(rows, &s); err != nil {
+			return nil, err
+		}
+		out = append(out, s)
+	}
+	return out, rows.Err()
+}
+
+func (r *SubscriptionRepo) Overlapping(ctx context.Context, userID uuid.UUID, serviceName string, serviceID *uuid.UUID, start time.Time, end *time.Time, exclude *uuid.UUID) ([]uuid.UUID, error) {
+	q := `
+SELECT id
+FROM subscriptions
+WHERE user_id = $1
+  AND deleted_at IS NULL
+  AND (normalize_service_name(service_name) = normalize_service_name($2) OR service_id = $6)
+  AND daterange(start_date, end_date, '[]') && daterange($3::date, $4::date, '[]')
+  AND ($5::uuid IS NULL OR id <> $5)
+ORDER BY start_date, created_at;
+`
+	rows, err := r.pool.Query(ctx, q, userID, serviceName, start, end, exclude, serviceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		out = append(out, id)
+	}
+	return out, rows.Err()
+}
+
+// Duplicates возвращает подписки, пересекающиеся хотя бы с одной другой подпиской того же
+// пользователя на тот же сервис, сгруппированные по пользователю и сервису (название из каталога
+// или нормализованное название подписки).
+func (r *SubscriptionRepo) Duplicates(ctx context.Context, userID *uuid.UUID) ([]domain.DuplicateGroup, error) {
+	q := `
+SELECT COALESCE((SELECT c.name FROM services c WHERE c.id = service_id), normalize_service_name(service_name)),
+       ` + subscriptionColumns + `
+FROM subscriptions
+WHERE id IN (
+  SELECT a.id
+  FROM subscriptions a
+  JOIN subscriptions b
+    ON b.user_id = a.user_id AND b.id <> a.id
+   AND (normalize_service_name(b.service_name) = normalize_service_name(a.service_name) OR b.service_id = a.service_id)
+   AND b.deleted_at IS NULL
+   AND daterange(b.start_date, b.end_date, '[]') && daterange(a.start_date, a.end_date, '[]')
+  WHERE a.deleted_at IS NULL AND ($1::uuid IS NULL OR a.user_id = $1)
 )
-SELECT COALESCE(TO_CHAR(SUM(s.monthly_price)::numeric(12,2), 'FM9999999990D00'), '0.00')
-FROM months mo
-JOIN subscriptions s
-  ON s.start_month <= mo.m
- AND (s.end_month IS NULL OR s.end_month >= mo.m)
-WHERE ($3::uuid IS NULL OR s.user_id = $3)
-  AND ($4::text IS NULL OR s.service_name ILIKE '%'||$4||'%');
+ORDER BY user_id, 1, start_date, created_at;
+`
+	rows, err := r.pool.Query(ctx, q, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []domain.DuplicateGroup{}
+	for rows.Next() {
+		var (
+			name string
+			s    domain.Subscription
+		)
+		if err := scanSubscription(prefixedRow{rows, &name}, &s); err != nil {
+			return nil, err
+		}
+		if n := len(out); n == 0 || out[n-1].UserID != s.UserID || out[n-1].ServiceName != name {
+			out = append(out, domain.DuplicateGroup{UserID: s.UserID, ServiceName: name})
+		}
+		g := &out[len(out)-1]
+		g.Subscriptions = append(g.Subscriptions, s)
+	}
+	return out, rows.Err()
+}
+
+// prefixedRow сканирует первую колонку строки в prefix, остальные — в dest вызывающего.
+type prefixedRow struct {
+	row    pgx.Row
+	prefix any
+}
+
+func (p prefixedRow) Scan(dest ...any) error {
+	return p.row.Scan(append([]any{p.prefix}, dest...)...)
+}
+
+func (r *SubscriptionRepo) Members(ctx context.Context, id uuid.UUID) ([]domain.Member, error) {
+	q := `
+SELECT user_id, share_type, share_value::text, created_at
+FROM subscription_members
+WHERE subscription_id = $1
+ORDER BY created_at, user_id;
+`
+	rows, err := r.pool.Query(ctx, q, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []domain.Member{}
+	for rows.Next() {
+		var m domain.Member
+		if err := rows.Scan(&m.UserID, &m.Share, &m.Value, &m.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, m)
+	}
+	return out, rows.Err()
+}
+
+// SetMembers заменяет список участников подписки одной транзакцией.
+func (r *SubscriptionRepo) SetMembers(ctx context.Context, id uuid.UUID, members []domain.Member) ([]domain.Member, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	before, err := lockSubscription(ctx, tx, id, false)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, domain.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM subscription_members WHERE subscription_id = $1`, id); err != nil {
+		return nil, err
+	}
+	q := `
+INSERT INTO subscription_members (subscription_id, user_id, share_type, share_value)
+VALUES ($1, $2, $3, $4::numeric(12,2))
+RETURNING created_at;
+`
+	out := make([]domain.Member, 0, len(members))
+	for _, m := range members {
+		if err := tx.QueryRow(ctx, q, id, m.UserID, m.Share, m.Value).Scan(&m.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, m)
+	}
+	if err := touchSubscription(ctx, tx, &before, domain.EventMembers); err != nil {
+		return nil, err
+	}
+	return out, tx.Commit(ctx)
+}
+
+// Settlements считает взаимные долги участников совместных подписок за период: каждый участник
+// должен владельцу свою долю списаний; встречные долги взаимозачитываются.
+func (r *SubscriptionRepo) Settlements(ctx context.Context, from, to time.Time, userID *uuid.UUID) ([]domain.Settlement, error) {
+	f := domain.TotalFilter{Mode: domain.TotalModeCharges}
+	args := append(totalArgs(from, to, f), userID)
+	userArg := "$" + strconv.Itoa(len(args))
+
+	q := `
+WITH ` + chargesCTE(f.Mode) + `,
+owed AS (
+  SELECT user_id AS debtor, owner_id AS creditor, currency, SUM(amount) AS amount
+  FROM charges
+  WHERE user_id <> owner_id
+  GROUP BY 1, 2, 3
+),
+net AS (
+  SELECT LEAST(debtor, creditor) AS a, GREATEST(debtor, creditor) AS b, currency,
+         SUM(CASE WHEN debtor < creditor THEN amount ELSE -amount END) AS amount
+  FROM owed
+  GROUP BY 1, 2, 3
+)
+SELECT CASE WHEN amount > 0 THEN a ELSE b END,
+       CASE WHEN amount > 0 THEN b ELSE a END,
+       currency,
+       TO_CHAR(ABS(amount)::numeric(12,2), 'FM9999999990D00')
+FROM net
+WHERE ROUND(amount, 2) <> 0
+  AND (` + userArg + `::uuid IS NULL OR ` + userArg + ` IN (a, b))
+ORDER BY 1, 2, 3;
+`
+	rows, err := r.pool.Query(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []domain.Settlement{}
+	for rows.Next() {
+		var s domain.Settlement
+		if err := rows.Scan(&s.From, &s.To, &s.Currency, &s.Amount); err != nil {
+			return nil, err
+		}
+		out = append(out, s)
+	}
+	return out, rows.Err()
+}
+
+// Tags возвращает теги с числом подписок; userID ограничивает подсчёт подписками пользователя.
+func (r *SubscriptionRepo) Tags(ctx context.Context, userID *uuid.UUID) ([]domain.TagCount, error) {
+	q := `
+SELECT t.tag, count(*)::int
+FROM subscription_tags t
+JOIN subscriptions s ON s.id = t.subscription_id
+WHERE s.deleted_at IS NULL AND ($1::uuid IS NULL OR s.user_id = $1)
+GROUP BY t.tag
+ORDER BY count(*) DESC, t.tag;
+`
+	rows, err := r.pool.Query(ctx, q, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []domain.TagCount{}
+	for rows.Next() {
+		var t domain.TagCount
+		if err := rows.Scan(&t.Tag, &t.Subscriptions); err != nil {
+			return nil, err
+		}
+		out = append(out, t)
+	}
+	return out, rows.Err()
+}
+
+// Delete помечает подписку удалённой; она перестаёт учитываться, но её можно восстановить.
+// ifVersion != nil — удалить, только если версия совпадает (иначе domain.ErrVersionMismatch).
+func (r *SubscriptionRepo) Delete(ctx context.Context, id uuid.UUID, ifVersion *int) (bool, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	before, err := lockSubscription(ctx, tx, id, false)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	q := `
+UPDATE subscriptions SET deleted_at = now(), version = version + 1
+WHERE id = $1 AND ($2::int IS NULL OR version = $2)
+RETURNING ` + subscriptionColumns + `;
+`
+	var s domain.Subscription
+	err = scanSubscription(tx.QueryRow(ctx, q, id, ifVersion), &s)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, domain.ErrVersionMismatch
+	}
+	if err != nil {
+		return false, err
+	}
+	if err := recordEvent(ctx, tx, id, domain.EventDelete, &before, &s); err != nil {
+		return false, err
+	}
+	return true, tx.Commit(ctx)
+}
+
+// Trash возвращает удалённые подписки, последние удалённые первыми.
+func (r *SubscriptionRepo) Trash(ctx context.Context, f domain.ListFilter) ([]domain.Subscription, error) {
+	q := `
+SELECT ` + subscriptionColumns + `
+FROM subscriptions
+WHERE deleted_at IS NOT NULL AND ($1::uuid IS NULL OR user_id = $1)
+ORDER BY deleted_at DESC
+LIMIT $2 OFFSET $3;
+`
+	rows, err := r.pool.Query(ctx, q, f.UserID, f.Limit, f.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []domain.Subscription{}
+	for rows.Next() {
+		var s domain.Subscription
+		if err := scanSubscription(rows, &s); err != nil {
+			return nil, err
+		}
+		out = append(out, s)
+	}
+	return out, rows.Err()
+}
+
+func (r *SubscriptionRepo) GetDeleted(ctx context.Context, id uuid.UUID) (domain.Subscription, error) {
+	q := `
+SELECT ` + subscriptionColumns + `
+FROM subscriptions WHERE id = $1 AND deleted_at IS NOT NULL;
+`
+	var s domain.Subscription
+	err := scanSubscription(r.pool.QueryRow(ctx, q, id), &s)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return s, domain.ErrNotFound
+	}
+	return s, err
+}
+
+// Restore снимает пометку об удалении.
+func (r *SubscriptionRepo) Restore(ctx context.Context, id uuid.UUID) (domain.Subscription, error) {
+	q := `
+UPDATE subscriptions SET deleted_at = NULL, updated_at = now(), version = version + 1
+WHERE id = $1
+RETURNING ` + subscriptionColumns + `;
+`
+	var s domain.Subscription
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return s, err
+	}
+	defer tx.Rollback(ctx)
+
+	before, err := lockSubscription(ctx, tx, id, true)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return s, domain.ErrNotFound
+	}
+	if err != nil {
+		return s, err
+	}
+	if err := scanSubscription(tx.QueryRow(ctx, q, id), &s); err != nil {
+		return s, err
+	}
+	if err := recordEvent(ctx, tx, id, domain.EventRestore, &before, &s); err != nil {
+		return s, err
+	}
+	return s, tx.Commit(ctx)
+}
+
+// Purge окончательно удаляет подписки, удалённые раньше before; в журнал пишется событие purge
+// с последним состоянием каждой подписки.
+func (r *SubscriptionRepo) Purge(ctx context.Context, before time.Time) (int, error) {
+	q := `
+DELETE FROM subscriptions
+WHERE deleted_at < $1
+RETURNING ` + subscriptionColumns + `;
 `
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, q, before)
+	if err != nil {
+		return 0, err
+	}
+	var purged []domain.Subscription
+	for rows.Next() {
+		var s domain.Subscription
+		if err := scanSubscription(rows, &s); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		purged = append(purged, s)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	for i := range purged {
+		if err := recordEvent(ctx, tx, purged[i].ID, domain.EventPurge, &purged[i], nil); err != nil {
+			return 0, err
+		}
+	}
+	return len(purged), tx.Commit(ctx)
+}
+
+// totalArgs собирает параметры $1..$10 для chargesCTE и totalFilterSQL; дополнительные
+// параметры запросы добавляют после них.
+func totalArgs(from, to time.Time, f domain.TotalFilter) []any {
 	var userArg any = nil
-	if userID != nil {
-		userArg = *userID
+	if f.UserID != nil {
+		userArg = *f.UserID
 	}
 	var srvArg any = nil
-	if service != nil {
-		srvArg = *service
+	if f.ServiceName != nil {
+		srvArg = *f.ServiceName
+	}
+	var tagsArg any = nil
+	if len(f.Tags) > 0 {
+		tagsArg = f.Tags
 	}
-	var total string
-	err := r.pool.QueryRow(ctx, q, from, to, userArg, srvArg).Scan(&total)
-	return total, err
+	return []any{from, to, userArg, srvArg, f.Prorate, f.OpenEnded, f.ServiceID, tagsArg, f.TagMatch == domain.TagMatchAll, f.ServiceExact}
+}
+
+// totalFilterSQL — неудалённые подписки s с фильтрами $3 (user_id владельца или участника), $4 (service_name;
+// $10 — название целиком, иначе подстрока), $6 (бессрочные или нет),
+// $7 (service_id) и $8 (теги; $9 — нужны все, иначе любой) для агрегатов.
+const totalFilterSQL = `s.deleted_at IS NULL
+    AND ($3::uuid IS NULL OR s.user_id = $3
+         OR EXISTS (SELECT 1 FROM subscription_members sm WHERE sm.subscription_id = s.id AND sm.user_id = $3))
+    AND ($4::text IS NULL OR CASE WHEN $10::bool
+                                  THEN normalize_service_name(s.service_name) = normalize_service_name($4)
+                                  ELSE s.service_name ILIKE '%'||$4||'%' END)
+    AND ($6::bool IS NULL OR (s.end_date IS NULL) = $6)
+    AND ($7::uuid IS NULL OR s.service_id = $7)
+    AND ($8::text[] IS NULL OR (
+          SELECT CASE WHEN $9::bool THEN count(*) = cardinality($8::text[]) ELSE count(*) > 0 END
+            FROM subscription_tags t
+           WHERE t.subscription_id = s.id AND t.tag = ANY($8::text[])))`
+
+// chargesCTE строит CTE charges(m, currency, amount, subscription_id, user_id, service_name, owner_id)
+// для периода [$1, $2] с фильтрами totalFilterSQL; начисления разделены по участникам (sharesCTE).
+// Оплачиваемое время начинается с paid_from — первого дня после пробного периода (без него —
+// с даты начала). В режиме charges каждая строка — списание, попавшее в период (m — месяц
+// списания); первое списание — в paid_from, k-е — paid_from + k шагов, число, которого нет
+// в коротком месяце, заменяется его последним днём (generate_series только задаёт число шагов:
+// он прибавляет шаг к предыдущему значению, и дата после короткого месяца уплывала бы).
+// В режиме spread — месячный эквивалент стоимости для каждого активного месяца; месяцы, целиком
+// попавшие в пробный период, не учитываются. Списания (месяцы), дата которых приходится
+// на паузу, пропускаются.
+// При $5 = true первый и последний месяц уменьшаются пропорционально оплачиваемым дням;
+// сервис допускает это только в режиме spread (в charges множитель всегда 1). Цена берётся
+// из истории на месяц начисления.
+func chargesCTE(mode string) string {
+	if mode == domain.TotalModeSpread {
+		return `
+months AS (
+  SELECT generate_series($1::date, $2::date, interval '1 month')::date AS m
+),
+raw_charges AS (
+  SELECT mo.m, s.currency,
+         monthly_equivalent(price_at(s.id, s.price, mo.m), s.billing_period, s.billing_interval) *
+         CASE WHEN $5::bool
+              THEN (LEAST((mo.m + interval '1 month')::date, s.end_date + 1) - GREATEST(mo.m, pf.paid_from))::numeric
+                   / ((mo.m + interval '1 month')::date - mo.m)
+              ELSE 1 END AS amount,
+         monthly_equivalent(price_at(s.id, s.price, mo.m), s.billing_period, s.billing_interval) AS price,
+         s.id AS subscription_id, s.user_id, s.service_name
+  FROM subscriptions s
+  CROSS JOIN LATERAL (SELECT GREATEST(s.start_date, s.trial_end + 1) AS paid_from) pf
+  JOIN months mo
+    ON pf.paid_from < mo.m + interval '1 month'
+   AND (s.end_date IS NULL OR s.end_date >= mo.m)
+  WHERE (s.end_date IS NULL OR s.end_date >= pf.paid_from)
+    AND NOT is_paused(s.id, mo.m)
+    AND ` + totalFilterSQL + `
+),` + sharesCTE
+	}
+	return `
+raw_charges AS (
+  SELECT date_trunc('month', c.at)::date AS m, s.currency,
+         price_at(s.id, s.price, c.at::date) *
+         CASE WHEN $5::bool
+              THEN (LEAST(c.next::date, s.end_date + 1) - c.at::date)::numeric
+                   / (c.next::date - c.at::date)
+              ELSE 1 END AS amount,
+         price_at(s.id, s.price, c.at::date) AS price,
+         s.id AS subscription_id, s.user_id, s.service_name
+  FROM subscriptions s
+  CROSS JOIN LATERAL (
+    SELECT GREATEST(s.start_date, s.trial_end + 1) AS paid_from,
+           LEAST(COALESCE(s.end_date, $2::date + interval '1 month' - interval '1 day'),
+                 $2::date + interval '1 month' - interval '1 day') AS last_day
+  ) pf
+  CROSS JOIN LATERAL (
+    SELECT pf.paid_from + billing_step(s.billing_period, s.billing_interval) * (g.k - 1)::int AS at,
+           pf.paid_from + billing_step(s.billing_period, s.billing_interval) * g.k::int AS next
+    FROM generate_series(
+      pf.paid_from::timestamp,
+      pf.last_day,
+      billing_step(s.billing_period, s.billing_interval)
+    ) WITH ORDINALITY AS g(d, k)
+  ) AS c
+  WHERE c.at >= $1::date
+    AND c.at <= pf.last_day
+    AND (s.end_date IS NULL OR s.end_date >= pf.paid_from)
+    AND NOT is_paused(s.id, c.at::date)
+    AND ` + totalFilterSQL + `
+),` + sharesCTE
+}
+
+// sharesCTE делит каждое начисление raw_charges между участниками подписки: user_id в charges —
+// участник, amount — его доля, owner_id — владелец, который оплачивает списание. При фильтре
+// $3 остаются только доли этого пользователя.
+const sharesCTE = `
+charges AS (
+  SELECT rc.m, rc.currency, sh.share AS amount, rc.subscription_id, sh.member_id AS user_id,
+         rc.service_name, rc.user_id AS owner_id
+  FROM raw_charges rc
+  CROSS JOIN LATERAL member_shares(rc.subscription_id, rc.user_id, rc.amount, rc.price) sh
+  WHERE $3::uuid IS NULL OR sh.member_id = $3
+)`
+
+func (r *SubscriptionRepo) Total(ctx context.Context, from, to time.Time, f domain.TotalFilter) ([]domain.CurrencyTotal, error) {
+	q := `
+WITH ` + chargesCTE(f.Mode) + `
+SELECT currency, TO_CHAR(SUM(amount)::numeric(12,2), 'FM9999999990D00')
+FROM charges
+GROUP BY currency
+ORDER BY currency;
+`
+	rows, err := r.pool.Query(ctx, q, totalArgs(from, to, f)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []domain.CurrencyTotal{}
+	for rows.Next() {
+		var t domain.CurrencyTotal
+		if err := rows.Scan(&t.Currency, &t.Total); err != nil {
+			return nil, err
+		}
+		out = append(out, t)
+	}
+	return out, rows.Err()
+}
+
+// Breakdown возвращает начисления по месяцам периода (в том числе пустым) и число подписок,
+// действующих в месяце и не приостановленных на его первое число.
+func (r *SubscriptionRepo) Breakdown(ctx context.Context, from, to time.Time, f domain.TotalFilter) ([]domain.MonthBreakdown, error) {
+	q := `
+WITH ` + chargesCTE(f.Mode) + `,
+series AS (
+  SELECT generate_series($1::date, $2::date, interval '1 month')::date AS m
+),
+sums AS (
+  SELECT m, currency, SUM(amount) AS amount
+  FROM charges
+  GROUP BY m, currency
+),
+active AS (
+  SELECT se.m, COUNT(*) AS n
+  FROM series se
+  JOIN subscriptions s
+    ON s.start_date < se.m + interval '1 month'
+   AND (s.end_date IS NULL OR s.end_date >= se.m)
+  WHERE NOT is_paused(s.id, se.m)
+    AND ` + totalFilterSQL + `
+  GROUP BY se.m
+)
+SELECT to_char(se.m, 'YYYY-MM'), COALESCE(a.n, 0), su.currency,
+       TO_CHAR(su.amount::numeric(12,2), 'FM9999999990D00')
+FROM series se
+LEFT JOIN active a ON a.m = se.m
+LEFT JOIN sums su ON su.m = se.m
+ORDER BY se.m, su.currency;
+`
+	rows, err := r.pool.Query(ctx, q, totalArgs(from, to, f)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []domain.MonthBreakdown{}
+	for rows.Next() {
+		var (
+			month         string
+			active        int
+			currency, sum *string
+		)
+		if err := rows.Scan(&month, &active, &currency, &sum); err != nil {
+			return nil, err
+		}
+		if len(out) == 0 || out[len(out)-1].Month != month {
+			out = append(out, domain.MonthBreakdown{Month: month, ActiveSubscriptions: active, Totals: []domain.CurrencyTotal{}})
+		}
+		if currency != nil {
+			last := &out[len(out)-1]
+			last.Totals = append(last.Totals, domain.CurrencyTotal{Currency: *currency, Total: *sum})
+		}
+	}
+	return out, rows.Err()
+}
+
+// groupKeys — допустимые ключи группировки и их выражения над charges.
+var groupKeys = map[string]string{
+	domain.GroupByServiceName: "service_name",
+	domain.GroupByUserID:      "user_id::text",
+	domain.GroupByMonth:       "to_char(m, 'YYYY-MM')",
+}
+
+// GroupedTotal суммирует начисления по ключам groupBy отдельно в каждой валюте. Если top > 0,
+// в каждой валюте остаются top самых дорогих групп, остальные сворачиваются в группу other.
+func (r *SubscriptionRepo) GroupedTotal(ctx context.Context, from, to time.Time, f domain.TotalFilter, groupBy []string, top int) (domain.GroupedTotals, error) {
+	exprs := make([]string, 0, len(groupBy))
+	names := make([]string, 0, len(groupBy))
+	outer := make([]string, 0, len(groupBy))
+	for i, g := range groupBy {
+		k := "k" + strconv.Itoa(i)
+		exprs = append(exprs, groupKeys[g]+" AS "+k)
+		names = append(names, k)
+		outer = append(outer, "CASE WHEN other THEN NULL ELSE "+k+" END")
+	}
+	nameList := strings.Join(names, ", ")
+	args := totalArgs(from, to, f)
+	args = append(args, top)
+	topArg := "$" + strconv.Itoa(len(args)) + "::int"
+	outerList := strings.Join(outer, ", ")
+
+	q := `
+WITH ` + chargesCTE(f.Mode) + `,
+grouped AS (
+  SELECT ` + strings.Join(exprs, ", ") + `, currency, SUM(amount) AS amount
+  FROM charges
+  GROUP BY ` + nameList + `, currency
+),
+ranked AS (
+  SELECT g.*, (` + topArg + ` > 0 AND ROW_NUMBER() OVER (PARTITION BY currency ORDER BY amount DESC, ` + nameList + `) > ` + topArg + `) AS other
+  FROM grouped g
+)
+SELECT other, ` + outerList + `, currency,
+       TO_CHAR(SUM(amount)::numeric(12,2), 'FM9999999990D00'),
+       COUNT(*),
+       TO_CHAR(SUM(SUM(amount)) OVER (PARTITION BY currency)::numeric(12,2), 'FM9999999990D00')
+FROM ranked
+GROUP BY other, ` + outerList + `, currency
+ORDER BY currency, other, SUM(amount) DESC, ` + outerList + `;
+`
+	rows, err := r.pool.Query(ctx, q, args...)
+	if err != nil {
+		return domain.GroupedTotals{}, err
+	}
+	defer rows.Close()
+
+	out := domain.GroupedTotals{Groups: []domain.GroupTotal{}, GrandTotals: []domain.CurrencyTotal{}}
+	for rows.Next() {
+		var (
+			g     domain.GroupTotal
+			vals  = make([]*string, len(groupBy))
+			grand string
+		)
+		dest := []any{&g.Other}
+		for i := range vals {
+			dest = append(dest, &vals[i])
+		}
+		dest = append(dest, &g.Currency, &g.Total, &g.Groups, &grand)
+		if err := rows.Scan(dest...); err != nil {
+			return domain.GroupedTotals{}, err
+		}
+		if !g.Other {
+			g.Groups = 0
+			g.Keys = make(map[string]string, len(groupBy))
+			for i, k := range groupBy {
+				if vals[i] != nil {
+					g.Keys[k] = *vals[i]
+				}
+			}
+		}
+		out.Groups = append(out.Groups, g)
+		if n := len(out.GrandTotals); n == 0 || out.GrandTotals[n-1].Currency != g.Currency {
+			out.GrandTotals = append(out.GrandTotals, domain.CurrencyTotal{Currency: g.Currency, Total: grand})
+		}
+	}
+	return out, rows.Err()
+}
+
+// ConvertedTotal пересчитывает начисления каждого месяца в валюту currency.
+// Для месяца берётся последний курс, опубликованный в этом месяце: прямой, обратный
+// или кросс-курс через общую базовую валюту (например, EUR у ЕЦБ).
+func (r *SubscriptionRepo) ConvertedTotal(ctx context.Context, from, to time.Time, f domain.TotalFilter, currency string) (domain.ConvertedTotal, error) {
+	args := totalArgs(from, to, f)
+	args = append(args, currency)
+	curArg := "$" + strconv.Itoa(len(args))
+
+	q := `
+WITH ` + chargesCTE(f.Mode) + `,
+monthly AS (
+  SELECT m, currency, SUM(amount) AS amount
+  FROM charges
+  GROUP BY m, currency
+)
+SELECT to_char(c.m, 'YYYY-MM'), c.currency, c.amount::numeric(12,2)::text,
+       trim_scale(ROUND(r.factor, 8))::text,
+       to_char(r.rate_date, 'YYYY-MM-DD'),
+       COALESCE(TO_CHAR(SUM(ROUND(c.amount * r.factor, 2)) OVER (), 'FM9999999990D00'), '0.00')
+FROM monthly c
+LEFT JOIN LATERAL (
+  SELECT x.rate_date, x.factor
+  FROM (
+    SELECT 0 AS prio, NULL::date AS rate_date, 1::numeric AS factor
+     WHERE c.currency = ` + curArg + `
+    UNION ALL
+    SELECT 1, e.rate_date, e.rate
+      FROM exchange_rates e
+     WHERE e.base = c.currency AND e.quote = ` + curArg + `
+       AND e.rate_date >= c.m AND e.rate_date < c.m + interval '1 month'
+    UNION ALL
+    SELECT 1, e.rate_date, 1 / e.rate
+      FROM exchange_rates e
+     WHERE e.base = ` + curArg + ` AND e.quote = c.currency
+       AND e.rate_date >= c.m AND e.rate_date < c.m + interval '1 month'
+    UNION ALL
+    SELECT 2, a.rate_date, b.rate / a.rate
+      FROM exchange_rates a
+      JOIN exchange_rates b ON b.rate_date = a.rate_date AND b.base = a.base
+     WHERE a.quote = c.currency AND b.quote = ` + curArg + `
+       AND a.rate_date >= c.m AND a.rate_date < c.m + interval '1 month'
+  ) x
+  ORDER BY x.prio, x.rate_date DESC
+  LIMIT 1
+) r ON true
+ORDER BY c.m, c.currency;
+`
+	rows, err := r.pool.Query(ctx, q, args...)
+	if err != nil {
+		return domain.ConvertedTotal{}, err
+	}
+	defer rows.Close()
+
+	out := domain.ConvertedTotal{
+		Currency: currency,
+		Total:    "0.00",
+		Rates:    []domain.AppliedRate{},
+		Missing:  []domain.MissingRate{},
+	}
+	for rows.Next() {
+		var (
+			month, cur, amount string
+			rate, rateDate     *string
+		)
+		if err := rows.Scan(&month, &cur, &amount, &rate, &rateDate, &out.Total); err != nil {
+			return domain.ConvertedTotal{}, err
+		}
+		switch {
+		case rate == nil:
+			out.Missing = append(out.Missing, domain.MissingRate{Month: month, Currency: cur, Amount: amount})
+		case cur != currency:
+			out.Rates = append(out.Rates, domain.AppliedRate{Month: month, Currency: cur, Rate: *rate, RateDate: rateDate})
+		}
+	}
+	return out, rows.Err()
 }
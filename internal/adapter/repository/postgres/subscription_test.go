@@ -0,0 +1,133 @@
+package postgres_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"crud_ef/internal/adapter/repository/postgres"
+	"crud_ef/internal/domain"
+	"crud_ef/internal/usecase/subscription"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// testPool подключается к TEST_DATABASE_URL, создаёт отдельную схему и применяет к ней
+// миграции из db/migrations; схема удаляется после теста. Без переменной тест пропускается.
+func testPool(t *testing.T) *pgxpool.Pool {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	ctx := context.Background()
+	schema := "test_" + strings.ReplaceAll(uuid.NewString(), "-", "")
+
+	admin, err := pgxpool.New(ctx, dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(admin.Close)
+	if _, err := admin.Exec(ctx, "CREATE SCHEMA "+schema); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _, _ = admin.Exec(context.Background(), "DROP SCHEMA "+schema+" CASCADE") })
+
+	cfg, err := pgxpool.ParseConfig(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.ConnConfig.RuntimeParams["search_path"] = schema + ",public"
+	pool, err := pgxpool.NewWithConfig(ctx, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(pool.Close)
+
+	files, err := filepath.Glob("../../../../db/migrations/*.up.sql")
+	if err != nil || len(files) == 0 {
+		t.Fatalf("migrations not found: %v", err)
+	}
+	sort.Strings(files)
+	for _, f := range files {
+		sql, err := os.ReadFile(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := pool.Exec(ctx, string(sql)); err != nil {
+			t.Fatalf("%s: %v", filepath.Base(f), err)
+		}
+	}
+	return pool
+}
+
+func TestTotalMidMonthStart(t *testing.T) {
+	ctx := context.Background()
+	svc := subscription.NewService(postgres.NewSubscriptionRepo(testPool(t)))
+	user := uuid.New()
+	end := "2025-03-10"
+	if _, err := svc.Create(ctx, domain.CreateInput{
+		ServiceName: "Netflix", Price: "31", Currency: "RUB", UserID: user,
+		StartMonth: "2025-01-20", EndMonth: &end,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		from, to string
+		f        domain.TotalFilter
+		want     string
+	}{
+		// Списания 20.01 и 20.02 — полные периоды; 20.03 уже после окончания.
+		{"charges", "2025-01", "2025-03", domain.TotalFilter{}, "62.00"},
+		{"charges in start month", "2025-01", "2025-01", domain.TotalFilter{}, "31.00"},
+		{"spread", "2025-01", "2025-03", domain.TotalFilter{Mode: domain.TotalModeSpread}, "93.00"},
+		// 12 дней из 31 в январе.
+		{"spread prorated start month", "2025-01", "2025-01", domain.TotalFilter{Mode: domain.TotalModeSpread, Prorate: true}, "12.00"},
+		// 10 дней из 31 в марте.
+		{"spread prorated end month", "2025-03", "2025-03", domain.TotalFilter{Mode: domain.TotalModeSpread, Prorate: true}, "10.00"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.f.UserID = &user
+			got, err := svc.Total(ctx, tt.from, tt.to, tt.f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != 1 || got[0].Currency != "RUB" || got[0].Total != tt.want {
+				t.Errorf("Total = %+v, want RUB %s", got, tt.want)
+			}
+		})
+	}
+
+	_, err := svc.Total(ctx, "2025-01", "2025-01", domain.TotalFilter{UserID: &user, Prorate: true})
+	if err == nil || !strings.HasPrefix(err.Error(), "invalid prorate") {
+		t.Errorf("prorate in charges mode: error = %v, want invalid prorate", err)
+	}
+}
+
+func TestTotalAfterTrial(t *testing.T) {
+	ctx := context.Background()
+	svc := subscription.NewService(postgres.NewSubscriptionRepo(testPool(t)))
+	user := uuid.New()
+	trialEnd, end := "2025-01-24", "2025-02-20"
+	if _, err := svc.Create(ctx, domain.CreateInput{
+		ServiceName: "Netflix", Price: "31", Currency: "RUB", UserID: user,
+		StartMonth: "2025-01-10", TrialEndMonth: &trialEnd, EndMonth: &end,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	// Первое списание — 25.01, после пробного периода; следующее, 25.02, уже после окончания.
+	got, err := svc.Total(ctx, "2025-01", "2025-02", domain.TotalFilter{UserID: &user})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].Total != "31.00" {
+		t.Errorf("Total = %+v, want RUB 31.00", got)
+	}
+}
@@ -20,6 +20,15 @@ type Config struct {
 	DBPassword string `mapstructure:"DB_PASSWORD"`
 	DBName     string `mapstructure:"DB_NAME"`
 	DBSSLMode  string `mapstructure:"DB_SSLMODE"`
+
+	// TrashRetentionDays — сколько дней удалённые подписки хранятся в корзине; 0 — не очищать.
+	TrashRetentionDays int           `mapstructure:"TRASH_RETENTION_DAYS"`
+	TrashPurgeInterval time.Duration `mapstructure:"TRASH_PURGE_INTERVAL"`
+
+	// RequireIfMatch — PUT и DELETE подписки только с заголовком If-Match.
+	RequireIfMatch bool `mapstructure:"REQUIRE_IF_MATCH"`
+	// LegacyPut — PUT /subscriptions/{id} как прежнее частичное обновление (переходный период).
+	LegacyPut bool `mapstructure:"LEGACY_PUT"`
 }
 
 func Load() (Config, error) {
@@ -43,6 +52,10 @@ func Load() (Config, error) {
 	v.SetDefault("DB_PASSWORD", "postgres")
 	v.SetDefault("DB_NAME", "subscriptions")
 	v.SetDefault("DB_SSLMODE", "disable")
+	v.SetDefault("TRASH_RETENTION_DAYS", 30)
+	v.SetDefault("TRASH_PURGE_INTERVAL", "1h")
+	v.SetDefault("REQUIRE_IF_MATCH", false)
+	v.SetDefault("LEGACY_PUT", false)
 
 	var cfg Config
 	if err := v.Unmarshal(&cfg); err != nil {
@@ -55,6 +68,11 @@ func Load() (Config, error) {
 	return cfg, nil
 }
 
+// TrashRetention — срок хранения удалённых подписок.
+func (c Config) TrashRetention() time.Duration {
+	return time.Duration(c.TrashRetentionDays) * 24 * time.Hour
+}
+
 func (c Config) Addr() string {
 	return fmt.Sprintf(":%s", c.HTTPPort)
 }
@@ -0,0 +1,64 @@
+package domain
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Действия, записываемые в журнал изменений подписки.
+const (
+	EventCreate  = "create"
+	EventUpdate  = "update"
+	EventCancel  = "cancel"
+	EventDelete  = "delete"
+	EventRestore = "restore"
+	EventPrice   = "price"
+	EventPause   = "pause"
+	EventResume  = "resume"
+	EventMembers = "members"
+	EventPurge   = "purge"
+)
+
+// Event — запись журнала: состояние подписки до (Before) и после (After) изменения.
+// Before пуст у create, After — у окончательного удаления (purge).
+type Event struct {
+	ID             int64
+	SubscriptionID uuid.UUID
+	Action         string
+	Actor          string
+	RequestID      string
+	Before         *Subscription
+	After          *Subscription
+	CreatedAt      time.Time
+}
+
+// EventFilter — фильтры журнала; From включительно, To не включительно.
+type EventFilter struct {
+	SubscriptionID *uuid.UUID
+	Actor          *string
+	From           *time.Time
+	To             *time.Time
+	Limit          int
+	Offset         int
+}
+
+// Audit — кто и в рамках какого запроса вносит изменения.
+type Audit struct {
+	Actor     string
+	RequestID string
+}
+
+type auditKey struct{}
+
+// WithAudit сохраняет в контексте автора изменений для журнала.
+func WithAudit(ctx context.Context, a Audit) context.Context {
+	return context.WithValue(ctx, auditKey{}, a)
+}
+
+// AuditFrom возвращает автора изменений из контекста (пустой, если не задан).
+func AuditFrom(ctx context.Context) Audit {
+	a, _ := ctx.Value(auditKey{}).(Audit)
+	return a
+}
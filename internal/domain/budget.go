@@ -0,0 +1,82 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Области действия бюджета.
+const (
+	BudgetScopeUser    = "user"
+	BudgetScopeService = "service"
+	BudgetScopeGlobal  = "global"
+)
+
+// DefaultThresholds — пороги оповещений (% от лимита), если они не заданы.
+var DefaultThresholds = []int{80, 100}
+
+// Budget — месячный лимит расходов на пользователя (UserID, включая доли в совместных подписках),
+// сервис (ServiceName, название целиком без учёта регистра) или на все подписки. Расходы считаются в режиме Mode и пересчитываются
+// в Currency.
+type Budget struct {
+	ID           uuid.UUID
+	Scope        string
+	UserID       *uuid.UUID
+	ServiceName  *string
+	MonthlyLimit string
+	Currency     string
+	Mode         string
+	Thresholds   []int
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+type BudgetInput struct {
+	Scope        string
+	UserID       *uuid.UUID
+	ServiceName  *string
+	MonthlyLimit string
+	Currency     string
+	Mode         string
+	Thresholds   []int
+}
+
+// Filter возвращает фильтр Total, соответствующий области бюджета.
+func (b Budget) Filter() TotalFilter {
+	f := TotalFilter{Mode: b.Mode}
+	switch b.Scope {
+	case BudgetScopeUser:
+		f.UserID = b.UserID
+	case BudgetScopeService:
+		f.ServiceName = b.ServiceName
+		f.ServiceExact = true
+	}
+	return f
+}
+
+// BudgetStatus — расходы за месяц (YYYY-MM) относительно лимита. Reached — достигнутые пороги,
+// Missing — начисления без курса, не вошедшие в Spent.
+type BudgetStatus struct {
+	Budget    Budget
+	Month     string
+	Spent     string
+	Remaining string
+	Percent   float64
+	Reached   []int
+	Over      bool
+	Missing   []MissingRate
+}
+
+// BudgetAlert — оповещение о достижении порога Threshold в месяце Month. SubscriptionID —
+// подписка, изменение которой привело к превышению.
+type BudgetAlert struct {
+	BudgetID       uuid.UUID
+	Month          string
+	Threshold      int
+	Spent          string
+	MonthlyLimit   string
+	Currency       string
+	SubscriptionID *uuid.UUID
+	CreatedAt      time.Time
+}
@@ -0,0 +1,30 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// CalendarEvent — повторяющееся списание по подписке для календарной ленты: первое списание
+// First (после пробного периода), далее раз в BillingInterval периодов BillingPeriod до Until
+// включительно (nil — бессрочно).
+type CalendarEvent struct {
+	SubscriptionID  uuid.UUID
+	ServiceName     string
+	Price           string
+	Currency        string
+	BillingPeriod   string
+	BillingInterval int
+	First           time.Time
+	// Day — число месяца, от которого считаются списания (день First); в месяцах
+	// короче списание приходится на последний день.
+	Day   int
+	Until *time.Time
+	// Skipped — даты списаний внутри пауз: они исключаются из повторений (EXDATE). Бессрочная
+	// пауза вместо этого ограничивает Until.
+	Skipped    []time.Time
+	NoticeDays int
+	Version    int
+	UpdatedAt  time.Time
+}
@@ -0,0 +1,40 @@
+package domain
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// CatalogService — запись каталога сервисов. Название подписки сопоставляется с Name и Aliases
+// без учёта регистра и лишних пробелов (NormalizeServiceName).
+type CatalogService struct {
+	ID            uuid.UUID
+	Name          string
+	Aliases       []string
+	Category      *string
+	VendorURL     *string
+	Subscriptions int
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+type CatalogServiceInput struct {
+	Name      string
+	Aliases   []string
+	Category  *string
+	VendorURL *string
+}
+
+type CatalogFilter struct {
+	// Query ищет по названию и псевдонимам.
+	Query    *string
+	Category *string
+}
+
+// NormalizeServiceName приводит название к нижнему регистру и схлопывает пробелы,
+// как SQL-функция normalize_service_name.
+func NormalizeServiceName(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(name), " "))
+}
@@ -0,0 +1,16 @@
+package domain
+
+import "errors"
+
+var (
+	// ErrNotFound возвращают репозитории, если запись не найдена.
+	ErrNotFound = errors.New("not found")
+	// ErrAlreadyExists возвращают репозитории при нарушении уникальности.
+	ErrAlreadyExists = errors.New("already exists")
+	// ErrVersionMismatch — запись изменена с момента чтения (версия не совпадает).
+	ErrVersionMismatch = errors.New("version mismatch")
+	// ErrAlreadyEnded — подписка закончилась раньше даты запроса на отмену.
+	ErrAlreadyEnded = errors.New("subscription has already ended")
+	// ErrAlreadyCancelled — отмена уже оформлена.
+	ErrAlreadyCancelled = errors.New("subscription is already cancelled")
+)
@@ -0,0 +1,33 @@
+package domain
+
+import "github.com/google/uuid"
+
+// Результаты строк импорта.
+const (
+	// ImportCreated — подписка создана.
+	ImportCreated = "created"
+	// ImportValid — строка прошла проверку в режиме dry run.
+	ImportValid = "valid"
+	// ImportDuplicate — пропущена: пересекается с существующей подпиской или строкой выше.
+	ImportDuplicate = "duplicate"
+	// ImportInvalid — строка не прошла проверку.
+	ImportInvalid = "invalid"
+)
+
+// ImportRow — строка файла импорта. Error — ошибка разбора строки; Input тогда не заполнен.
+type ImportRow struct {
+	Line  int
+	Input CreateInput
+	Error string
+}
+
+// ImportResult — итог по строке. Conflicts — пересекающиеся подписки для ImportDuplicate
+// (пусто, если дубль — строка того же файла; её номер в DuplicateOf).
+type ImportResult struct {
+	Line           int
+	Status         string
+	SubscriptionID *uuid.UUID
+	Error          string
+	Conflicts      []uuid.UUID
+	DuplicateOf    int
+}
@@ -0,0 +1,35 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Типы долей участника совместной подписки.
+const (
+	// ShareEqual — поровну с другими участниками equal из остатка после остальных долей.
+	ShareEqual = "equal"
+	// SharePercent — процент от каждого начисления.
+	SharePercent = "percent"
+	// ShareFixed — фиксированная сумма с каждого списания в валюте подписки.
+	ShareFixed = "fixed"
+)
+
+// Member — участник, с которым владелец подписки делит её стоимость. Value пуст для ShareEqual.
+// Владелец, не указанный среди участников, платит как участник equal.
+type Member struct {
+	UserID    uuid.UUID
+	Share     string
+	Value     *string
+	CreatedAt time.Time
+}
+
+// Settlement — сколько From должен To за период: доли From в подписках, которые оплачивает To,
+// за вычетом встречного долга.
+type Settlement struct {
+	From     uuid.UUID
+	To       uuid.UUID
+	Currency string
+	Amount   string
+}
@@ -0,0 +1,69 @@
+package domain
+
+import "strings"
+
+// ExchangeRate — курс на дату: 1 Base = Rate Quote.
+type ExchangeRate struct {
+	Date  string
+	Base  string
+	Quote string
+	Rate  string
+}
+
+type RateFilter struct {
+	Base  *string
+	Quote *string
+	From  *string
+	To    *string
+}
+
+// AppliedRate — курс, по которому пересчитаны начисления месяца.
+type AppliedRate struct {
+	Month    string
+	Currency string
+	Rate     string
+	RateDate *string
+}
+
+// MissingRate — начисления месяца, для которых не нашлось курса; в сумму не входят.
+type MissingRate struct {
+	Month    string
+	Currency string
+	Amount   string
+}
+
+type ConvertedTotal struct {
+	Currency string
+	Total    string
+	Rates    []AppliedRate
+	Missing  []MissingRate
+}
+
+// currencies — действующие коды валют ISO 4217 (без фондов, драгметаллов и тестовых кодов).
+var currencies = map[string]struct{}{
+	"AED": {}, "AFN": {}, "ALL": {}, "AMD": {}, "AOA": {}, "ARS": {}, "AUD": {}, "AWG": {}, "AZN": {}, "BAM": {},
+	"BBD": {}, "BDT": {}, "BHD": {}, "BIF": {}, "BMD": {}, "BND": {}, "BOB": {}, "BRL": {}, "BSD": {}, "BTN": {},
+	"BWP": {}, "BYN": {}, "BZD": {}, "CAD": {}, "CDF": {}, "CHF": {}, "CLP": {}, "CNY": {}, "COP": {}, "CRC": {},
+	"CUP": {}, "CVE": {}, "CZK": {}, "DJF": {}, "DKK": {}, "DOP": {}, "DZD": {}, "EGP": {}, "ERN": {}, "ETB": {},
+	"EUR": {}, "FJD": {}, "FKP": {}, "GBP": {}, "GEL": {}, "GHS": {}, "GIP": {}, "GMD": {}, "GNF": {}, "GTQ": {},
+	"GYD": {}, "HKD": {}, "HNL": {}, "HTG": {}, "HUF": {}, "IDR": {}, "ILS": {}, "INR": {}, "IQD": {}, "IRR": {},
+	"ISK": {}, "JMD": {}, "JOD": {}, "JPY": {}, "KES": {}, "KGS": {}, "KHR": {}, "KMF": {}, "KPW": {}, "KRW": {},
+	"KWD": {}, "KYD": {}, "KZT": {}, "LAK": {}, "LBP": {}, "LKR": {}, "LRD": {}, "LSL": {}, "LYD": {}, "MAD": {},
+	"MDL": {}, "MGA": {}, "MKD": {}, "MMK": {}, "MNT": {}, "MOP": {}, "MRU": {}, "MUR": {}, "MVR": {}, "MWK": {},
+	"MXN": {}, "MYR": {}, "MZN": {}, "NAD": {}, "NGN": {}, "NIO": {}, "NOK": {}, "NPR": {}, "NZD": {}, "OMR": {},
+	"PAB": {}, "PEN": {}, "PGK": {}, "PHP": {}, "PKR": {}, "PLN": {}, "PYG": {}, "QAR": {}, "RON": {}, "RSD": {},
+	"RUB": {}, "RWF": {}, "SAR": {}, "SBD": {}, "SCR": {}, "SDG": {}, "SEK": {}, "SGD": {}, "SHP": {}, "SLE": {},
+	"SOS": {}, "SRD": {}, "SSP": {}, "STN": {}, "SVC": {}, "SYP": {}, "SZL": {}, "THB": {}, "TJS": {}, "TMT": {},
+	"TND": {}, "TOP": {}, "TRY": {}, "TTD": {}, "TWD": {}, "TZS": {}, "UAH": {}, "UGX": {}, "USD": {}, "UYU": {},
+	"UZS": {}, "VED": {}, "VES": {}, "VND": {}, "VUV": {}, "WST": {}, "XAF": {}, "XCD": {}, "XCG": {}, "XOF": {},
+	"XPF": {}, "YER": {}, "ZAR": {}, "ZMW": {}, "ZWG": {},
+}
+
+// NormalizeCurrency приводит код валюты к верхнему регистру и проверяет, что это действующий код ISO 4217.
+func NormalizeCurrency(c string) (string, bool) {
+	c = strings.ToUpper(strings.TrimSpace(c))
+	if _, ok := currencies[c]; !ok {
+		return "", false
+	}
+	return c, true
+}
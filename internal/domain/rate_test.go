@@ -0,0 +1,16 @@
+package domain
+
+import "testing"
+
+func TestNormalizeCurrency(t *testing.T) {
+	for in, want := range map[string]string{"usd": "USD", " Rub ": "RUB", "EUR": "EUR", "xof": "XOF"} {
+		if got, ok := NormalizeCurrency(in); !ok || got != want {
+			t.Errorf("NormalizeCurrency(%q) = %q, %v; want %q", in, got, ok, want)
+		}
+	}
+	for _, in := range []string{"", "US", "USDT", "ABC", "XXX", "XAU", "HRK", "U1D"} {
+		if got, ok := NormalizeCurrency(in); ok {
+			t.Errorf("NormalizeCurrency(%q) = %q, want rejection", in, got)
+		}
+	}
+}
@@ -6,35 +6,322 @@ import (
 	"github.com/google/uuid"
 )
 
+// DefaultCurrency используется, если валюта не передана при создании.
+const DefaultCurrency = "RUB"
+
+// Периоды списания.
+const (
+	PeriodWeek    = "week"
+	PeriodMonth   = "month"
+	PeriodQuarter = "quarter"
+	PeriodYear    = "year"
+)
+
+func ValidBillingPeriod(p string) bool {
+	switch p {
+	case PeriodWeek, PeriodMonth, PeriodQuarter, PeriodYear:
+		return true
+	}
+	return false
+}
+
+// Статусы подписки на текущую дату.
+const (
+	StatusUpcoming = "upcoming"
+	StatusActive   = "active"
+	StatusPaused   = "paused"
+	StatusEnded    = "ended"
+)
+
+// Режимы расчёта Total.
+const (
+	// TotalModeCharges считает фактические списания, попавшие в период.
+	TotalModeCharges = "charges"
+	// TotalModeSpread распределяет стоимость списания равномерно по месяцам.
+	TotalModeSpread = "spread"
+)
+
+// StartMonth/EndMonth в Subscription — YYYY-MM, если подписка начинается с первого числа
+// (заканчивается последним днём месяца), иначе YYYY-MM-DD. Во входных данных принимаются
+// оба формата; сервис приводит их к YYYY-MM-DD.
+//
+// Subscription.Price — сумма одного списания раз в BillingInterval периодов BillingPeriod,
+// MonthlyPrice — та же стоимость в пересчёте на месяц.
 type Subscription struct {
-	ID           uuid.UUID
-	ServiceName  string
-	MonthlyPrice string
-	UserID       uuid.UUID
-	StartMonth   string
-	EndMonth     *string
+	ID              uuid.UUID
+	ServiceName     string
+	ServiceID       *uuid.UUID
+	Price           string
+	BillingPeriod   string
+	BillingInterval int
+	MonthlyPrice    string
+	Currency        string
+	UserID          uuid.UUID
+	StartMonth      string
+	EndMonth        *string
+	// TrialEndMonth — последний день бесплатного пробного периода (формат как у EndMonth).
+	TrialEndMonth *string
+	Status        string
+	// NoticeDays — срок уведомления об отмене в днях.
+	NoticeDays   int
+	Cancellation *Cancellation
+	Tags         []string
 	CreatedAt    time.Time
 	UpdatedAt    time.Time
+	// DeletedAt — когда подписка перемещена в корзину.
+	DeletedAt *time.Time
+	// Version увеличивается при каждом изменении подписки.
+	Version int
 }
 
+// CreateInput.ServiceID — запись каталога; без него подписка привязывается к сервису, имя или
+// псевдоним которого совпадает с ServiceName. Пустой ServiceName при заданном ServiceID
+// заменяется каноническим названием.
 type CreateInput struct {
-	ServiceName  string
-	MonthlyPrice string
-	UserID       uuid.UUID
-	StartMonth   string
-	EndMonth     *string
+	ServiceName     string
+	ServiceID       *uuid.UUID
+	Price           string
+	BillingPeriod   string
+	BillingInterval int
+	Currency        string
+	UserID          uuid.UUID
+	StartMonth      string
+	EndMonth        *string
+	// Пробный период задаётся длиной в месяцах (TrialMonths) или последним месяцем (TrialEndMonth).
+	TrialMonths   int
+	TrialEndMonth *string
+	NoticeDays    int
+	Tags          []string
+	// AllowOverlap разрешает пересечение с подписками того же пользователя на тот же сервис.
+	AllowOverlap bool
 }
 
 type UpdateInput struct {
-	ServiceName  *string
-	MonthlyPrice *string
-	StartMonth   *string
-	EndMonth     *string
+	ServiceName *string
+	// ServiceID == uuid.Nil отвязывает подписку от каталога.
+	ServiceID *uuid.UUID
+	Price     *string
+	// PriceEffectiveMonth (YYYY-MM) — с какого месяца действует новая Price.
+	PriceEffectiveMonth *string
+	BillingPeriod       *string
+	BillingInterval     *int
+	Currency            *string
+	StartMonth          *string
+	EndMonth            *string
+	// TrialMonths считается от даты начала; 0 или пустой TrialEndMonth убирают пробный период.
+	TrialMonths   *int
+	TrialEndMonth *string
+	NoticeDays    *int
+	// Tags заменяет все теги; пустой срез удаляет их.
+	Tags         *[]string
+	AllowOverlap bool
+	// IfVersion — изменить, только если текущая версия совпадает (иначе ErrVersionMismatch).
+	IfVersion *int
+}
+
+// PriceChange — цена подписки, действующая с EffectiveMonth (YYYY-MM).
+// Scheduled — изменение запланировано на будущий месяц.
+type PriceChange struct {
+	EffectiveMonth string
+	Price          string
+	Scheduled      bool
+	CreatedAt      time.Time
+}
+
+// Cancellation — отмена подписки: дата запроса (YYYY-MM-DD), кто и почему отменил.
+type Cancellation struct {
+	RequestedAt string
+	By          string
+	Reason      string
+	CancelledAt time.Time
 }
 
+// CancelInput — запрос на отмену; пустой RequestedAt — сегодня.
+type CancelInput struct {
+	RequestedAt string
+	By          string
+	Reason      string
+}
+
+// Pause — приостановка подписки с From (включительно) до ResumeAt (первый день после паузы);
+// ResumeAt == nil — пауза без даты возобновления.
+type Pause struct {
+	ID        uuid.UUID
+	From      string
+	ResumeAt  *string
+	CreatedAt time.Time
+}
+
+// DuplicateGroup — подписки пользователя на один сервис (ServiceName — нормализованное
+// название), периоды которых пересекаются.
+type DuplicateGroup struct {
+	UserID        uuid.UUID
+	ServiceName   string
+	Subscriptions []Subscription
+}
+
+// Режимы фильтра по тегам.
+const (
+	// TagMatchAny — подписка с хотя бы одним из тегов.
+	TagMatchAny = "any"
+	// TagMatchAll — подписка со всеми тегами.
+	TagMatchAll = "all"
+)
+
+// TagCount — тег и число подписок с ним.
+type TagCount struct {
+	Tag           string
+	Subscriptions int
+}
+
+// ListFilter — фильтры и порядок списка подписок. Цены сравниваются с текущей ценой
+// списания, ActiveAt — первый день месяца, в котором подписка действовала хотя бы день.
 type ListFilter struct {
+	UserID *uuid.UUID
+	// UserIDs — подписки любого из пользователей.
+	UserIDs     []uuid.UUID
+	ServiceName *string
+	// ServiceMatch — ServiceMatchContains (по умолчанию) или ServiceMatchExact.
+	ServiceMatch string
+	ServiceID    *uuid.UUID
+	Tags         []string
+	TagMatch     string
+	MinPrice     *string
+	MaxPrice     *string
+	ActiveAt     *time.Time
+	Statuses     []string
+	StartFrom    *time.Time
+	StartTo      *time.Time
+	CreatedAfter *time.Time
+	// SortBy — одно из полей ListSortFields; пустое — created_at по убыванию.
+	SortBy   string
+	SortDesc bool
+	Limit    int
+	Offset   int
+	// After — продолжить список после этой позиции (вместо Offset).
+	After *ListCursor
+}
+
+// Sort возвращает поле и направление сортировки с учётом значения по умолчанию
+// в виде "поле:asc" или "поле:desc".
+func (f ListFilter) Sort() string {
+	if f.SortBy == "" {
+		return SortCreatedAt + ":desc"
+	}
+	if f.SortDesc {
+		return f.SortBy + ":desc"
+	}
+	return f.SortBy + ":asc"
+}
+
+// Сопоставление service_name в списке: подстрока без учёта регистра или название целиком
+// (без учёта регистра и лишних пробелов).
+const (
+	ServiceMatchContains = "contains"
+	ServiceMatchExact    = "exact"
+)
+
+// Поля сортировки списка; при равенстве порядок определяет id.
+const (
+	SortCreatedAt    = "created_at"
+	SortUpdatedAt    = "updated_at"
+	SortStartMonth   = "start_month"
+	SortEndMonth     = "end_month"
+	SortServiceName  = "service_name"
+	SortPrice        = "price"
+	SortMonthlyPrice = "monthly_price"
+)
+
+var ListSortFields = []string{
+	SortCreatedAt, SortUpdatedAt, SortStartMonth, SortEndMonth, SortServiceName, SortPrice, SortMonthlyPrice,
+}
+
+// ListCursor — позиция в списке: значение поля сортировки Sort у последней подписки
+// страницы (в текстовом виде) и её id. Key для created_at и updated_at — RFC 3339 в UTC,
+// для start_month и end_month — YYYY-MM-DD (бессрочная — infinity), для цен — десятичное число.
+type ListCursor struct {
+	Sort string
+	Key  string
+	ID   uuid.UUID
+}
+
+// SubscriptionPage — страница списка. Next — курсор следующей страницы (nil — страница
+// последняя), Total — число подписок под фильтром, если оно запрашивалось.
+type SubscriptionPage struct {
+	Items []Subscription
+	Next  *ListCursor
+	Total *int
+}
+
+// TotalFilter — фильтры для расчёта сумм за период.
+type TotalFilter struct {
 	UserID      *uuid.UUID
 	ServiceName *string
-	Limit       int
-	Offset      int
+	// ServiceExact сравнивает ServiceName с названием целиком (без учёта регистра и лишних пробелов),
+	// иначе ищется подстрока.
+	ServiceExact bool
+	// ServiceID — точный фильтр по записи каталога.
+	ServiceID *uuid.UUID
+	// Tags с режимом TagMatch (any по умолчанию).
+	Tags     []string
+	TagMatch string
+	Mode     string
+	// Prorate учитывает первый и последний месяц пропорционально дням подписки (только в режиме spread).
+	Prorate bool
+	// OpenEnded: true — только бессрочные подписки (без end_month), false — только с датой окончания.
+	OpenEnded *bool
+}
+
+// CurrencyTotal — сумма за период в одной валюте.
+type CurrencyTotal struct {
+	Currency string
+	Total    string
+}
+
+// Уровни достоверности прогноза.
+const (
+	ConfidenceHigh   = "high"
+	ConfidenceMedium = "medium"
+	ConfidenceLow    = "low"
+)
+
+// ForecastMonth — прогноз начислений на месяц. OpenEndedTotals — часть Totals от бессрочных
+// подписок, которые считаются продолжающимися.
+type ForecastMonth struct {
+	Month                  string
+	ActiveSubscriptions    int
+	OpenEndedSubscriptions int
+	Totals                 []CurrencyTotal
+	OpenEndedTotals        []CurrencyTotal
+	Confidence             string
+	Note                   string
+}
+
+// Ключи группировки сумм.
+const (
+	GroupByServiceName = "service_name"
+	GroupByUserID      = "user_id"
+	GroupByMonth       = "month"
+)
+
+// GroupTotal — сумма группы в одной валюте. Keys — значения ключей группировки; у свёрнутой
+// группы other Keys == nil, а Groups — число объединённых в неё групп.
+type GroupTotal struct {
+	Keys     map[string]string
+	Currency string
+	Total    string
+	Other    bool
+	Groups   int
+}
+
+type GroupedTotals struct {
+	Groups      []GroupTotal
+	GrandTotals []CurrencyTotal
+}
+
+// MonthBreakdown — начисления за месяц (YYYY-MM) по валютам и число активных подписок.
+type MonthBreakdown struct {
+	Month               string
+	ActiveSubscriptions int
+	Totals              []CurrencyTotal
 }
@@ -0,0 +1,239 @@
+package budget
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"crud_ef/internal/domain"
+
+	"github.com/google/uuid"
+)
+
+type Repository interface {
+	Create(ctx context.Context, in domain.BudgetInput) (domain.Budget, error)
+	Get(ctx context.Context, id uuid.UUID) (domain.Budget, error)
+	List(ctx context.Context) ([]domain.Budget, error)
+	Update(ctx context.Context, id uuid.UUID, in domain.BudgetInput) (domain.Budget, error)
+	Delete(ctx context.Context, id uuid.UUID) (bool, error)
+	// Matching возвращает бюджеты, в область которых попадает подписка: её владельца или участников,
+	// её сервиса или глобальные.
+	Matching(ctx context.Context, sub domain.Subscription) ([]domain.Budget, error)
+	// AddAlert сохраняет оповещение; false — порог в этом месяце уже срабатывал.
+	AddAlert(ctx context.Context, a domain.BudgetAlert) (bool, error)
+	Alerts(ctx context.Context, id uuid.UUID) ([]domain.BudgetAlert, error)
+}
+
+// Spending считает расходы так же, как Total подписок, с пересчётом в валюту бюджета.
+type Spending interface {
+	ConvertedTotal(ctx context.Context, from, to time.Time, f domain.TotalFilter, currency string) (domain.ConvertedTotal, error)
+}
+
+// Notifier доставляет оповещения о превышении порогов.
+type Notifier interface {
+	BudgetAlert(ctx context.Context, a domain.BudgetAlert)
+}
+
+type Service struct {
+	repo     Repository
+	spending Spending
+	notifier Notifier
+}
+
+func NewService(repo Repository, spending Spending, notifier Notifier) *Service {
+	return &Service{repo: repo, spending: spending, notifier: notifier}
+}
+
+func validLimit(l string) bool {
+	v, err := strconv.ParseFloat(strings.TrimSpace(l), 64)
+	return err == nil && v > 0
+}
+
+// normalize проверяет бюджет, подставляет значения по умолчанию и сортирует пороги.
+func normalize(in domain.BudgetInput) (domain.BudgetInput, error) {
+	switch in.Scope {
+	case domain.BudgetScopeUser:
+		if in.UserID == nil {
+			return in, errors.New("invalid budget: user scope requires user_id")
+		}
+		in.ServiceName = nil
+	case domain.BudgetScopeService:
+		if in.ServiceName == nil || strings.TrimSpace(*in.ServiceName) == "" {
+			return in, errors.New("invalid budget: service scope requires service_name")
+		}
+		in.UserID = nil
+	case domain.BudgetScopeGlobal:
+		in.UserID, in.ServiceName = nil, nil
+	default:
+		return in, errors.New("invalid scope (user, service, global)")
+	}
+	if !validLimit(in.MonthlyLimit) {
+		return in, errors.New("invalid monthly_limit")
+	}
+	in.MonthlyLimit = strings.TrimSpace(in.MonthlyLimit)
+	if in.Currency == "" {
+		in.Currency = domain.DefaultCurrency
+	}
+	cur, ok := domain.NormalizeCurrency(in.Currency)
+	if !ok {
+		return in, errors.New("invalid currency (ISO 4217)")
+	}
+	in.Currency = cur
+	switch in.Mode {
+	case "":
+		in.Mode = domain.TotalModeCharges
+	case domain.TotalModeCharges, domain.TotalModeSpread:
+	default:
+		return in, errors.New("invalid mode (charges or spread)")
+	}
+	if len(in.Thresholds) == 0 {
+		in.Thresholds = domain.DefaultThresholds
+	}
+	ts := make([]int, 0, len(in.Thresholds))
+	seen := make(map[int]bool, len(in.Thresholds))
+	for _, t := range in.Thresholds {
+		if t < 1 || t > 1000 {
+			return in, errors.New("invalid thresholds (1..1000 %)")
+		}
+		if !seen[t] {
+			seen[t] = true
+			ts = append(ts, t)
+		}
+	}
+	sort.Ints(ts)
+	in.Thresholds = ts
+	return in, nil
+}
+
+func (s *Service) Create(ctx context.Context, in domain.BudgetInput) (domain.Budget, error) {
+	in, err := normalize(in)
+	if err != nil {
+		return domain.Budget{}, err
+	}
+	return s.repo.Create(ctx, in)
+}
+
+func (s *Service) Get(ctx context.Context, id uuid.UUID) (domain.Budget, error) {
+	return s.repo.Get(ctx, id)
+}
+
+func (s *Service) List(ctx context.Context) ([]domain.Budget, error) {
+	return s.repo.List(ctx)
+}
+
+// Update полностью заменяет настройки бюджета.
+func (s *Service) Update(ctx context.Context, id uuid.UUID, in domain.BudgetInput) (domain.Budget, error) {
+	in, err := normalize(in)
+	if err != nil {
+		return domain.Budget{}, err
+	}
+	return s.repo.Update(ctx, id, in)
+}
+
+func (s *Service) Delete(ctx context.Context, id uuid.UUID) (bool, error) {
+	return s.repo.Delete(ctx, id)
+}
+
+func (s *Service) Alerts(ctx context.Context, id uuid.UUID) ([]domain.BudgetAlert, error) {
+	if _, err := s.repo.Get(ctx, id); err != nil {
+		return nil, err
+	}
+	return s.repo.Alerts(ctx, id)
+}
+
+// Status сравнивает лимит с расходами за месяц monthStr (YYYY-MM, по умолчанию текущий).
+func (s *Service) Status(ctx context.Context, id uuid.UUID, monthStr string) (domain.BudgetStatus, error) {
+	month := currentMonth()
+	if monthStr != "" {
+		t, err := time.Parse("2006-01", monthStr)
+		if err != nil {
+			return domain.BudgetStatus{}, errors.New("invalid month (YYYY-MM)")
+		}
+		month = t
+	}
+	b, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return domain.BudgetStatus{}, err
+	}
+	return s.status(ctx, b, month)
+}
+
+func (s *Service) status(ctx context.Context, b domain.Budget, month time.Time) (domain.BudgetStatus, error) {
+	total, err := s.spending.ConvertedTotal(ctx, month, month, b.Filter(), b.Currency)
+	if err != nil {
+		return domain.BudgetStatus{}, err
+	}
+	spent, _ := strconv.ParseFloat(total.Total, 64)
+	limit, _ := strconv.ParseFloat(b.MonthlyLimit, 64)
+
+	st := domain.BudgetStatus{
+		Budget:    b,
+		Month:     month.Format("2006-01"),
+		Spent:     total.Total,
+		Remaining: strconv.FormatFloat(limit-spent, 'f', 2, 64),
+		Percent:   math.Round(spent/limit*1000) / 10,
+		Reached:   []int{},
+		Over:      spent > limit,
+		Missing:   total.Missing,
+	}
+	for _, t := range b.Thresholds {
+		if spent*100 >= limit*float64(t) {
+			st.Reached = append(st.Reached, t)
+		}
+	}
+	return st, nil
+}
+
+// CheckSubscription проверяет бюджеты, в область которых попадает подписка, за текущий месяц
+// и месяц первого списания, если он позже. О каждом впервые достигнутом в месяце пороге
+// сохраняется и отправляется оповещение.
+func (s *Service) CheckSubscription(ctx context.Context, sub domain.Subscription) error {
+	budgets, err := s.repo.Matching(ctx, sub)
+	if err != nil || len(budgets) == 0 {
+		return err
+	}
+	months := []time.Time{currentMonth()}
+	if len(sub.StartMonth) >= 7 {
+		if start, err := time.Parse("2006-01", sub.StartMonth[:7]); err == nil && start.After(months[0]) {
+			months = append(months, start)
+		}
+	}
+	id := sub.ID
+	for _, b := range budgets {
+		for _, m := range months {
+			st, err := s.status(ctx, b, m)
+			if err != nil {
+				return err
+			}
+			for _, t := range st.Reached {
+				a := domain.BudgetAlert{
+					BudgetID:       b.ID,
+					Month:          st.Month,
+					Threshold:      t,
+					Spent:          st.Spent,
+					MonthlyLimit:   b.MonthlyLimit,
+					Currency:       b.Currency,
+					SubscriptionID: &id,
+					CreatedAt:      time.Now().UTC(),
+				}
+				added, err := s.repo.AddAlert(ctx, a)
+				if err != nil {
+					return err
+				}
+				if added && s.notifier != nil {
+					s.notifier.BudgetAlert(ctx, a)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func currentMonth() time.Time {
+	now := time.Now().UTC()
+	return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
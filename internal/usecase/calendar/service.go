@@ -0,0 +1,82 @@
+package calendar
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+
+	"crud_ef/internal/domain"
+
+	"github.com/google/uuid"
+)
+
+// ErrInvalidToken — токен не передан, не совпадает или не выпускался.
+var ErrInvalidToken = errors.New("invalid calendar token")
+
+// Repository хранит хеши токенов календарных лент.
+type Repository interface {
+	// SetToken сохраняет хеш токена пользователя, заменяя прежний.
+	SetToken(ctx context.Context, userID uuid.UUID, hash []byte) error
+	// TokenHash возвращает хеш токена или domain.ErrNotFound.
+	TokenHash(ctx context.Context, userID uuid.UUID) ([]byte, error)
+	DeleteToken(ctx context.Context, userID uuid.UUID) (bool, error)
+}
+
+// Events — списания подписок пользователя для ленты.
+type Events interface {
+	Calendar(ctx context.Context, userID uuid.UUID) ([]domain.CalendarEvent, error)
+}
+
+type Service struct {
+	repo   Repository
+	events Events
+}
+
+func NewService(repo Repository, events Events) *Service {
+	return &Service{repo: repo, events: events}
+}
+
+// IssueToken выпускает новый токен ленты; прежний токен перестаёт действовать.
+// Токен возвращается только здесь, в базе хранится его хеш.
+func (s *Service) IssueToken(ctx context.Context, userID uuid.UUID) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+	if err := s.repo.SetToken(ctx, userID, hashToken(token)); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// RevokeToken отключает ленту пользователя; false — токена не было.
+func (s *Service) RevokeToken(ctx context.Context, userID uuid.UUID) (bool, error) {
+	return s.repo.DeleteToken(ctx, userID)
+}
+
+// Feed проверяет токен и возвращает события ленты пользователя.
+func (s *Service) Feed(ctx context.Context, userID uuid.UUID, token string) ([]domain.CalendarEvent, error) {
+	if token == "" {
+		return nil, ErrInvalidToken
+	}
+	stored, err := s.repo.TokenHash(ctx, userID)
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil, ErrInvalidToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare(stored, hashToken(token)) != 1 {
+		return nil, ErrInvalidToken
+	}
+	return s.events.Calendar(ctx, userID)
+}
+
+func hashToken(token string) []byte {
+	h := sha256.Sum256([]byte(token))
+	return h[:]
+}
@@ -0,0 +1,92 @@
+package catalog
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+
+	"crud_ef/internal/domain"
+
+	"github.com/google/uuid"
+)
+
+type Repository interface {
+	Create(ctx context.Context, in domain.CatalogServiceInput) (domain.CatalogService, error)
+	Get(ctx context.Context, id uuid.UUID) (domain.CatalogService, error)
+	List(ctx context.Context, f domain.CatalogFilter) ([]domain.CatalogService, error)
+	Update(ctx context.Context, id uuid.UUID, in domain.CatalogServiceInput) (domain.CatalogService, error)
+	Delete(ctx context.Context, id uuid.UUID) (bool, error)
+}
+
+type Service struct {
+	repo Repository
+}
+
+func NewService(repo Repository) *Service {
+	return &Service{repo: repo}
+}
+
+// normalize проверяет запись, убирает пустые и повторяющиеся (с учётом нормализации) псевдонимы.
+func normalize(in domain.CatalogServiceInput) (domain.CatalogServiceInput, error) {
+	in.Name = strings.Join(strings.Fields(in.Name), " ")
+	if in.Name == "" {
+		return in, errors.New("invalid name")
+	}
+	seen := map[string]bool{domain.NormalizeServiceName(in.Name): true}
+	aliases := make([]string, 0, len(in.Aliases))
+	for _, a := range in.Aliases {
+		a = strings.Join(strings.Fields(a), " ")
+		if key := domain.NormalizeServiceName(a); a != "" && !seen[key] {
+			seen[key] = true
+			aliases = append(aliases, a)
+		}
+	}
+	in.Aliases = aliases
+	if in.Category != nil {
+		c := strings.TrimSpace(*in.Category)
+		in.Category = &c
+		if c == "" {
+			in.Category = nil
+		}
+	}
+	if in.VendorURL != nil {
+		v := strings.TrimSpace(*in.VendorURL)
+		in.VendorURL = &v
+		if v == "" {
+			in.VendorURL = nil
+		} else if u, err := url.Parse(v); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return in, errors.New("invalid vendor_url")
+		}
+	}
+	return in, nil
+}
+
+func (s *Service) Create(ctx context.Context, in domain.CatalogServiceInput) (domain.CatalogService, error) {
+	in, err := normalize(in)
+	if err != nil {
+		return domain.CatalogService{}, err
+	}
+	return s.repo.Create(ctx, in)
+}
+
+func (s *Service) Get(ctx context.Context, id uuid.UUID) (domain.CatalogService, error) {
+	return s.repo.Get(ctx, id)
+}
+
+func (s *Service) List(ctx context.Context, f domain.CatalogFilter) ([]domain.CatalogService, error) {
+	return s.repo.List(ctx, f)
+}
+
+// Update полностью заменяет запись каталога.
+func (s *Service) Update(ctx context.Context, id uuid.UUID, in domain.CatalogServiceInput) (domain.CatalogService, error) {
+	in, err := normalize(in)
+	if err != nil {
+		return domain.CatalogService{}, err
+	}
+	return s.repo.Update(ctx, id, in)
+}
+
+func (s *Service) Delete(ctx context.Context, id uuid.UUID) (bool, error) {
+	return s.repo.Delete(ctx, id)
+}
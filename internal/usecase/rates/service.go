@@ -0,0 +1,117 @@
+package rates
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"crud_ef/internal/domain"
+)
+
+type Repository interface {
+	Upsert(ctx context.Context, rates []domain.ExchangeRate) (int, error)
+	List(ctx context.Context, f domain.RateFilter) ([]domain.ExchangeRate, error)
+	Delete(ctx context.Context, date time.Time, base, quote string) (bool, error)
+}
+
+type Service struct {
+	repo Repository
+}
+
+func NewService(repo Repository) *Service {
+	return &Service{repo: repo}
+}
+
+func parseDate(s string) (time.Time, error) {
+	return time.Parse("2006-01-02", s)
+}
+
+func validRate(r string) bool {
+	v, err := strconv.ParseFloat(strings.TrimSpace(r), 64)
+	return err == nil && v > 0
+}
+
+// normalize проверяет курс и приводит коды валют к верхнему регистру.
+func normalize(rt domain.ExchangeRate) (domain.ExchangeRate, error) {
+	if _, err := parseDate(rt.Date); err != nil {
+		return rt, errors.New("invalid date (YYYY-MM-DD)")
+	}
+	base, ok := domain.NormalizeCurrency(rt.Base)
+	if !ok {
+		return rt, errors.New("invalid base (ISO 4217)")
+	}
+	quote, ok := domain.NormalizeCurrency(rt.Quote)
+	if !ok {
+		return rt, errors.New("invalid quote (ISO 4217)")
+	}
+	if base == quote {
+		return rt, errors.New("invalid pair: base equals quote")
+	}
+	if !validRate(rt.Rate) {
+		return rt, errors.New("invalid rate")
+	}
+	rt.Base, rt.Quote, rt.Rate = base, quote, strings.TrimSpace(rt.Rate)
+	return rt, nil
+}
+
+// Upsert валидирует и сохраняет курсы. Если хотя бы один курс некорректен, ничего не сохраняется.
+func (s *Service) Upsert(ctx context.Context, rates []domain.ExchangeRate) (int, error) {
+	if len(rates) == 0 {
+		return 0, errors.New("invalid payload: no rates")
+	}
+	out := make([]domain.ExchangeRate, 0, len(rates))
+	for i, rt := range rates {
+		n, err := normalize(rt)
+		if err != nil {
+			return 0, errors.New(err.Error() + " at #" + strconv.Itoa(i))
+		}
+		out = append(out, n)
+	}
+	return s.repo.Upsert(ctx, out)
+}
+
+func (s *Service) List(ctx context.Context, f domain.RateFilter) ([]domain.ExchangeRate, error) {
+	if f.Base != nil {
+		c, ok := domain.NormalizeCurrency(*f.Base)
+		if !ok {
+			return nil, errors.New("invalid base (ISO 4217)")
+		}
+		f.Base = &c
+	}
+	if f.Quote != nil {
+		c, ok := domain.NormalizeCurrency(*f.Quote)
+		if !ok {
+			return nil, errors.New("invalid quote (ISO 4217)")
+		}
+		f.Quote = &c
+	}
+	if f.From != nil {
+		if _, err := parseDate(*f.From); err != nil {
+			return nil, errors.New("invalid from (YYYY-MM-DD)")
+		}
+	}
+	if f.To != nil {
+		if _, err := parseDate(*f.To); err != nil {
+			return nil, errors.New("invalid to (YYYY-MM-DD)")
+		}
+	}
+	return s.repo.List(ctx, f)
+}
+
+func (s *Service) Delete(ctx context.Context, date, base, quote string) (bool, error) {
+	d, err := parseDate(date)
+	if err != nil {
+		return false, errors.New("invalid date (YYYY-MM-DD)")
+	}
+	b, ok := domain.NormalizeCurrency(base)
+	if !ok {
+		return false, errors.New("invalid base (ISO 4217)")
+	}
+	q, ok := domain.NormalizeCurrency(quote)
+	if !ok {
+		return false, errors.New("invalid quote (ISO 4217)")
+	}
+	return s.repo.Delete(ctx, d, b, q)
+}
@@ -0,0 +1,97 @@
+package subscription
+
+import (
+	"context"
+
+	"crud_ef/internal/domain"
+
+	"github.com/google/uuid"
+)
+
+// Calendar возвращает повторяющиеся списания действующих, приостановленных и предстоящих подписок
+// пользователя. Списания считаются от первого дня после пробного периода, как в Total; паузы пропускаются.
+func (s *Service) Calendar(ctx context.Context, userID uuid.UUID) ([]domain.CalendarEvent, error) {
+	pauses, err := s.repo.UserPauses(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	var out []domain.CalendarEvent
+	err = s.repo.Stream(ctx, domain.ListFilter{UserID: &userID}, func(sub domain.Subscription) error {
+		switch sub.Status {
+		case domain.StatusActive, domain.StatusUpcoming, domain.StatusPaused:
+		default:
+			return nil
+		}
+		if ev, ok := calendarEvent(sub, pauses[sub.ID]); ok {
+			out = append(out, ev)
+		}
+		return nil
+	})
+	return out, err
+}
+
+// calendarEvent находит первое платное списание и списания, пропускаемые из-за пауз; false —
+// у подписки нет платных списаний.
+func calendarEvent(sub domain.Subscription, pauses []domain.Pause) (domain.CalendarEvent, bool) {
+	first, err := paidFrom(sub)
+	if err != nil {
+		return domain.CalendarEvent{}, false
+	}
+	interval := max(sub.BillingInterval, 1)
+	ev := domain.CalendarEvent{
+		SubscriptionID:  sub.ID,
+		ServiceName:     sub.ServiceName,
+		Price:           sub.Price,
+		Currency:        sub.Currency,
+		BillingPeriod:   sub.BillingPeriod,
+		BillingInterval: interval,
+		First:           first,
+		Day:             first.Day(),
+		NoticeDays:      sub.NoticeDays,
+		Version:         sub.Version,
+		UpdatedAt:       sub.UpdatedAt,
+	}
+	if sub.EndMonth != nil {
+		end, err := parseEnd(*sub.EndMonth)
+		if err != nil || end.Before(first) {
+			return domain.CalendarEvent{}, false
+		}
+		ev.Until = &end
+	}
+	// Бессрочная пауза обрывает повторения накануне своего начала.
+	for _, p := range pauses {
+		if p.ResumeAt != nil {
+			continue
+		}
+		if from, err := parseStart(p.From); err == nil {
+			stop := from.AddDate(0, 0, -1)
+			if ev.Until == nil || stop.Before(*ev.Until) {
+				ev.Until = &stop
+			}
+		}
+	}
+	if ev.Until != nil && ev.Until.Before(ev.First) {
+		return domain.CalendarEvent{}, false
+	}
+	// Списания с датой в [начало паузы, возобновление) пропускаются, как is_paused в Total.
+	for _, p := range pauses {
+		if p.ResumeAt == nil {
+			continue
+		}
+		from, errFrom := parseStart(p.From)
+		resume, errResume := parseStart(*p.ResumeAt)
+		if errFrom != nil || errResume != nil {
+			continue
+		}
+		for j := 0; ; j += interval {
+			at := addPeriods(first, sub.BillingPeriod, j)
+			if !at.Before(resume) || (ev.Until != nil && at.After(*ev.Until)) {
+				break
+			}
+			if !at.Before(from) {
+				ev.Skipped = append(ev.Skipped, at)
+			}
+		}
+	}
+	return ev, true
+}
@@ -0,0 +1,71 @@
+package subscription
+
+import (
+	"testing"
+	"time"
+
+	"crud_ef/internal/domain"
+)
+
+func TestCalendarEventPauses(t *testing.T) {
+	sub := domain.Subscription{
+		ServiceName: "Netflix", Price: "900", Currency: "RUB",
+		BillingPeriod: domain.PeriodMonth, BillingInterval: 1, StartMonth: "2025-01-31",
+	}
+	str := func(s string) *string { return &s }
+	dates := func(ts []time.Time) []string {
+		out := make([]string, len(ts))
+		for i, t := range ts {
+			out[i] = t.Format(dateLayout)
+		}
+		return out
+	}
+
+	t.Run("bounded pause", func(t *testing.T) {
+		// Списания 28.02 и 31.03 внутри паузы, 30.04 — уже после возобновления.
+		ev, ok := calendarEvent(sub, []domain.Pause{{From: "2025-02-10", ResumeAt: str("2025-04-01")}})
+		if !ok {
+			t.Fatal("no event")
+		}
+		got := dates(ev.Skipped)
+		if len(got) != 2 || got[0] != "2025-02-28" || got[1] != "2025-03-31" {
+			t.Errorf("Skipped = %v, want [2025-02-28 2025-03-31]", got)
+		}
+		if ev.Until != nil {
+			t.Errorf("Until = %v, want nil", ev.Until)
+		}
+	})
+
+	t.Run("open-ended pause", func(t *testing.T) {
+		ev, ok := calendarEvent(sub, []domain.Pause{{From: "2025-03-01"}})
+		if !ok {
+			t.Fatal("no event")
+		}
+		if ev.Until == nil || !ev.Until.Equal(day("2025-02-28")) || len(ev.Skipped) != 0 {
+			t.Errorf("Until = %v, Skipped = %v; want 2025-02-28 and none", ev.Until, ev.Skipped)
+		}
+	})
+
+	t.Run("paused before first charge", func(t *testing.T) {
+		if ev, ok := calendarEvent(sub, []domain.Pause{{From: "2025-01-15"}}); ok {
+			t.Errorf("got event %+v, want none", ev)
+		}
+	})
+
+	t.Run("pause after end", func(t *testing.T) {
+		s := sub
+		s.EndMonth = str("2025-02-28")
+		ev, ok := calendarEvent(s, []domain.Pause{{From: "2025-03-10", ResumeAt: str("2025-05-01")}})
+		if !ok || len(ev.Skipped) != 0 {
+			t.Errorf("ok = %v, Skipped = %v; want event without skipped dates", ok, dates(ev.Skipped))
+		}
+	})
+	t.Run("trial", func(t *testing.T) {
+		s := sub
+		s.StartMonth, s.TrialEndMonth = "2025-01-10", str("2025-02-14")
+		ev, ok := calendarEvent(s, nil)
+		if !ok || !ev.First.Equal(day("2025-02-15")) || ev.Day != 15 {
+			t.Errorf("First = %v, Day = %d; want 2025-02-15 and 15", ev.First, ev.Day)
+		}
+	})
+}
@@ -0,0 +1,26 @@
+package subscription
+
+import (
+	"errors"
+
+	"crud_ef/internal/domain"
+
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrAlreadyEnded и ErrAlreadyCancelled репозиторий возвращает и сам, проверяя под блокировкой строки.
+	ErrAlreadyEnded     = domain.ErrAlreadyEnded
+	ErrAlreadyCancelled = domain.ErrAlreadyCancelled
+	// ErrOverlap — период пересекается с подпиской того же пользователя на тот же сервис.
+	ErrOverlap = errors.New("subscription overlaps with an existing subscription of the same user and service")
+)
+
+// OverlapError перечисляет пересекающиеся подписки; errors.Is(err, ErrOverlap) == true.
+type OverlapError struct {
+	Conflicts []uuid.UUID
+}
+
+func (e *OverlapError) Error() string { return ErrOverlap.Error() }
+
+func (e *OverlapError) Unwrap() error { return ErrOverlap }
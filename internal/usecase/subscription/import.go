@@ -0,0 +1,158 @@
+package subscription
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"crud_ef/internal/domain"
+
+	"github.com/google/uuid"
+)
+
+// MaxImportRows — наибольшее число строк в одном импорте.
+const MaxImportRows = 10000
+
+// importedRow — проверенная строка, которая будет создана.
+type importedRow struct {
+	result int
+	in     domain.CreateInput
+	start  time.Time
+	end    *time.Time
+}
+
+// catalogCache запоминает ответы каталога на время импорта: строки файла обычно ссылаются
+// на немногие сервисы, и каталог не запрашивается для каждой строки. Методы nil-кэша
+// обращаются к каталогу напрямую.
+type catalogCache struct {
+	services map[uuid.UUID]catalogResult
+	matches  map[string]*uuid.UUID
+}
+
+type catalogResult struct {
+	service domain.CatalogService
+	err     error
+}
+
+func newCatalogCache() *catalogCache {
+	return &catalogCache{services: map[uuid.UUID]catalogResult{}, matches: map[string]*uuid.UUID{}}
+}
+
+// service — запись каталога по id; запоминается и ответ «нет в каталоге».
+func (c *catalogCache) service(ctx context.Context, s *Service, id uuid.UUID) (domain.CatalogService, error) {
+	if c == nil {
+		return s.catalogService(ctx, id)
+	}
+	if r, ok := c.services[id]; ok {
+		return r.service, r.err
+	}
+	svc, err := s.catalogService(ctx, id)
+	if err == nil || strings.HasPrefix(err.Error(), "invalid") {
+		c.services[id] = catalogResult{service: svc, err: err}
+	}
+	return svc, err
+}
+
+// match — сервис каталога по названию; названия сравниваются после NormalizeServiceName.
+func (c *catalogCache) match(ctx context.Context, s *Service, name string) (*uuid.UUID, error) {
+	if c == nil {
+		return s.matchService(ctx, name)
+	}
+	key := domain.NormalizeServiceName(name)
+	if id, ok := c.matches[key]; ok {
+		return id, nil
+	}
+	id, err := s.matchService(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	c.matches[key] = id
+	return id, nil
+}
+
+// Import проверяет строки по правилам Create и создаёт прошедшие проверку подписки в одной
+// транзакции. Строки, пересекающиеся с предыдущими строками файла или (в той же транзакции)
+// с существующими подписками, пропускаются как дубли (кроме allowOverlap). При dryRun ничего
+// не сохраняется, а прошедшие проверку строки получают статус valid.
+func (s *Service) Import(ctx context.Context, rows []domain.ImportRow, dryRun, allowOverlap bool) ([]domain.ImportResult, error) {
+	if len(rows) > MaxImportRows {
+		return nil, errors.New("invalid import: too many rows")
+	}
+	results := make([]domain.ImportResult, len(rows))
+	var accepted []importedRow
+	cache := newCatalogCache()
+	for i, row := range rows {
+		res := &results[i]
+		res.Line = row.Line
+		if row.Error != "" {
+			res.Status, res.Error = domain.ImportInvalid, row.Error
+			continue
+		}
+		in := row.Input
+		start, err := s.prepareCreate(ctx, &in, cache)
+		if err != nil {
+			if !strings.HasPrefix(err.Error(), "invalid") {
+				return nil, err
+			}
+			res.Status, res.Error = domain.ImportInvalid, err.Error()
+			continue
+		}
+		end := endDate(in.EndMonth)
+		if !allowOverlap {
+			if prev := overlappingRow(accepted, in, start, end); prev != nil {
+				res.Status, res.DuplicateOf = domain.ImportDuplicate, results[prev.result].Line
+				continue
+			}
+		}
+		res.Status = domain.ImportValid
+		accepted = append(accepted, importedRow{result: i, in: in, start: start, end: end})
+	}
+	if len(accepted) == 0 || (dryRun && allowOverlap) {
+		return results, nil
+	}
+
+	items := make([]domain.CreateInput, len(accepted))
+	for i, a := range accepted {
+		items[i] = a.in
+	}
+	subs, conflicts, err := s.repo.Import(ctx, items, !allowOverlap, dryRun)
+	if err != nil {
+		return nil, err
+	}
+	for i, a := range accepted {
+		res := &results[a.result]
+		if len(conflicts[i]) > 0 {
+			res.Status, res.Conflicts = domain.ImportDuplicate, conflicts[i]
+			continue
+		}
+		if dryRun {
+			continue
+		}
+		id := subs[i].ID
+		res.Status, res.SubscriptionID = domain.ImportCreated, &id
+		s.checkBudgets(ctx, subs[i])
+	}
+	return results, nil
+}
+
+// overlappingRow ищет среди принятых строк подписку того же пользователя на тот же сервис
+// с пересекающимся периодом.
+func overlappingRow(accepted []importedRow, in domain.CreateInput, start time.Time, end *time.Time) *importedRow {
+	name := domain.NormalizeServiceName(in.ServiceName)
+	for i := range accepted {
+		a := &accepted[i]
+		if a.in.UserID != in.UserID {
+			continue
+		}
+		sameService := domain.NormalizeServiceName(a.in.ServiceName) == name ||
+			(a.in.ServiceID != nil && in.ServiceID != nil && *a.in.ServiceID == *in.ServiceID)
+		if !sameService {
+			continue
+		}
+		if (end == nil || !end.Before(a.start)) && (a.end == nil || !a.end.Before(start)) {
+			return a
+		}
+	}
+	return nil
+}
@@ -3,9 +3,13 @@ package subscription
 import (
 	"context"
 	"errors"
+	"log"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"crud_ef/internal/domain"
 
@@ -14,21 +18,89 @@ import (
 
 type Repository interface {
 	Create(ctx context.Context, in domain.CreateInput) (domain.Subscription, error)
+	// Import создаёт подписки в одной транзакции; subs и conflicts идут в порядке items. При
+	// checkOverlap в той же транзакции одним запросом ищутся существующие подписки, пересекающиеся
+	// с items[i] (conflicts[i]); такие строки не создаются. При dryRun ничего не создаётся.
+	Import(ctx context.Context, items []domain.CreateInput, checkOverlap, dryRun bool) (subs []domain.Subscription, conflicts [][]uuid.UUID, err error)
 	Get(ctx context.Context, id uuid.UUID) (domain.Subscription, error)
-	List(ctx context.Context, f domain.ListFilter) ([]domain.Subscription, error)
+	List(ctx context.Context, f domain.ListFilter) (domain.SubscriptionPage, error)
+	Stream(ctx context.Context, f domain.ListFilter, fn func(domain.Subscription) error) error
+	Count(ctx context.Context, f domain.ListFilter) (int, error)
 	Update(ctx context.Context, id uuid.UUID, in domain.UpdateInput) (domain.Subscription, error)
-	Delete(ctx context.Context, id uuid.UUID) (bool, error)
-	Total(ctx context.Context, from, to time.Time, userID *uuid.UUID, service *string) (string, error)
+	Delete(ctx context.Context, id uuid.UUID, ifVersion *int) (bool, error)
+	Trash(ctx context.Context, f domain.ListFilter) ([]domain.Subscription, error)
+	// GetDeleted возвращает подписку из корзины.
+	GetDeleted(ctx context.Context, id uuid.UUID) (domain.Subscription, error)
+	Restore(ctx context.Context, id uuid.UUID) (domain.Subscription, error)
+	// Purge окончательно удаляет подписки, перемещённые в корзину раньше before.
+	Purge(ctx context.Context, before time.Time) (int, error)
+	Events(ctx context.Context, f domain.EventFilter) ([]domain.Event, error)
+	AddPrice(ctx context.Context, id uuid.UUID, month time.Time, price string) (domain.PriceChange, error)
+	Prices(ctx context.Context, id uuid.UUID) ([]domain.PriceChange, error)
+	TrialsEnding(ctx context.Context, days int) ([]domain.Subscription, error)
+	Pause(ctx context.Context, id uuid.UUID, from time.Time, resumeAt *time.Time) (bool, error)
+	Resume(ctx context.Context, id uuid.UUID, at time.Time) (bool, error)
+	Pauses(ctx context.Context, id uuid.UUID) ([]domain.Pause, error)
+	// UserPauses возвращает паузы подписок пользователя по ID подписки.
+	UserPauses(ctx context.Context, userID uuid.UUID) (map[uuid.UUID][]domain.Pause, error)
+	Cancel(ctx context.Context, id uuid.UUID, requestedAt, end time.Time, by, reason string) (domain.Subscription, error)
+	// Overlapping возвращает подписки пользователя на сервис с тем же нормализованным названием
+	// или той же записью каталога, период которых пересекается с [start, end] (end == nil — бессрочно).
+	Overlapping(ctx context.Context, userID uuid.UUID, serviceName string, serviceID *uuid.UUID, start time.Time, end *time.Time, exclude *uuid.UUID) ([]uuid.UUID, error)
+	Duplicates(ctx context.Context, userID *uuid.UUID) ([]domain.DuplicateGroup, error)
+	Tags(ctx context.Context, userID *uuid.UUID) ([]domain.TagCount, error)
+	Members(ctx context.Context, id uuid.UUID) ([]domain.Member, error)
+	SetMembers(ctx context.Context, id uuid.UUID, members []domain.Member) ([]domain.Member, error)
+	Settlements(ctx context.Context, from, to time.Time, userID *uuid.UUID) ([]domain.Settlement, error)
+	Total(ctx context.Context, from, to time.Time, f domain.TotalFilter) ([]domain.CurrencyTotal, error)
+	ConvertedTotal(ctx context.Context, from, to time.Time, f domain.TotalFilter, currency string) (domain.ConvertedTotal, error)
+	Breakdown(ctx context.Context, from, to time.Time, f domain.TotalFilter) ([]domain.MonthBreakdown, error)
+	GroupedTotal(ctx context.Context, from, to time.Time, f domain.TotalFilter, groupBy []string, top int) (domain.GroupedTotals, error)
+}
+
+// BudgetChecker проверяет бюджеты, затронутые созданием или изменением подписки.
+type BudgetChecker interface {
+	CheckSubscription(ctx context.Context, sub domain.Subscription) error
+}
+
+// CatalogMatcher — каталог сервисов: проверка service_id и сопоставление названия подписки.
+type CatalogMatcher interface {
+	Get(ctx context.Context, id uuid.UUID) (domain.CatalogService, error)
+	// Match ищет сервис по названию или псевдониму; nil — совпадений нет.
+	Match(ctx context.Context, name string) (*uuid.UUID, error)
 }
 
 type Service struct {
-	repo Repository
+	repo    Repository
+	budgets BudgetChecker
+	catalog CatalogMatcher
 }
 
 func NewService(repo Repository) *Service {
 	return &Service{repo: repo}
 }
 
+// SetBudgetChecker подключает проверку бюджетов после изменений, влияющих на расходы по подписке.
+func (s *Service) SetBudgetChecker(c BudgetChecker) {
+	s.budgets = c
+}
+
+// SetCatalogMatcher подключает каталог сервисов. Без него service_id отклоняется, а подписки
+// не привязываются к каталогу по названию.
+func (s *Service) SetCatalogMatcher(c CatalogMatcher) {
+	s.catalog = c
+}
+
+// checkBudgets не влияет на результат операции: подписка уже сохранена, ошибка только логируется.
+func (s *Service) checkBudgets(ctx context.Context, sub domain.Subscription) {
+	if s.budgets == nil {
+		return
+	}
+	if err := s.budgets.CheckSubscription(ctx, sub); err != nil {
+		log.Printf("budget check for subscription %s: %v", sub.ID, err)
+	}
+}
+
 func parseMonth(s string) (time.Time, error) {
 	t, err := time.Parse("2006-01", s)
 	if err != nil {
@@ -37,6 +109,28 @@ func parseMonth(s string) (time.Time, error) {
 	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC), nil
 }
 
+const dateLayout = "2006-01-02"
+
+// parseStart принимает YYYY-MM-DD или YYYY-MM (с первого числа месяца).
+func parseStart(s string) (time.Time, error) {
+	if t, err := time.Parse(dateLayout, s); err == nil {
+		return t, nil
+	}
+	return parseMonth(s)
+}
+
+// parseEnd принимает YYYY-MM-DD или YYYY-MM (по последний день месяца включительно).
+func parseEnd(s string) (time.Time, error) {
+	if t, err := time.Parse(dateLayout, s); err == nil {
+		return t, nil
+	}
+	t, err := parseMonth(s)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return t.AddDate(0, 1, -1), nil
+}
+
 func validPrice(p string) bool {
 	if p == "" {
 		return false
@@ -59,61 +153,1123 @@ func validPrice(p string) bool {
 	return err == nil
 }
 
+func validBilling(period string, interval int) error {
+	if !domain.ValidBillingPeriod(period) {
+		return errors.New("invalid billing_period (week|month|quarter|year)")
+	}
+	if interval < 1 {
+		return errors.New("invalid billing_interval (>= 1)")
+	}
+	return nil
+}
+
+// validTotalFilter проверяет режим расчёта и фильтр по тегам, подставляя значения по умолчанию.
+func validTotalFilter(f *domain.TotalFilter) error {
+	switch f.Mode {
+	case "":
+		f.Mode = domain.TotalModeCharges
+	case domain.TotalModeCharges, domain.TotalModeSpread:
+	default:
+		return errors.New("invalid mode (charges|spread)")
+	}
+	// Списание с даты начала оплачивает полный расчётный период, поэтому неполный первый
+	// или последний месяц можно учесть только в режиме spread.
+	if f.Prorate && f.Mode != domain.TotalModeSpread {
+		return errors.New("invalid prorate (only with mode=spread)")
+	}
+	tags, match, err := validTagFilter(f.Tags, f.TagMatch)
+	if err != nil {
+		return err
+	}
+	f.Tags, f.TagMatch = tags, match
+	return nil
+}
+
+// normalizeTags приводит теги к нижнему регистру, убирает повторы и сортирует.
+func normalizeTags(tags []string) ([]string, error) {
+	out := make([]string, 0, len(tags))
+	seen := make(map[string]bool, len(tags))
+	for _, t := range tags {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if t == "" || utf8.RuneCountInString(t) > 64 || strings.ContainsAny(t, " \t\n,") {
+			return nil, errors.New("invalid tag: " + strconv.Quote(t))
+		}
+		if !seen[t] {
+			seen[t] = true
+			out = append(out, t)
+		}
+	}
+	sort.Strings(out)
+	return out, nil
+}
+
+func validTagFilter(tags []string, match string) ([]string, string, error) {
+	switch match {
+	case "":
+		match = domain.TagMatchAny
+	case domain.TagMatchAny, domain.TagMatchAll:
+	default:
+		return nil, "", errors.New("invalid tag_match (any|all)")
+	}
+	if len(tags) == 0 {
+		return nil, match, nil
+	}
+	tags, err := normalizeTags(tags)
+	return tags, match, err
+}
+
 func (s *Service) Create(ctx context.Context, in domain.CreateInput) (domain.Subscription, error) {
-	if strings.TrimSpace(in.ServiceName) == "" || !validPrice(in.MonthlyPrice) {
-		return domain.Subscription{}, errors.New("invalid service_name or monthly_price")
+	start, err := s.prepareCreate(ctx, &in, nil)
+	if err != nil {
+		return domain.Subscription{}, err
+	}
+	if !in.AllowOverlap {
+		if err := s.checkOverlap(ctx, in.UserID, in.ServiceName, in.ServiceID, start, endDate(in.EndMonth), nil); err != nil {
+			return domain.Subscription{}, err
+		}
+	}
+	sub, err := s.repo.Create(ctx, in)
+	if err != nil {
+		return sub, err
+	}
+	s.checkBudgets(ctx, sub)
+	return sub, nil
+}
+
+// Replace заменяет подписку целиком: документ проверяется как при Create, отсутствующие
+// необязательные поля (end_month, пробный период, service_id, теги) сбрасываются. Владелец
+// не меняется; цена, отличная от текущей, действует с текущего месяца.
+func (s *Service) Replace(ctx context.Context, id uuid.UUID, in domain.CreateInput, ifVersion *int) (domain.Subscription, error) {
+	cur, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return domain.Subscription{}, err
+	}
+	if in.UserID != cur.UserID {
+		return domain.Subscription{}, errors.New("invalid user_id (owner cannot be changed)")
+	}
+	start, err := s.prepareCreate(ctx, &in, nil)
+	if err != nil {
+		return domain.Subscription{}, err
+	}
+	if !in.AllowOverlap {
+		if err := s.checkOverlap(ctx, in.UserID, in.ServiceName, in.ServiceID, start, endDate(in.EndMonth), &id); err != nil {
+			return domain.Subscription{}, err
+		}
+	}
+
+	clear := ""
+	unlink := uuid.Nil
+	upd := domain.UpdateInput{
+		ServiceName:     &in.ServiceName,
+		ServiceID:       in.ServiceID,
+		BillingPeriod:   &in.BillingPeriod,
+		BillingInterval: &in.BillingInterval,
+		Currency:        &in.Currency,
+		StartMonth:      &in.StartMonth,
+		EndMonth:        in.EndMonth,
+		TrialEndMonth:   in.TrialEndMonth,
+		NoticeDays:      &in.NoticeDays,
+		Tags:            &in.Tags,
+		IfVersion:       ifVersion,
+	}
+	if upd.ServiceID == nil {
+		upd.ServiceID = &unlink
+	}
+	if upd.EndMonth == nil {
+		upd.EndMonth = &clear
+	}
+	if upd.TrialEndMonth == nil {
+		upd.TrialEndMonth = &clear
+	}
+	if !samePrice(in.Price, cur.Price) {
+		month := time.Now().UTC().Format("2006-01")
+		upd.Price = &in.Price
+		upd.PriceEffectiveMonth = &month
+	}
+	sub, err := s.repo.Update(ctx, id, upd)
+	if err != nil {
+		return sub, err
+	}
+	s.checkBudgets(ctx, sub)
+	return sub, nil
+}
+
+// prepareCreate проверяет документ подписки, подставляет значения по умолчанию и запись
+// каталога и возвращает дату начала. cache (может быть nil) переиспользует ответы каталога.
+func (s *Service) prepareCreate(ctx context.Context, in *domain.CreateInput, cache *catalogCache) (time.Time, error) {
+	if in.ServiceID != nil {
+		c, err := cache.service(ctx, s, *in.ServiceID)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if strings.TrimSpace(in.ServiceName) == "" {
+			in.ServiceName = c.Name
+		}
 	}
-	if _, err := parseMonth(in.StartMonth); err != nil {
-		return domain.Subscription{}, errors.New("invalid start_month (YYYY-MM)")
+	if strings.TrimSpace(in.ServiceName) == "" || !validPrice(in.Price) {
+		return time.Time{}, errors.New("invalid service_name or price")
 	}
+	if in.ServiceID == nil {
+		id, err := cache.match(ctx, s, in.ServiceName)
+		if err != nil {
+			return time.Time{}, err
+		}
+		in.ServiceID = id
+	}
+	if in.BillingPeriod == "" {
+		in.BillingPeriod = domain.PeriodMonth
+	}
+	if in.BillingInterval == 0 {
+		in.BillingInterval = 1
+	}
+	if err := validBilling(in.BillingPeriod, in.BillingInterval); err != nil {
+		return time.Time{}, err
+	}
+	if in.Currency == "" {
+		in.Currency = domain.DefaultCurrency
+	}
+	cur, ok := domain.NormalizeCurrency(in.Currency)
+	if !ok {
+		return time.Time{}, errors.New("invalid currency (ISO 4217)")
+	}
+	in.Currency = cur
+	start, err := parseStart(in.StartMonth)
+	if err != nil {
+		return time.Time{}, errors.New("invalid start_month (YYYY-MM or YYYY-MM-DD)")
+	}
+	in.StartMonth = start.Format(dateLayout)
 	if in.EndMonth != nil {
-		if _, err := parseMonth(*in.EndMonth); err != nil {
-			return domain.Subscription{}, errors.New("invalid end_month (YYYY-MM)")
+		end, err := parseEnd(*in.EndMonth)
+		if err != nil {
+			return time.Time{}, errors.New("invalid end_month (YYYY-MM or YYYY-MM-DD)")
+		}
+		if end.Before(start) {
+			return time.Time{}, errors.New("invalid end_month (before start_month)")
 		}
+		e := end.Format(dateLayout)
+		in.EndMonth = &e
 	}
-	return s.repo.Create(ctx, in)
+	if in.NoticeDays < 0 {
+		return time.Time{}, errors.New("invalid notice_days (>= 0)")
+	}
+	if in.Tags, err = normalizeTags(in.Tags); err != nil {
+		return time.Time{}, err
+	}
+	trialEnd, err := resolveTrial(start, in.TrialMonths, in.TrialEndMonth)
+	if err != nil {
+		return time.Time{}, err
+	}
+	in.TrialEndMonth = trialEnd
+	return start, nil
+}
+
+// endDate разбирает нормализованную дату окончания (YYYY-MM-DD); nil — бессрочно.
+func endDate(v *string) *time.Time {
+	if v == nil {
+		return nil
+	}
+	e, _ := time.Parse(dateLayout, *v)
+	return &e
+}
+
+// samePrice сравнивает цены численно ("10" и "10.00" равны).
+func samePrice(a, b string) bool {
+	x, errX := strconv.ParseFloat(a, 64)
+	y, errY := strconv.ParseFloat(b, 64)
+	return errX == nil && errY == nil && x == y
+}
+
+// catalogService проверяет, что service_id есть в каталоге.
+func (s *Service) catalogService(ctx context.Context, id uuid.UUID) (domain.CatalogService, error) {
+	if s.catalog == nil {
+		return domain.CatalogService{}, errors.New("invalid service_id (not in catalog)")
+	}
+	c, err := s.catalog.Get(ctx, id)
+	if errors.Is(err, domain.ErrNotFound) {
+		return c, errors.New("invalid service_id (not in catalog)")
+	}
+	return c, err
+}
+
+// matchService сопоставляет название подписки с каталогом; nil — совпадений нет.
+func (s *Service) matchService(ctx context.Context, name string) (*uuid.UUID, error) {
+	if s.catalog == nil {
+		return nil, nil
+	}
+	return s.catalog.Match(ctx, name)
+}
+
+// checkOverlap возвращает *OverlapError, если период [start, end] пересекается с другой
+// подпиской пользователя на тот же сервис.
+func (s *Service) checkOverlap(ctx context.Context, userID uuid.UUID, name string, serviceID *uuid.UUID, start time.Time, end *time.Time, exclude *uuid.UUID) error {
+	ids, err := s.repo.Overlapping(ctx, userID, name, serviceID, start, end, exclude)
+	if err != nil {
+		return err
+	}
+	if len(ids) > 0 {
+		return &OverlapError{Conflicts: ids}
+	}
+	return nil
+}
+
+// Duplicates находит уже сохранённые пересекающиеся подписки (например, импортированные
+// до появления проверки в Create и Update).
+func (s *Service) Duplicates(ctx context.Context, userID *uuid.UUID) ([]domain.DuplicateGroup, error) {
+	return s.repo.Duplicates(ctx, userID)
 }
 
 func (s *Service) Get(ctx context.Context, id uuid.UUID) (domain.Subscription, error) {
 	return s.repo.Get(ctx, id)
 }
 
-func (s *Service) List(ctx context.Context, f domain.ListFilter) ([]domain.Subscription, error) {
-	return s.repo.List(ctx, f)
+// MaxListLimit — наибольший размер страницы списка.
+const MaxListLimit = 200
+
+// List возвращает страницу из f.Limit подписок и курсор следующей; withTotal добавляет
+// число всех подписок под фильтром.
+func (s *Service) List(ctx context.Context, f domain.ListFilter, withTotal bool) (domain.SubscriptionPage, error) {
+	if err := validListFilter(&f); err != nil {
+		return domain.SubscriptionPage{}, err
+	}
+	if f.Limit < 1 || f.Limit > MaxListLimit {
+		return domain.SubscriptionPage{}, errors.New("invalid limit (1.." + strconv.Itoa(MaxListLimit) + ")")
+	}
+	if f.Offset < 0 {
+		return domain.SubscriptionPage{}, errors.New("invalid offset")
+	}
+	if f.After != nil {
+		if f.Offset > 0 {
+			return domain.SubscriptionPage{}, errors.New("invalid offset: cannot be combined with cursor")
+		}
+		if f.After.Sort != f.Sort() {
+			return domain.SubscriptionPage{}, errors.New("invalid cursor: issued for sort " + f.After.Sort)
+		}
+		if !validCursorKey(f.SortBy, f.After.Key) {
+			return domain.SubscriptionPage{}, errors.New("invalid cursor")
+		}
+	}
+
+	page, err := s.repo.List(ctx, f)
+	if err != nil {
+		return page, err
+	}
+	if withTotal {
+		n, err := s.repo.Count(ctx, f)
+		if err != nil {
+			return page, err
+		}
+		page.Total = &n
+	}
+	return page, nil
+}
+
+// validCursorKey проверяет, что значение курсора приводится к типу поля сортировки sortBy
+// (формат — в domain.ListCursor); иначе запрос упал бы на приведении типа в базе.
+func validCursorKey(sortBy, key string) bool {
+	switch sortBy {
+	case "", domain.SortCreatedAt, domain.SortUpdatedAt:
+		_, err := time.Parse(time.RFC3339Nano, key)
+		return err == nil
+	case domain.SortStartMonth:
+		_, err := time.Parse(dateLayout, key)
+		return err == nil
+	case domain.SortEndMonth:
+		_, err := time.Parse(dateLayout, key)
+		return err == nil || key == "infinity"
+	case domain.SortPrice, domain.SortMonthlyPrice:
+		return validPrice(key)
+	case domain.SortServiceName:
+		return utf8.ValidString(key) && !strings.ContainsRune(key, 0)
+	}
+	return false
+}
+
+// validListFilter проверяет фильтры и сортировку списка и подставляет значения по умолчанию.
+func validListFilter(f *domain.ListFilter) error {
+	tags, match, err := validTagFilter(f.Tags, f.TagMatch)
+	if err != nil {
+		return err
+	}
+	f.Tags, f.TagMatch = tags, match
+
+	switch f.ServiceMatch {
+	case "":
+		f.ServiceMatch = domain.ServiceMatchContains
+	case domain.ServiceMatchContains, domain.ServiceMatchExact:
+	default:
+		return errors.New("invalid service_match (contains, exact)")
+	}
+	for _, p := range []struct {
+		name string
+		v    *string
+	}{{"min_price", f.MinPrice}, {"max_price", f.MaxPrice}} {
+		if p.v != nil && (!validPrice(*p.v) || strings.HasPrefix(*p.v, "-")) {
+			return errors.New("invalid " + p.name)
+		}
+	}
+	if f.MinPrice != nil && f.MaxPrice != nil {
+		lo, _ := strconv.ParseFloat(*f.MinPrice, 64)
+		hi, _ := strconv.ParseFloat(*f.MaxPrice, 64)
+		if lo > hi {
+			return errors.New("invalid price range: min_price > max_price")
+		}
+	}
+	for _, st := range f.Statuses {
+		switch st {
+		case domain.StatusUpcoming, domain.StatusActive, domain.StatusPaused, domain.StatusEnded:
+		default:
+			return errors.New("invalid status (upcoming, active, paused, ended)")
+		}
+	}
+	if f.StartFrom != nil && f.StartTo != nil && f.StartTo.Before(*f.StartFrom) {
+		return errors.New("invalid start range: start_to < start_from")
+	}
+	if f.SortBy != "" && !slices.Contains(domain.ListSortFields, f.SortBy) {
+		return errors.New("invalid sort (" + strings.Join(domain.ListSortFields, ", ") + ")")
+	}
+	return nil
+}
+
+// Stream передаёт в fn все подписки под фильтром списка, без ограничения limit.
+func (s *Service) Stream(ctx context.Context, f domain.ListFilter, fn func(domain.Subscription) error) error {
+	if err := validListFilter(&f); err != nil {
+		return err
+	}
+	return s.repo.Stream(ctx, f, fn)
+}
+
+func (s *Service) Members(ctx context.Context, id uuid.UUID) ([]domain.Member, error) {
+	if _, err := s.repo.Get(ctx, id); err != nil {
+		return nil, err
+	}
+	return s.repo.Members(ctx, id)
+}
+
+// SetMembers заменяет участников подписки. Сумма процентов и фиксированных долей (в пересчёте
+// на текущую цену) не может превышать 100%.
+func (s *Service) SetMembers(ctx context.Context, id uuid.UUID, members []domain.Member) ([]domain.Member, error) {
+	sub, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	price, _ := strconv.ParseFloat(sub.Price, 64)
+	seen := make(map[uuid.UUID]bool, len(members))
+	var allocated float64
+	for i, m := range members {
+		at := " at #" + strconv.Itoa(i)
+		if m.UserID == uuid.Nil || seen[m.UserID] {
+			return nil, errors.New("invalid user_id (empty or duplicate)" + at)
+		}
+		seen[m.UserID] = true
+		switch m.Share {
+		case "", domain.ShareEqual:
+			members[i].Share, members[i].Value = domain.ShareEqual, nil
+		case domain.SharePercent:
+			if m.Value == nil || !validPrice(*m.Value) {
+				return nil, errors.New("invalid share value" + at)
+			}
+			v, _ := strconv.ParseFloat(*m.Value, 64)
+			if v <= 0 || v > 100 {
+				return nil, errors.New("invalid percent share (0..100]" + at)
+			}
+			allocated += v
+		case domain.ShareFixed:
+			if m.Value == nil || !validPrice(*m.Value) {
+				return nil, errors.New("invalid share value" + at)
+			}
+			v, _ := strconv.ParseFloat(*m.Value, 64)
+			if v <= 0 {
+				return nil, errors.New("invalid fixed share (> 0)" + at)
+			}
+			if price > 0 {
+				allocated += v / price * 100
+			}
+		default:
+			return nil, errors.New("invalid share (equal|percent|fixed)" + at)
+		}
+	}
+	if allocated > 100.0001 {
+		return nil, errors.New("invalid shares: percent and fixed shares exceed the price")
+	}
+	out, err := s.repo.SetMembers(ctx, id, members)
+	if err != nil {
+		return nil, err
+	}
+	s.checkBudgets(ctx, sub)
+	return out, nil
+}
+
+// Settlements возвращает, кто кому должен за совместные подписки за период fromStr–toStr.
+func (s *Service) Settlements(ctx context.Context, fromStr, toStr string, userID *uuid.UUID) ([]domain.Settlement, error) {
+	from, to, err := parsePeriod(fromStr, toStr)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.Settlements(ctx, from, to, userID)
+}
+
+// Tags возвращает теги с числом подписок.
+func (s *Service) Tags(ctx context.Context, userID *uuid.UUID) ([]domain.TagCount, error) {
+	return s.repo.Tags(ctx, userID)
 }
 
 func (s *Service) Update(ctx context.Context, id uuid.UUID, in domain.UpdateInput) (domain.Subscription, error) {
-	if in.MonthlyPrice != nil && !validPrice(*in.MonthlyPrice) {
-		return domain.Subscription{}, errors.New("invalid monthly_price")
+	if in.ServiceName != nil && strings.TrimSpace(*in.ServiceName) == "" {
+		return domain.Subscription{}, errors.New("invalid service_name")
+	}
+	switch {
+	case in.ServiceID != nil && *in.ServiceID != uuid.Nil:
+		if _, err := s.catalogService(ctx, *in.ServiceID); err != nil {
+			return domain.Subscription{}, err
+		}
+	case in.ServiceID == nil && in.ServiceName != nil:
+		// Новое название заново сопоставляется с каталогом.
+		match, err := s.matchService(ctx, *in.ServiceName)
+		if err != nil {
+			return domain.Subscription{}, err
+		}
+		if match == nil {
+			unlink := uuid.Nil
+			match = &unlink
+		}
+		in.ServiceID = match
+	}
+	if in.Price != nil {
+		if !validPrice(*in.Price) {
+			return domain.Subscription{}, errors.New("invalid price")
+		}
+		month, err := effectiveMonth(in.PriceEffectiveMonth)
+		if err != nil {
+			return domain.Subscription{}, err
+		}
+		m := month.Format("2006-01")
+		in.PriceEffectiveMonth = &m
+	}
+	if in.BillingPeriod != nil && !domain.ValidBillingPeriod(*in.BillingPeriod) {
+		return domain.Subscription{}, errors.New("invalid billing_period (week|month|quarter|year)")
+	}
+	if in.BillingInterval != nil && *in.BillingInterval < 1 {
+		return domain.Subscription{}, errors.New("invalid billing_interval (>= 1)")
+	}
+	if in.Currency != nil {
+		cur, ok := domain.NormalizeCurrency(*in.Currency)
+		if !ok {
+			return domain.Subscription{}, errors.New("invalid currency (ISO 4217)")
+		}
+		in.Currency = &cur
 	}
 	if in.StartMonth != nil {
-		if _, err := parseMonth(*in.StartMonth); err != nil {
-			return domain.Subscription{}, errors.New("invalid start_month (YYYY-MM)")
+		start, err := parseStart(*in.StartMonth)
+		if err != nil {
+			return domain.Subscription{}, errors.New("invalid start_month (YYYY-MM or YYYY-MM-DD)")
 		}
+		sm := start.Format(dateLayout)
+		in.StartMonth = &sm
 	}
 	if in.EndMonth != nil && *in.EndMonth != "" {
-		if _, err := parseMonth(*in.EndMonth); err != nil {
-			return domain.Subscription{}, errors.New("invalid end_month (YYYY-MM)")
+		end, err := parseEnd(*in.EndMonth)
+		if err != nil {
+			return domain.Subscription{}, errors.New("invalid end_month (YYYY-MM or YYYY-MM-DD)")
+		}
+		em := end.Format(dateLayout)
+		in.EndMonth = &em
+	}
+	if in.NoticeDays != nil && *in.NoticeDays < 0 {
+		return domain.Subscription{}, errors.New("invalid notice_days (>= 0)")
+	}
+	if in.Tags != nil {
+		tags, err := normalizeTags(*in.Tags)
+		if err != nil {
+			return domain.Subscription{}, err
+		}
+		in.Tags = &tags
+	}
+	dates := in.StartMonth != nil || in.EndMonth != nil || in.TrialMonths != nil || in.TrialEndMonth != nil
+	overlap := !in.AllowOverlap && (in.ServiceName != nil || in.ServiceID != nil || in.StartMonth != nil || in.EndMonth != nil)
+	if dates || overlap {
+		cur, err := s.repo.Get(ctx, id)
+		if err != nil {
+			return domain.Subscription{}, err
+		}
+		start, end, err := mergeDates(cur, &in)
+		if err != nil {
+			return domain.Subscription{}, err
+		}
+		if overlap {
+			name := cur.ServiceName
+			if in.ServiceName != nil {
+				name = *in.ServiceName
+			}
+			serviceID := cur.ServiceID
+			if in.ServiceID != nil {
+				serviceID = in.ServiceID
+				if *serviceID == uuid.Nil {
+					serviceID = nil
+				}
+			}
+			if err := s.checkOverlap(ctx, cur.UserID, name, serviceID, start, end, &id); err != nil {
+				return domain.Subscription{}, err
+			}
+		}
+	}
+	sub, err := s.repo.Update(ctx, id, in)
+	if err != nil {
+		return sub, err
+	}
+	s.checkBudgets(ctx, sub)
+	return sub, nil
+}
+
+// mergeDates проверяет даты подписки cur после частичного изменения in так же, как Create:
+// окончание и пробный период не раньше начала. trial_months переводится в trial_end_month
+// от итоговой даты начала. Возвращает итоговые начало и окончание (nil — бессрочно).
+func mergeDates(cur domain.Subscription, in *domain.UpdateInput) (time.Time, *time.Time, error) {
+	start, _ := parseStart(cur.StartMonth)
+	if in.StartMonth != nil {
+		start, _ = time.Parse(dateLayout, *in.StartMonth)
+	}
+	var end *time.Time
+	switch {
+	case in.EndMonth != nil && *in.EndMonth != "":
+		e, _ := time.Parse(dateLayout, *in.EndMonth)
+		end = &e
+	case in.EndMonth == nil && cur.EndMonth != nil:
+		e, _ := parseEnd(*cur.EndMonth)
+		end = &e
+	}
+	if end != nil && end.Before(start) {
+		return start, end, errors.New("invalid end_month (before start_month)")
+	}
+	if in.TrialMonths == nil && in.TrialEndMonth == nil {
+		if cur.TrialEndMonth != nil {
+			if t, err := parseEnd(*cur.TrialEndMonth); err == nil && t.Before(start) {
+				return start, end, errors.New("invalid trial_end_month (before start_month)")
+			}
+		}
+		return start, end, nil
+	}
+	months := 0
+	if in.TrialMonths != nil {
+		months = *in.TrialMonths
+	}
+	trialEnd, err := resolveTrial(start, months, in.TrialEndMonth)
+	if err != nil {
+		return start, end, err
+	}
+	clear := ""
+	if trialEnd == nil {
+		trialEnd = &clear
+	}
+	in.TrialEndMonth = trialEnd
+	in.TrialMonths = nil
+	return start, end, nil
+}
+
+// resolveTrial вычисляет последний день пробного периода (YYYY-MM-DD) по длине в месяцах
+// или по trial_end_month. nil — пробного периода нет.
+func resolveTrial(start time.Time, months int, endStr *string) (*string, error) {
+	hasEnd := endStr != nil && *endStr != ""
+	if months < 0 {
+		return nil, errors.New("invalid trial_months (>= 0)")
+	}
+	if months > 0 && hasEnd {
+		return nil, errors.New("invalid trial: use either trial_months or trial_end_month")
+	}
+	var end time.Time
+	switch {
+	case months > 0:
+		end = addMonths(start, months).AddDate(0, 0, -1)
+	case hasEnd:
+		t, err := parseEnd(*endStr)
+		if err != nil {
+			return nil, errors.New("invalid trial_end_month (YYYY-MM or YYYY-MM-DD)")
+		}
+		if t.Before(start) {
+			return nil, errors.New("invalid trial_end_month (before start_month)")
+		}
+		end = t
+	default:
+		return nil, nil
+	}
+	e := end.Format(dateLayout)
+	return &e, nil
+}
+
+// parseWithin разбирает горизонт вида 30d, 2w или 30 (дни).
+func parseWithin(v string) (int, error) {
+	if v == "" {
+		return 30, nil
+	}
+	mult := 1
+	switch {
+	case strings.HasSuffix(v, "d"):
+		v = strings.TrimSuffix(v, "d")
+	case strings.HasSuffix(v, "w"):
+		v, mult = strings.TrimSuffix(v, "w"), 7
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 || n > 3660 {
+		return 0, errors.New("invalid within (e.g. 30d, 2w)")
+	}
+	return n * mult, nil
+}
+
+// TrialsEnding — подписки, у которых пробный период заканчивается в пределах within (по умолчанию 30d).
+func (s *Service) TrialsEnding(ctx context.Context, within string) ([]domain.Subscription, error) {
+	days, err := parseWithin(within)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.TrialsEnding(ctx, days)
+}
+
+// effectiveMonth разбирает месяц начала действия цены; по умолчанию — текущий месяц.
+func effectiveMonth(v *string) (time.Time, error) {
+	if v == nil || *v == "" {
+		now := time.Now().UTC()
+		return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC), nil
+	}
+	m, err := parseMonth(*v)
+	if err != nil {
+		return time.Time{}, errors.New("invalid effective_month (YYYY-MM)")
+	}
+	return m, nil
+}
+
+// AddPrice меняет цену с месяца monthStr, сохраняя прошлые цены; будущий месяц — запланированное изменение.
+func (s *Service) AddPrice(ctx context.Context, id uuid.UUID, monthStr, price string) (domain.PriceChange, error) {
+	if !validPrice(price) {
+		return domain.PriceChange{}, errors.New("invalid price")
+	}
+	month, err := effectiveMonth(&monthStr)
+	if err != nil {
+		return domain.PriceChange{}, err
+	}
+	sub, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return domain.PriceChange{}, err
+	}
+	p, err := s.repo.AddPrice(ctx, id, month, price)
+	if err != nil {
+		return domain.PriceChange{}, err
+	}
+	s.checkBudgets(ctx, sub)
+	return p, nil
+}
+
+func (s *Service) Prices(ctx context.Context, id uuid.UUID) ([]domain.PriceChange, error) {
+	if _, err := s.repo.Get(ctx, id); err != nil {
+		return nil, err
+	}
+	return s.repo.Prices(ctx, id)
+}
+
+// dayOrToday разбирает дату (YYYY-MM-DD или YYYY-MM); пустая строка — сегодня.
+func dayOrToday(v string) (time.Time, error) {
+	if v == "" {
+		now := time.Now().UTC()
+		return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC), nil
+	}
+	return parseStart(v)
+}
+
+// Pause приостанавливает подписку с fromStr (по умолчанию сегодня) до resumeStr — первого дня
+// после паузы; пустой resumeStr — до явного Resume.
+func (s *Service) Pause(ctx context.Context, id uuid.UUID, fromStr, resumeStr string) (domain.Subscription, error) {
+	from, err := dayOrToday(fromStr)
+	if err != nil {
+		return domain.Subscription{}, errors.New("invalid from (YYYY-MM-DD)")
+	}
+	var resumeAt *time.Time
+	if resumeStr != "" {
+		t, err := parseStart(resumeStr)
+		if err != nil {
+			return domain.Subscription{}, errors.New("invalid resume_at (YYYY-MM-DD)")
+		}
+		if !t.After(from) {
+			return domain.Subscription{}, errors.New("invalid resume_at (must be after from)")
 		}
+		resumeAt = &t
 	}
-	return s.repo.Update(ctx, id, in)
+	cur, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return domain.Subscription{}, err
+	}
+	if cur.EndMonth != nil {
+		if end, _ := parseEnd(*cur.EndMonth); end.Before(from) {
+			return domain.Subscription{}, errors.New("invalid pause: subscription ends before from")
+		}
+	}
+	ok, err := s.repo.Pause(ctx, id, from, resumeAt)
+	if err != nil {
+		return domain.Subscription{}, err
+	}
+	if !ok {
+		return domain.Subscription{}, errors.New("invalid pause: overlaps an existing pause")
+	}
+	sub, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return domain.Subscription{}, err
+	}
+	s.checkBudgets(ctx, sub)
+	return sub, nil
 }
 
-func (s *Service) Delete(ctx context.Context, id uuid.UUID) (bool, error) {
-	return s.repo.Delete(ctx, id)
+// Resume возобновляет подписку с atStr (по умолчанию сегодня).
+func (s *Service) Resume(ctx context.Context, id uuid.UUID, atStr string) (domain.Subscription, error) {
+	at, err := dayOrToday(atStr)
+	if err != nil {
+		return domain.Subscription{}, errors.New("invalid at (YYYY-MM-DD)")
+	}
+	if _, err := s.repo.Get(ctx, id); err != nil {
+		return domain.Subscription{}, err
+	}
+	ok, err := s.repo.Resume(ctx, id, at)
+	if err != nil {
+		return domain.Subscription{}, err
+	}
+	if !ok {
+		return domain.Subscription{}, errors.New("invalid resume: subscription is not paused")
+	}
+	sub, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return domain.Subscription{}, err
+	}
+	s.checkBudgets(ctx, sub)
+	return sub, nil
+}
+
+func (s *Service) Pauses(ctx context.Context, id uuid.UUID) ([]domain.Pause, error) {
+	if _, err := s.repo.Get(ctx, id); err != nil {
+		return nil, err
+	}
+	return s.repo.Pauses(ctx, id)
+}
+
+// addPeriods сдвигает t на k периодов period. Как и в Postgres, число, которого нет в целевом
+// месяце, заменяется его последним днём (31 января + 1 месяц = 28 февраля).
+func addPeriods(t time.Time, period string, k int) time.Time {
+	switch period {
+	case domain.PeriodWeek:
+		return t.AddDate(0, 0, 7*k)
+	case domain.PeriodQuarter:
+		return addMonths(t, 3*k)
+	case domain.PeriodYear:
+		return addMonths(t, 12*k)
+	default:
+		return addMonths(t, k)
+	}
+}
+
+func addMonths(t time.Time, n int) time.Time {
+	first := time.Date(t.Year(), t.Month()+time.Month(n), 1, 0, 0, 0, 0, time.UTC)
+	last := first.AddDate(0, 1, -1).Day()
+	return first.AddDate(0, 0, min(t.Day(), last)-1)
+}
+
+// paidFrom возвращает первый оплачиваемый день подписки — следующий за пробным периодом или
+// дату начала, как paid_from в Total. От него отсчитываются списания и расчётные периоды.
+func paidFrom(sub domain.Subscription) (time.Time, error) {
+	start, err := parseStart(sub.StartMonth)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if sub.TrialEndMonth != nil {
+		if trialEnd, err := parseEnd(*sub.TrialEndMonth); err == nil && !trialEnd.Before(start) {
+			return trialEnd.AddDate(0, 0, 1), nil
+		}
+	}
+	return start, nil
+}
+
+// termEnd возвращает последний день расчётного периода, в который попадает день d.
+// Периоды отсчитываются от anchor — первого оплачиваемого дня (paidFrom).
+func termEnd(anchor time.Time, period string, interval int, d time.Time) time.Time {
+	for k := interval; ; k += interval {
+		if next := addPeriods(anchor, period, k); next.After(d) {
+			return next.AddDate(0, 0, -1)
+		}
+	}
 }
 
-func (s *Service) Total(ctx context.Context, fromStr, toStr string, userID *uuid.UUID, service *string) (string, error) {
+// Cancel отменяет подписку по запросу от in.RequestedAt (по умолчанию сегодня). Подписка действует
+// до конца расчётного периода, в который попадает окончание срока уведомления NoticeDays; если
+// срок истекает в пробный период — до его конца, без платных списаний.
+// Повторную отмену и отмену закончившейся подписки репозиторий проверяет ещё раз под блокировкой,
+// поэтому из двух одновременных отмен проходит одна.
+func (s *Service) Cancel(ctx context.Context, id uuid.UUID, in domain.CancelInput) (domain.Subscription, error) {
+	requested, err := dayOrToday(in.RequestedAt)
+	if err != nil {
+		return domain.Subscription{}, errors.New("invalid requested_at (YYYY-MM-DD)")
+	}
+	cur, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return domain.Subscription{}, err
+	}
+	if cur.Cancellation != nil {
+		return domain.Subscription{}, ErrAlreadyCancelled
+	}
+	start, _ := parseStart(cur.StartMonth)
+	anchor, _ := paidFrom(cur)
+	noticeEnd := requested.AddDate(0, 0, cur.NoticeDays)
+	end := termEnd(anchor, cur.BillingPeriod, cur.BillingInterval, noticeEnd)
+	if anchor.After(start) && noticeEnd.Before(anchor) {
+		end = anchor.AddDate(0, 0, -1)
+	}
+	if cur.EndMonth != nil {
+		curEnd, _ := parseEnd(*cur.EndMonth)
+		if curEnd.Before(requested) {
+			return domain.Subscription{}, ErrAlreadyEnded
+		}
+		if curEnd.Before(end) {
+			end = curEnd
+		}
+	}
+	return s.repo.Cancel(ctx, id, requested, end, strings.TrimSpace(in.By), strings.TrimSpace(in.Reason))
+}
+
+// Delete перемещает подписку в корзину; ifVersion — ожидаемая версия подписки.
+func (s *Service) Delete(ctx context.Context, id uuid.UUID, ifVersion *int) (bool, error) {
+	return s.repo.Delete(ctx, id, ifVersion)
+}
+
+func (s *Service) Trash(ctx context.Context, f domain.ListFilter) ([]domain.Subscription, error) {
+	return s.repo.Trash(ctx, f)
+}
+
+// Restore возвращает подписку из корзины. Пересечение её периода с другими подписками
+// пользователя на тот же сервис проверяется как при Create, если не задан allowOverlap.
+func (s *Service) Restore(ctx context.Context, id uuid.UUID, allowOverlap bool) (domain.Subscription, error) {
+	if !allowOverlap {
+		cur, err := s.repo.GetDeleted(ctx, id)
+		if err != nil {
+			return domain.Subscription{}, err
+		}
+		start, _ := parseStart(cur.StartMonth)
+		var end *time.Time
+		if cur.EndMonth != nil {
+			e, _ := parseEnd(*cur.EndMonth)
+			end = &e
+		}
+		if err := s.checkOverlap(ctx, cur.UserID, cur.ServiceName, cur.ServiceID, start, end, &id); err != nil {
+			return domain.Subscription{}, err
+		}
+	}
+	sub, err := s.repo.Restore(ctx, id)
+	if err != nil {
+		return sub, err
+	}
+	s.checkBudgets(ctx, sub)
+	return sub, nil
+}
+
+// PurgeTrash окончательно удаляет подписки, пролежавшие в корзине дольше retention.
+func (s *Service) PurgeTrash(ctx context.Context, retention time.Duration) (int, error) {
+	if retention <= 0 {
+		return 0, errors.New("invalid retention (> 0)")
+	}
+	return s.repo.Purge(ctx, time.Now().Add(-retention))
+}
+
+// History возвращает журнал изменений подписки, в том числе удалённой.
+func (s *Service) History(ctx context.Context, id uuid.UUID, limit, offset int) ([]domain.Event, error) {
+	events, err := s.repo.Events(ctx, domain.EventFilter{SubscriptionID: &id, Limit: limit, Offset: offset})
+	if err != nil {
+		return nil, err
+	}
+	// Подписки, созданные до появления журнала, могут не иметь событий.
+	if len(events) == 0 && offset == 0 {
+		if _, err := s.repo.Get(ctx, id); err != nil {
+			return nil, err
+		}
+	}
+	return events, nil
+}
+
+// Audit возвращает журнал изменений всех подписок. from/to — RFC 3339 или YYYY-MM-DD
+// (to-дата включительно); пустые границы не ограничивают период.
+func (s *Service) Audit(ctx context.Context, fromStr, toStr string, f domain.EventFilter) ([]domain.Event, error) {
+	if fromStr != "" {
+		from, err := parseInstant(fromStr, false)
+		if err != nil {
+			return nil, errors.New("invalid from (RFC 3339 or YYYY-MM-DD)")
+		}
+		f.From = &from
+	}
+	if toStr != "" {
+		to, err := parseInstant(toStr, true)
+		if err != nil {
+			return nil, errors.New("invalid to (RFC 3339 or YYYY-MM-DD)")
+		}
+		f.To = &to
+	}
+	if f.From != nil && f.To != nil && !f.To.After(*f.From) {
+		return nil, errors.New("invalid period: to must be after from")
+	}
+	return s.repo.Events(ctx, f)
+}
+
+// parseInstant разбирает момент времени RFC 3339 или дату; для конца периода (end) дата
+// означает начало следующего дня.
+func parseInstant(v string, end bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(dateLayout, v)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if end {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
+
+// parsePeriod разбирает границы периода from/to в формате YYYY-MM.
+func parsePeriod(fromStr, toStr string) (time.Time, time.Time, error) {
 	from, err := parseMonth(fromStr)
 	if err != nil {
-		return "", errors.New("invalid from (YYYY-MM)")
+		return time.Time{}, time.Time{}, errors.New("invalid from (YYYY-MM)")
 	}
 	to, err := parseMonth(toStr)
 	if err != nil {
-		return "", errors.New("invalid to (YYYY-MM)")
+		return time.Time{}, time.Time{}, errors.New("invalid to (YYYY-MM)")
 	}
 	if to.Before(from) {
-		return "", errors.New("to must be >= from")
+		return time.Time{}, time.Time{}, errors.New("to must be >= from")
+	}
+	return from, to, nil
+}
+
+func (s *Service) Total(ctx context.Context, fromStr, toStr string, f domain.TotalFilter) ([]domain.CurrencyTotal, error) {
+	from, to, err := parsePeriod(fromStr, toStr)
+	if err != nil {
+		return nil, err
+	}
+	if err := validTotalFilter(&f); err != nil {
+		return nil, err
+	}
+	return s.repo.Total(ctx, from, to, f)
+}
+
+// Breakdown возвращает начисления и число активных подписок по каждому месяцу периода.
+func (s *Service) Breakdown(ctx context.Context, fromStr, toStr string, f domain.TotalFilter) ([]domain.MonthBreakdown, error) {
+	from, to, err := parsePeriod(fromStr, toStr)
+	if err != nil {
+		return nil, err
+	}
+	if err := validTotalFilter(&f); err != nil {
+		return nil, err
+	}
+	return s.repo.Breakdown(ctx, from, to, f)
+}
+
+// GroupedTotal считает суммы за период с группировкой по groupBy (service_name, user_id, month);
+// top > 0 оставляет top самых дорогих групп в каждой валюте, остальные попадают в other.
+func (s *Service) GroupedTotal(ctx context.Context, fromStr, toStr string, f domain.TotalFilter, groupBy []string, top int) (domain.GroupedTotals, error) {
+	from, to, err := parsePeriod(fromStr, toStr)
+	if err != nil {
+		return domain.GroupedTotals{}, err
+	}
+	if err := validTotalFilter(&f); err != nil {
+		return domain.GroupedTotals{}, err
+	}
+	if len(groupBy) == 0 {
+		return domain.GroupedTotals{}, errors.New("invalid group_by: required")
+	}
+	seen := make(map[string]bool, len(groupBy))
+	for _, g := range groupBy {
+		switch g {
+		case domain.GroupByServiceName, domain.GroupByUserID, domain.GroupByMonth:
+		default:
+			return domain.GroupedTotals{}, errors.New("invalid group_by (service_name|user_id|month)")
+		}
+		if seen[g] {
+			return domain.GroupedTotals{}, errors.New("invalid group_by: duplicate " + g)
+		}
+		seen[g] = true
+	}
+	if top < 0 {
+		return domain.GroupedTotals{}, errors.New("invalid top (>= 0)")
+	}
+	return s.repo.GroupedTotal(ctx, from, to, f, groupBy, top)
+}
+
+// Forecast прогнозирует начисления на months месяцев начиная с текущего. Учитываются известные
+// даты окончания, запланированные изменения цен и паузы; бессрочные подписки считаются
+// продолжающимися, их доля отражена в Confidence и Note. Фильтр open_ended не поддерживается:
+// прогноз сам делит подписки на бессрочные и с датой окончания.
+func (s *Service) Forecast(ctx context.Context, months int, f domain.TotalFilter) ([]domain.ForecastMonth, error) {
+	if months < 1 || months > 60 {
+		return nil, errors.New("invalid months (1..60)")
+	}
+	if f.OpenEnded != nil {
+		return nil, errors.New("invalid open_ended (not supported by forecast)")
+	}
+	if err := validTotalFilter(&f); err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	from := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, months-1, 0)
+
+	all, err := s.repo.Breakdown(ctx, from, to, f)
+	if err != nil {
+		return nil, err
+	}
+	openEnded := true
+	f.OpenEnded = &openEnded
+	open, err := s.repo.Breakdown(ctx, from, to, f)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]domain.ForecastMonth, 0, len(all))
+	for i, m := range all {
+		fm := domain.ForecastMonth{
+			Month:               m.Month,
+			ActiveSubscriptions: m.ActiveSubscriptions,
+			Totals:              m.Totals,
+			OpenEndedTotals:     []domain.CurrencyTotal{},
+		}
+		if i < len(open) && open[i].Month == m.Month {
+			fm.OpenEndedSubscriptions = open[i].ActiveSubscriptions
+			fm.OpenEndedTotals = open[i].Totals
+		}
+		fm.Confidence, fm.Note = forecastConfidence(fm.ActiveSubscriptions, fm.OpenEndedSubscriptions)
+		out = append(out, fm)
+	}
+	return out, nil
+}
+
+// forecastConfidence оценивает прогноз месяца по доле бессрочных подписок среди active.
+// Месяц без подписок прогнозируется точно: начислений не будет.
+func forecastConfidence(active, openEnded int) (confidence, note string) {
+	switch {
+	case active == 0:
+		return domain.ConfidenceHigh, ""
+	case openEnded == 0:
+		return domain.ConfidenceHigh, "all subscriptions have known end dates"
+	case openEnded < active:
+		return domain.ConfidenceMedium, strconv.Itoa(openEnded) + " of " + strconv.Itoa(active) +
+			" subscriptions are open-ended and assumed to continue"
+	default:
+		return domain.ConfidenceLow, "all subscriptions are open-ended and assumed to continue"
+	}
+}
+
+// ConvertedTotal считает сумму за период в валюте currency по курсам каждого месяца.
+func (s *Service) ConvertedTotal(ctx context.Context, fromStr, toStr string, f domain.TotalFilter, currency string) (domain.ConvertedTotal, error) {
+	from, to, err := parsePeriod(fromStr, toStr)
+	if err != nil {
+		return domain.ConvertedTotal{}, err
+	}
+	if err := validTotalFilter(&f); err != nil {
+		return domain.ConvertedTotal{}, err
+	}
+	cur, ok := domain.NormalizeCurrency(currency)
+	if !ok {
+		return domain.ConvertedTotal{}, errors.New("invalid currency (ISO 4217)")
 	}
-	return s.repo.Total(ctx, from, to, userID, service)
+	return s.repo.ConvertedTotal(ctx, from, to, f, cur)
 }
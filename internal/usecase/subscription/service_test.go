@@ -0,0 +1,187 @@
+package subscription
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"crud_ef/internal/domain"
+)
+
+func day(s string) time.Time {
+	t, err := time.Parse(dateLayout, s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestResolveTrial(t *testing.T) {
+	str := func(s string) *string { return &s }
+	tests := []struct {
+		name   string
+		start  string
+		months int
+		end    *string
+		want   string // "" — пробного периода нет
+	}{
+		{"no trial", "2025-01-01", 0, nil, ""},
+		{"empty end clears", "2025-01-01", 0, str(""), ""},
+		{"one month", "2025-01-01", 1, nil, "2025-01-31"},
+		{"three months mid-month", "2025-01-15", 3, nil, "2025-04-14"},
+		{"month-end start clamps", "2025-01-31", 1, nil, "2025-02-27"},
+		{"month-end start in leap year", "2024-01-31", 1, nil, "2024-02-28"},
+		{"month-end start, two months", "2025-01-31", 2, nil, "2025-03-30"},
+		{"end month is its last day", "2025-01-01", 0, str("2025-02"), "2025-02-28"},
+		{"end day", "2025-01-10", 0, str("2025-01-20"), "2025-01-20"},
+		{"end on start day", "2025-01-10", 0, str("2025-01-10"), "2025-01-10"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolveTrial(day(tt.start), tt.months, tt.end)
+			if err != nil {
+				t.Fatalf("resolveTrial: %v", err)
+			}
+			switch {
+			case tt.want == "" && got != nil:
+				t.Errorf("got %q, want no trial", *got)
+			case tt.want != "" && (got == nil || *got != tt.want):
+				t.Errorf("got %v, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveTrialErrors(t *testing.T) {
+	str := func(s string) *string { return &s }
+	tests := []struct {
+		name   string
+		months int
+		end    *string
+		want   string
+	}{
+		{"negative months", -1, nil, "invalid trial_months"},
+		{"both forms", 1, str("2025-03"), "invalid trial: use either"},
+		{"bad end", 0, str("March"), "invalid trial_end_month (YYYY-MM"},
+		{"end before start", 0, str("2025-01-09"), "invalid trial_end_month (before start_month)"},
+		{"end month before start", 0, str("2024-12"), "invalid trial_end_month (before start_month)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := resolveTrial(day("2025-01-10"), tt.months, tt.end)
+			if err == nil || !strings.HasPrefix(err.Error(), tt.want) {
+				t.Errorf("error = %v, want prefix %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestTermEnd(t *testing.T) {
+	tests := []struct {
+		name     string
+		start    string
+		period   string
+		interval int
+		d        string
+		want     string
+	}{
+		{"monthly, first day", "2025-01-01", domain.PeriodMonth, 1, "2025-01-15", "2025-01-31"},
+		{"monthly, last day of term", "2025-01-01", domain.PeriodMonth, 1, "2025-01-31", "2025-01-31"},
+		{"monthly, first day of next term", "2025-01-01", domain.PeriodMonth, 1, "2025-02-01", "2025-02-28"},
+		{"monthly, mid-month start", "2025-01-15", domain.PeriodMonth, 1, "2025-03-20", "2025-04-14"},
+		{"monthly, month-end start clamps", "2025-01-31", domain.PeriodMonth, 1, "2025-02-10", "2025-02-27"},
+		{"monthly, after short month", "2025-01-31", domain.PeriodMonth, 1, "2025-02-28", "2025-03-30"},
+		{"every two months", "2025-01-01", domain.PeriodMonth, 2, "2025-02-15", "2025-02-28"},
+		{"quarterly", "2025-01-15", domain.PeriodQuarter, 1, "2025-03-01", "2025-04-14"},
+		{"yearly from leap day", "2024-02-29", domain.PeriodYear, 1, "2024-06-01", "2025-02-27"},
+		{"weekly", "2025-01-01", domain.PeriodWeek, 1, "2025-01-08", "2025-01-14"},
+		{"every two weeks", "2025-01-01", domain.PeriodWeek, 2, "2025-01-10", "2025-01-14"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := termEnd(day(tt.start), tt.period, tt.interval, day(tt.d))
+			if got.Format(dateLayout) != tt.want {
+				t.Errorf("termEnd(%s, %s×%d, %s) = %s, want %s",
+					tt.start, tt.period, tt.interval, tt.d, got.Format(dateLayout), tt.want)
+			}
+		})
+	}
+}
+
+func TestValidTotalFilterProrate(t *testing.T) {
+	tests := []struct {
+		name    string
+		f       domain.TotalFilter
+		wantErr bool
+	}{
+		{"charges by default", domain.TotalFilter{}, false},
+		{"spread", domain.TotalFilter{Mode: domain.TotalModeSpread, Prorate: true}, false},
+		{"default mode", domain.TotalFilter{Prorate: true}, true},
+		{"charges", domain.TotalFilter{Mode: domain.TotalModeCharges, Prorate: true}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validTotalFilter(&tt.f)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validTotalFilter(%+v) = %v, wantErr %v", tt.f, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidCursorKey(t *testing.T) {
+	tests := []struct {
+		sortBy string
+		key    string
+		want   bool
+	}{
+		{"", "2025-03-01T10:00:00.123456Z", true},
+		{domain.SortUpdatedAt, "2025-03-01T10:00:00Z", true},
+		{domain.SortCreatedAt, "2025-03-01 10:00:00+00", false},
+		{domain.SortCreatedAt, "x", false},
+		{domain.SortStartMonth, "2025-01-20", true},
+		{domain.SortStartMonth, "infinity", false},
+		{domain.SortEndMonth, "infinity", true},
+		{domain.SortEndMonth, "2025-02-30", false},
+		{domain.SortPrice, "9.99", true},
+		{domain.SortMonthlyPrice, "10", true},
+		{domain.SortPrice, "NaN", false},
+		{domain.SortPrice, "1e3", false},
+		{domain.SortServiceName, "Яндекс Плюс", true},
+		{domain.SortServiceName, "", true},
+		{domain.SortServiceName, "a\x00b", false},
+		{"owner", "x", false},
+	}
+	for _, tt := range tests {
+		if got := validCursorKey(tt.sortBy, tt.key); got != tt.want {
+			t.Errorf("validCursorKey(%q, %q) = %v, want %v", tt.sortBy, tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestForecastConfidence(t *testing.T) {
+	tests := []struct {
+		active, openEnded int
+		want, note        string
+	}{
+		{0, 0, domain.ConfidenceHigh, ""},
+		{3, 0, domain.ConfidenceHigh, "all subscriptions have known end dates"},
+		{3, 1, domain.ConfidenceMedium, "1 of 3 subscriptions are open-ended and assumed to continue"},
+		{3, 3, domain.ConfidenceLow, "all subscriptions are open-ended and assumed to continue"},
+	}
+	for _, tt := range tests {
+		got, note := forecastConfidence(tt.active, tt.openEnded)
+		if got != tt.want || note != tt.note {
+			t.Errorf("forecastConfidence(%d, %d) = %q, %q; want %q, %q", tt.active, tt.openEnded, got, note, tt.want, tt.note)
+		}
+	}
+}
+
+func TestForecastRejectsOpenEnded(t *testing.T) {
+	openEnded := false
+	_, err := NewService(nil).Forecast(context.Background(), 12, domain.TotalFilter{OpenEnded: &openEnded})
+	if err == nil || !strings.HasPrefix(err.Error(), "invalid open_ended") {
+		t.Errorf("error = %v, want invalid open_ended", err)
+	}
+}